@@ -114,4 +114,100 @@ CREATE TABLE `requests` (
   )
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
 
+DROP TABLE IF EXISTS `billing_runs`;
+CREATE TABLE `billing_runs` (
+  `id`            INT UNSIGNED  NOT NULL AUTO_INCREMENT,
+  `billing_month` DATE          NOT NULL,
+  `started_at`    DATETIME      NOT NULL,
+  `finished_at`   DATETIME      DEFAULT NULL,
+  `status`        VARCHAR(16)   NOT NULL,
+  `error`         TEXT,
+  `files`         LONGTEXT,
+  PRIMARY KEY (`id`),
+  KEY `idx_billing_runs_month` (`billing_month`, `status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+DROP TABLE IF EXISTS `maintenance_windows`;
+CREATE TABLE `maintenance_windows` (
+  `id`          INT UNSIGNED  NOT NULL AUTO_INCREMENT,
+  `member_name` VARCHAR(255)  NOT NULL,
+  `services`    TEXT,
+  `start_time`  DATETIME      NOT NULL,
+  `end_time`    DATETIME      NOT NULL,
+  `reason`      TEXT,
+  `created_at`  DATETIME      NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_maintenance_member_time` (`member_name`, `start_time`, `end_time`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+DROP TABLE IF EXISTS `billing_snapshots`;
+CREATE TABLE `billing_snapshots` (
+  `billing_month` DATE          NOT NULL,
+  `created_at`    DATETIME      NOT NULL,
+  `data`          LONGTEXT      NOT NULL,
+  PRIMARY KEY (`billing_month`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+DROP TABLE IF EXISTS `billing_config_history`;
+CREATE TABLE `billing_config_history` (
+  `id`             INT UNSIGNED  NOT NULL AUTO_INCREMENT,
+  `kind`           VARCHAR(16)   NOT NULL,
+  `name`           VARCHAR(255)  NOT NULL,
+  `effective_from` DATETIME      NOT NULL,
+  `data`           TEXT          NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_billing_config_history` (`kind`, `name`, `effective_from`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+DROP TABLE IF EXISTS `billing_adjustments`;
+CREATE TABLE `billing_adjustments` (
+  `id`            INT UNSIGNED  NOT NULL AUTO_INCREMENT,
+  `member_name`   VARCHAR(255)  NOT NULL,
+  `billing_month` DATE          NOT NULL,
+  `service_name`  VARCHAR(255)  NOT NULL DEFAULT '',
+  `amount`        DECIMAL(14,2) NOT NULL,
+  `reason`        TEXT          NOT NULL,
+  `created_by`    VARCHAR(255)  NOT NULL,
+  `created_at`    DATETIME      NOT NULL,
+  `voided_at`     DATETIME      NULL,
+  `voided_by`     VARCHAR(255)  NULL,
+  `void_reason`   TEXT          NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_adjustments_month_member` (`billing_month`, `member_name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+DROP TABLE IF EXISTS `billing_invoices`;
+CREATE TABLE `billing_invoices` (
+  `id`                INT UNSIGNED  NOT NULL AUTO_INCREMENT,
+  `sequence`          INT UNSIGNED  NOT NULL,
+  `invoice_number`    VARCHAR(64)   NOT NULL,
+  `member_name`       VARCHAR(255)  NOT NULL,
+  `billing_month`     DATE          NOT NULL,
+  `issue_date`        DATE          NOT NULL,
+  `due_date`          DATE          NOT NULL,
+  `base_total`        DECIMAL(14,2) NOT NULL,
+  `credits_total`     DECIMAL(14,2) NOT NULL,
+  `adjustments_total` DECIMAL(14,2) NOT NULL,
+  `amount_due`        DECIMAL(14,2) NOT NULL,
+  `status`            VARCHAR(16)   NOT NULL,
+  `paid_at`           DATETIME      NULL,
+  `reference`         VARCHAR(255)  NOT NULL DEFAULT '',
+  `created_at`        DATETIME      NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uq_invoice_sequence` (`sequence`),
+  UNIQUE KEY `uq_invoice_number` (`invoice_number`),
+  KEY `idx_invoices_month_member` (`billing_month`, `member_name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+DROP TABLE IF EXISTS `billing_exchange_rates`;
+CREATE TABLE `billing_exchange_rates` (
+  `currency`      VARCHAR(10)    NOT NULL,
+  `billing_month` DATE           NOT NULL,
+  `usd_per_unit`  DECIMAL(24,10) NOT NULL,
+  `rate_date`     DATE           NOT NULL,
+  `updated_by`    VARCHAR(255)   NOT NULL,
+  `updated_at`    DATETIME       NOT NULL,
+  PRIMARY KEY (`currency`, `billing_month`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
 SET FOREIGN_KEY_CHECKS = 1;
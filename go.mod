@@ -4,6 +4,7 @@ go 1.24.2
 
 require (
 	github.com/ibp-network/ibp-geodns-libs v0.2.0
+	github.com/nats-io/nats.go v1.45.0
 	github.com/phpdave11/gofpdf v1.4.3
 )
 
@@ -14,7 +15,6 @@ require (
 	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/mattn/go-colorable v0.1.14 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/nats-io/nats.go v1.45.0 // indirect
 	github.com/nats-io/nkeys v0.4.11 // indirect
 	github.com/nats-io/nuid v1.0.1 // indirect
 	github.com/oschwald/maxminddb-golang v1.13.1 // indirect
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
 
 	api "github.com/ibp-network/ibp-geodns-collator/src/api"
+	cluster "github.com/ibp-network/ibp-geodns-collator/src/cluster"
+	common "github.com/ibp-network/ibp-geodns-collator/src/common"
 
 	nats "github.com/ibp-network/ibp-geodns-libs/nats"
 
@@ -33,6 +38,10 @@ func main() {
 	// ── load configuration ──────────────────────────────────────────────────────
 	cfg.Init(*cfgPath)
 	c := cfg.GetConfig()
+	if err := common.LoadSettings(*cfgPath); err != nil {
+		log.Log(log.Fatal, "collator settings: %v", err)
+		os.Exit(1)
+	}
 	log.SetLogLevel(log.ParseLogLevel(c.Local.System.LogLevel))
 
 	// ── subsystems ──────────────────────────────────────────────────────────────
@@ -68,15 +77,53 @@ func main() {
 		os.Exit(1)
 	}
 
+	// ── leader election: only the leader runs scheduled billing jobs ───────────
+	if err := cluster.Init(c.Local.Nats.NodeID); err != nil {
+		log.Log(log.Fatal, "leader election: %v", err)
+		os.Exit(1)
+	}
+
 	// kick-off background collectors
 	go nats.StartUsageCollector()
 	go nats.StartMemoryJanitor()
 
 	log.Log(log.Info, "[collator] started – awaiting events")
 
-	for {
-		time.Sleep(1 * time.Hour)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	stop()
+
+	shutdown()
+}
+
+// shutdown drains the API, stops the billing schedulers and closes NATS and
+// the database, giving up once System.ShutdownTimeout has elapsed.
+func shutdown() {
+	timeout := common.GetSettings().System.ShutdownTimeoutDuration()
+	log.Log(log.Info, "[collator] shutdown requested – draining (timeout %s)", timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := api.Shutdown(ctx); err != nil {
+		log.Log(log.Error, "[collator] %v", err)
+	}
+	if err := billing.Shutdown(ctx); err != nil {
+		log.Log(log.Error, "[collator] %v", err)
+	}
+
+	cluster.Shutdown()
+
+	nats.Disconnect()
+
+	if data2.DB != nil {
+		if err := data2.DB.Close(); err != nil {
+			log.Log(log.Error, "[collator] close database: %v", err)
+		}
 	}
+
+	log.Log(log.Info, "[collator] shutdown complete")
 }
 
 func migrateMemberEventCheckTypesToNumeric() error {
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
+
+	cfg "github.com/ibp-network/ibp-geodns-libs/config"
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+const (
+	maxAdjustmentReason = 1000
+	maxAdjustmentAmount = 1e9
+)
+
+type AdjustmentResponse struct {
+	ID         int64      `json:"id"`
+	Member     string     `json:"member"`
+	Month      string     `json:"month"`
+	Service    string     `json:"service,omitempty"`
+	Amount     float64    `json:"amount"`
+	Reason     string     `json:"reason"`
+	Author     string     `json:"author"`
+	CreatedAt  time.Time  `json:"created_at"`
+	VoidedAt   *time.Time `json:"voided_at,omitempty"`
+	VoidedBy   string     `json:"voided_by,omitempty"`
+	VoidReason string     `json:"void_reason,omitempty"`
+}
+
+type adjustmentRequest struct {
+	Member  string  `json:"member"`
+	Year    int     `json:"year"`
+	Month   int     `json:"month"`
+	Service string  `json:"service"`
+	Amount  float64 `json:"amount"`
+	Reason  string  `json:"reason"`
+}
+
+type voidAdjustmentRequest struct {
+	Reason string `json:"reason"`
+}
+
+func newAdjustmentResponse(a billing.Adjustment) AdjustmentResponse {
+	return AdjustmentResponse{
+		ID:         a.ID,
+		Member:     a.Member,
+		Month:      a.Month.Format("2006-01"),
+		Service:    a.Service,
+		Amount:     a.Amount,
+		Reason:     a.Reason,
+		Author:     a.Author,
+		CreatedAt:  a.Created,
+		VoidedAt:   a.Voided,
+		VoidedBy:   a.VoidedBy,
+		VoidReason: a.VoidReason,
+	}
+}
+
+// handleListAdjustments handles GET /api/billing/adjustments
+func handleListAdjustments(w http.ResponseWriter, r *http.Request) {
+	member, ok := memberFilter(w, r)
+	if !ok {
+		return
+	}
+
+	billingMonth, err := parseOptionalMonth(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	includeVoided := r.URL.Query().Get("include_voided") == "true"
+
+	adjustments, err := billing.ListAdjustments(member, billingMonth, includeVoided)
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to list billing adjustments: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to list billing adjustments")
+		return
+	}
+
+	data := make([]AdjustmentResponse, 0, len(adjustments))
+	total := 0.0
+	for _, a := range adjustments {
+		data = append(data, newAdjustmentResponse(a))
+		if a.Active() {
+			total += a.Amount
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total":        len(data),
+		"total_amount": total,
+		"data":         data,
+	})
+}
+
+// handleCreateAdjustment handles POST /api/billing/adjustments. A negative
+// amount is a credit, a positive amount a surcharge.
+func handleCreateAdjustment(w http.ResponseWriter, r *http.Request) {
+	var req adjustmentRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	member := sanitizeString(req.Member)
+	if member == "" || !validateMemberName(member) {
+		writeError(w, http.StatusBadRequest, "Invalid member name")
+		return
+	}
+	memberID, exists := resolveConfigMember(member)
+	if !exists {
+		writeError(w, http.StatusNotFound, "Member not found")
+		return
+	}
+
+	if req.Year < 2020 || req.Year > 2100 || req.Month < 1 || req.Month > 12 {
+		writeError(w, http.StatusBadRequest, "Invalid year or month")
+		return
+	}
+
+	service := sanitizeString(req.Service)
+	if service != "" {
+		if _, exists := cfg.GetConfig().Services[service]; !exists {
+			writeError(w, http.StatusBadRequest, "Unknown service: "+service)
+			return
+		}
+	}
+
+	if req.Amount == 0 {
+		writeError(w, http.StatusBadRequest, "amount must be non-zero")
+		return
+	}
+	if math.IsNaN(req.Amount) || math.Abs(req.Amount) > maxAdjustmentAmount {
+		writeError(w, http.StatusBadRequest, "amount out of range")
+		return
+	}
+
+	reason := sanitizeString(req.Reason)
+	if reason == "" {
+		writeError(w, http.StatusBadRequest, "reason is required")
+		return
+	}
+	if len(reason) > maxAdjustmentReason {
+		writeError(w, http.StatusBadRequest, "reason is too long")
+		return
+	}
+
+	a := billing.Adjustment{
+		Member:  memberID,
+		Month:   time.Date(req.Year, time.Month(req.Month), 1, 0, 0, 0, 0, time.UTC),
+		Service: service,
+		Amount:  req.Amount,
+		Reason:  reason,
+		Author:  principalFromRequest(r).Name,
+	}
+
+	id, err := billing.CreateAdjustment(a)
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to create billing adjustment: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create billing adjustment")
+		return
+	}
+
+	log.Log(log.Info, "[CollatorAPI] Billing adjustment %d for %s %s (%.2f) created by %s",
+		id, memberID, a.Month.Format("2006-01"), a.Amount, a.Author)
+
+	created, err := billing.GetAdjustment(id)
+	if err != nil {
+		a.ID = id
+		a.Created = time.Now().UTC()
+		created = a
+	}
+	writeJSON(w, http.StatusCreated, newAdjustmentResponse(created))
+}
+
+// handleVoidAdjustment handles POST /api/billing/adjustments/void?id=N. The
+// adjustment is kept in the ledger but no longer counts towards the bill.
+func handleVoidAdjustment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || id < 1 {
+		writeError(w, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	var req voidAdjustmentRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+	reason := sanitizeString(req.Reason)
+	if len(reason) > maxAdjustmentReason {
+		writeError(w, http.StatusBadRequest, "reason is too long")
+		return
+	}
+
+	author := principalFromRequest(r).Name
+	err = billing.VoidAdjustment(id, author, reason)
+	switch {
+	case errors.Is(err, billing.ErrAdjustmentNotFound):
+		writeError(w, http.StatusNotFound, "Billing adjustment not found")
+		return
+	case errors.Is(err, billing.ErrAdjustmentVoided):
+		writeError(w, http.StatusConflict, "Billing adjustment already voided")
+		return
+	case err != nil:
+		log.Log(log.Error, "[CollatorAPI] Failed to void billing adjustment %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Failed to void billing adjustment")
+		return
+	}
+
+	log.Log(log.Info, "[CollatorAPI] Billing adjustment %d voided by %s", id, author)
+
+	voided, err := billing.GetAdjustment(id)
+	if err != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"voided": id})
+		return
+	}
+	writeJSON(w, http.StatusOK, newAdjustmentResponse(voided))
+}
@@ -1,15 +1,23 @@
 package api
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
+	cluster "github.com/ibp-network/ibp-geodns-collator/src/cluster"
+
 	cfg "github.com/ibp-network/ibp-geodns-libs/config"
 	log "github.com/ibp-network/ibp-geodns-libs/logging"
 )
@@ -22,6 +30,10 @@ var (
 	keyPath     string
 	lastCertMod time.Time
 	lastKeyMod  time.Time
+
+	server *http.Server
+	stopCh = make(chan struct{}) // closed by Shutdown to stop background loops
+	stopMu sync.Once
 )
 
 // PDF Management
@@ -30,22 +42,25 @@ type PDFInfo struct {
 	Month      string `json:"month"`
 	MemberName string `json:"member_name,omitempty"`
 	IsOverview bool   `json:"is_overview"`
+	IsProposal bool   `json:"is_proposal,omitempty"`
 	FileName   string `json:"file_name"`
-	FilePath   string `json:"-"` // Don't expose full path in API
+	Key        string `json:"-"` // artifact key, e.g. 2024-09/2024_09-Monthly_Overview.pdf
 	FileSize   int64  `json:"file_size"`
+	SHA256     string `json:"sha256,omitempty"` // from the month's manifest
 	ModTime    string `json:"modified_time"`
 }
 
 type PDFManager struct {
 	mu       sync.RWMutex
 	pdfFiles map[string][]PDFInfo // key: "YYYY-MM"
-	baseDir  string
+	store    billing.ArtifactStore
 }
 
 var (
 	pdfManager      *PDFManager
 	pdfFilePattern  = regexp.MustCompile(`^(\d{4})_(\d{2})-IBP-Service_(.+)\.pdf$`)
 	overviewPattern = regexp.MustCompile(`^(\d{4})_(\d{2})-Monthly_Overview\.pdf$`)
+	proposalPattern = regexp.MustCompile(`^(\d{4})_(\d{2})-Treasury_Proposal\.md$`)
 	monthDirPattern = regexp.MustCompile(`^\d{4}-\d{2}$`)
 )
 
@@ -68,6 +83,25 @@ func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// methods dispatches a route to a handler per HTTP method, so each method can
+// declare its own access level.
+func methods(handlers map[string]http.HandlerFunc) http.HandlerFunc {
+	allowed := make([]string, 0, len(handlers))
+	for m := range handlers {
+		allowed = append(allowed, m)
+	}
+	sort.Strings(allowed)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if next, ok := handlers[r.Method]; ok {
+			next(w, r)
+			return
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
 func Init() {
 	log.Log(log.Info, "[CollatorAPI] Initializing API...")
 
@@ -77,36 +111,90 @@ func Init() {
 	// Initialize PDF manager
 	initPDFManager()
 
+	// Every route must declare its access level: anonymous(...) or requireScope(...)
+	warnIfNoTokens()
+
 	// Request statistics endpoints
-	mux.HandleFunc("/api/requests/country", corsMiddleware(handleRequestsByCountry))
-	mux.HandleFunc("/api/requests/asn", corsMiddleware(handleRequestsByASN))
-	mux.HandleFunc("/api/requests/service", corsMiddleware(handleRequestsByService))
-	mux.HandleFunc("/api/requests/member", corsMiddleware(handleRequestsByMember))
-	mux.HandleFunc("/api/requests/summary", corsMiddleware(handleRequestsSummary))
+	handle("/api/requests/country", corsMiddleware(requireScope(ScopeRequestsRead, handleRequestsByCountry)))
+	handle("/api/requests/asn", corsMiddleware(requireScope(ScopeRequestsRead, handleRequestsByASN)))
+	handle("/api/requests/service", corsMiddleware(requireScope(ScopeRequestsRead, handleRequestsByService)))
+	handle("/api/requests/member", corsMiddleware(requireScope(ScopeRequestsRead, handleRequestsByMember)))
+	handle("/api/requests/summary", corsMiddleware(requireScope(ScopeRequestsRead, networkWide(handleRequestsSummary))))
 
 	// Downtime endpoints
-	mux.HandleFunc("/api/downtime/events", corsMiddleware(handleDowntimeEvents))
-	mux.HandleFunc("/api/downtime/current", corsMiddleware(handleCurrentDowntime))
-	mux.HandleFunc("/api/downtime/summary", corsMiddleware(handleDowntimeSummary))
+	handle("/api/downtime/events", corsMiddleware(requireScope(ScopeDowntimeRead, handleDowntimeEvents)))
+	handle("/api/downtime/current", corsMiddleware(requireScope(ScopeDowntimeRead, networkWide(handleCurrentDowntime))))
+	handle("/api/downtime/summary", corsMiddleware(requireScope(ScopeDowntimeRead, networkWide(handleDowntimeSummary))))
 
 	// Member endpoints
-	mux.HandleFunc("/api/members", corsMiddleware(handleMembers))
-	mux.HandleFunc("/api/members/stats", corsMiddleware(handleMemberStats))
+	handle("/api/members", corsMiddleware(requireScope(ScopeMembersRead, handleMembers)))
+	handle("/api/members/stats", corsMiddleware(requireScope(ScopeMembersRead, handleMemberStats)))
 
 	// Service endpoints (NEW)
-	mux.HandleFunc("/api/services", corsMiddleware(handleServices))
-	mux.HandleFunc("/api/services/summary", corsMiddleware(handleServicesSummary))
+	handle("/api/services", corsMiddleware(requireScope(ScopeServicesRead, handleServices)))
+	handle("/api/services/summary", corsMiddleware(requireScope(ScopeServicesRead, handleServicesSummary)))
 
 	// Billing endpoints
-	mux.HandleFunc("/api/billing/breakdown", corsMiddleware(handleBillingBreakdown))
-	mux.HandleFunc("/api/billing/summary", corsMiddleware(handleBillingSummary))
+	handle("/api/billing/breakdown", corsMiddleware(requireScope(ScopeBillingRead, handleBillingBreakdown)))
+	handle("/api/billing/summary", corsMiddleware(requireScope(ScopeBillingRead, networkWide(handleBillingSummary))))
+	handle("/api/billing/forecast", corsMiddleware(requireScope(ScopeBillingRead, handleBillingForecast)))
+	handle("/api/billing/runs", corsMiddleware(requireScope(ScopeBillingRead, networkWide(handleBillingRuns))))
+
+	// What-if pricing against the live summary
+	handle("/api/billing/simulate", corsMiddleware(methods(map[string]http.HandlerFunc{
+		http.MethodPost: requireScope(ScopeBillingRead, networkWide(handleSimulateBilling)),
+	})))
+
+	// Manual billing adjustments ledger
+	handle("/api/billing/adjustments", corsMiddleware(methods(map[string]http.HandlerFunc{
+		http.MethodGet:  requireScope(ScopeBillingRead, handleListAdjustments),
+		http.MethodPost: requireScope(ScopeBillingWrite, networkWide(handleCreateAdjustment)),
+	})))
+	handle("/api/billing/adjustments/void", corsMiddleware(methods(map[string]http.HandlerFunc{
+		http.MethodPost: requireScope(ScopeBillingWrite, networkWide(handleVoidAdjustment)),
+	})))
+
+	// Exchange rates for converted totals
+	handle("/api/billing/rates", corsMiddleware(methods(map[string]http.HandlerFunc{
+		http.MethodGet:  requireScope(ScopeBillingRead, handleListRates),
+		http.MethodPost: requireScope(ScopeBillingWrite, networkWide(handleSetRate)),
+	})))
+
+	// Invoices issued with the monthly member PDFs
+	handle("/api/billing/invoices", corsMiddleware(requireScope(ScopeBillingRead, handleListInvoices)))
+	handle("/api/billing/invoices/pay", corsMiddleware(methods(map[string]http.HandlerFunc{
+		http.MethodPost: requireScope(ScopeBillingWrite, networkWide(handlePayInvoice)),
+	})))
+	handle("/api/billing/invoices/void", corsMiddleware(methods(map[string]http.HandlerFunc{
+		http.MethodPost: requireScope(ScopeBillingWrite, networkWide(handleVoidInvoice)),
+	})))
+
+	// Maintenance windows (excluded from SLA downtime)
+	handle("/api/maintenance", corsMiddleware(methods(map[string]http.HandlerFunc{
+		http.MethodGet:    requireScope(ScopeDowntimeRead, handleListMaintenance),
+		http.MethodPost:   requireScope(ScopeMaintenanceWrite, handleCreateMaintenance),
+		http.MethodDelete: requireScope(ScopeMaintenanceWrite, handleDeleteMaintenance),
+	})))
 
 	// PDF endpoints
-	mux.HandleFunc("/api/billing/pdfs", corsMiddleware(handleListPDFs))
-	mux.HandleFunc("/api/billing/pdfs/download", corsMiddleware(handleDownloadPDF))
+	handle("/api/billing/pdfs", corsMiddleware(requireScope(ScopePDFDownload, handleListPDFs)))
+	handle("/api/billing/pdfs/download", corsMiddleware(requireScope(ScopePDFDownload, handleDownloadPDF)))
+	handle("/api/billing/pdfs/draft", corsMiddleware(requireScope(ScopePDFDownload, handleDraftPDF)))
+	handle("/api/billing/pdfs/bundle", corsMiddleware(requireScope(ScopePDFDownload, handlePDFBundle)))
+	handle("/api/billing/pdfs/manifest", corsMiddleware(requireScope(ScopePDFDownload, networkWide(handlePDFManifest))))
+	handle("/api/billing/pdfs/verify", corsMiddleware(methods(map[string]http.HandlerFunc{
+		http.MethodPost: requireScope(ScopePDFDownload, handleVerifyPDF),
+	})))
+	handle("/api/billing/pdfs/generate", corsMiddleware(methods(map[string]http.HandlerFunc{
+		http.MethodPost: requireScope(ScopeAdmin, handleGeneratePDFs),
+	})))
+	handle("/api/billing/pdfs/jobs", corsMiddleware(requireScope(ScopeAdmin, handlePDFJobs)))
 
 	// Health check
-	mux.HandleFunc("/api/health", corsMiddleware(handleHealth))
+	handle("/api/health", corsMiddleware(anonymous(handleHealth)))
+
+	// Prometheus / OpenMetrics scrape endpoint
+	handle("/metrics", requireScope(ScopeMetricsRead, networkWide(handleMetrics)))
 
 	addr := c.Local.CollatorApi.ListenAddress
 	port := c.Local.CollatorApi.ListenPort
@@ -126,7 +214,7 @@ func Init() {
 		go watchCertificates()
 
 		// Create HTTPS server
-		server := &http.Server{
+		server = &http.Server{
 			Addr:    addr + ":" + port,
 			Handler: mux,
 			TLSConfig: &tls.Config{
@@ -136,21 +224,43 @@ func Init() {
 
 		log.Log(log.Info, "[CollatorAPI] Starting HTTPS API server on %s:%s", addr, port)
 		go func() {
-			if err := server.ListenAndServeTLS("", ""); err != nil {
+			if err := server.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
 				log.Log(log.Fatal, "[CollatorAPI] Failed to start HTTPS server: %v", err)
 			}
 		}()
 	} else {
 		// Start HTTP server (no SSL)
+		server = &http.Server{
+			Addr:    addr + ":" + port,
+			Handler: mux,
+		}
+
 		log.Log(log.Info, "[CollatorAPI] Starting HTTP API server on %s:%s (no SSL configured)", addr, port)
 		go func() {
-			if err := http.ListenAndServe(addr+":"+port, mux); err != nil {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 				log.Log(log.Fatal, "[CollatorAPI] Failed to start HTTP server: %v", err)
 			}
 		}()
 	}
 }
 
+// Shutdown stops accepting new connections, waits for in-flight requests to
+// finish (bounded by ctx) and stops the certificate watcher and PDF scanner.
+func Shutdown(ctx context.Context) error {
+	stopMu.Do(func() { close(stopCh) })
+
+	if server == nil {
+		return nil
+	}
+
+	log.Log(log.Info, "[CollatorAPI] Draining API server...")
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("api shutdown: %w", err)
+	}
+	log.Log(log.Info, "[CollatorAPI] API server stopped")
+	return nil
+}
+
 // loadTLSConfig loads the certificate and key from disk
 func loadTLSConfig() error {
 	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
@@ -192,7 +302,13 @@ func watchCertificates() {
 	ticker := time.NewTicker(30 * time.Second) // Check every 30 seconds
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+
 		reloadNeeded := false
 
 		// Check certificate file
@@ -269,6 +385,30 @@ func parseTimeParams(r *http.Request) (time.Time, time.Time, error) {
 	return start, end, nil
 }
 
+// parseOptionalMonth reads the optional year/month filter of a listing. Both
+// must be given or neither; without them the zero time is returned.
+func parseOptionalMonth(r *http.Request) (time.Time, error) {
+	year := r.URL.Query().Get("year")
+	month := r.URL.Query().Get("month")
+
+	if year == "" && month == "" {
+		return time.Time{}, nil
+	}
+	if year == "" || month == "" {
+		return time.Time{}, errors.New("year and month must be given together")
+	}
+	if !validateYear(year) {
+		return time.Time{}, errors.New("Invalid year format")
+	}
+	if !validateMonth(month) {
+		return time.Time{}, errors.New("Invalid month format")
+	}
+
+	y, _ := strconv.Atoi(year)
+	m, _ := strconv.Atoi(month)
+	return time.Date(y, time.Month(m), 1, 0, 0, 0, 0, time.UTC), nil
+}
+
 func handleHealth(w http.ResponseWriter, r *http.Request) {
 	// Check SSL status
 	sslEnabled := certPath != "" && keyPath != ""
@@ -283,10 +423,19 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 		tlsMutex.RUnlock()
 	}
 
+	election := cluster.GetStatus()
+
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"status":    "healthy",
 		"timestamp": time.Now().UTC(),
 		"version":   cfg.GetVersion(),
 		"ssl":       sslStatus,
+		"cluster": map[string]interface{}{
+			"node_id": election.NodeID,
+			"role":    election.Role,
+			"leader":  election.Leader,
+			"peers":   election.Peers,
+			"since":   election.Since,
+		},
 	})
 }
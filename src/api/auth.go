@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	common "github.com/ibp-network/ibp-geodns-collator/src/common"
+
+	cfg "github.com/ibp-network/ibp-geodns-libs/config"
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+// API token scopes
+const (
+	ScopeRequestsRead     = "requests:read"
+	ScopeDowntimeRead     = "downtime:read"
+	ScopeMembersRead      = "members:read"
+	ScopeServicesRead     = "services:read"
+	ScopeBillingRead      = "billing:read"
+	ScopePDFDownload      = "pdf:download"
+	ScopeMetricsRead      = "metrics:read"
+	ScopeMaintenanceWrite = "maintenance:write"
+	ScopeBillingWrite     = "billing:write"
+	ScopeAdmin            = "admin" // implies every other scope
+)
+
+// apiPrincipal describes the caller behind an authenticated request.
+type apiPrincipal struct {
+	Name         string
+	Scopes       map[string]bool
+	Member       string // config member ID for member-bound tokens, "" otherwise
+	MemberDBName string // the member's Details.Name as stored in the database
+}
+
+// HasScope reports whether the principal was granted scope (directly or via admin).
+func (p *apiPrincipal) HasScope(scope string) bool {
+	return p.Scopes[ScopeAdmin] || p.Scopes[scope]
+}
+
+// IsMemberBound reports whether the caller may only see a single member's data.
+func (p *apiPrincipal) IsMemberBound() bool {
+	return p != nil && p.Member != ""
+}
+
+// matchesMember reports whether value names the bound member, either by config
+// ID or by Details.Name. PDF file names use underscores for spaces, so both
+// spellings are accepted.
+func (p *apiPrincipal) matchesMember(value string) bool {
+	value = strings.TrimSpace(value)
+	for _, candidate := range []string{p.Member, p.MemberDBName} {
+		if strings.EqualFold(value, candidate) ||
+			strings.EqualFold(strings.ReplaceAll(value, "_", " "), strings.ReplaceAll(candidate, "_", " ")) {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// principalFromRequest returns the authenticated caller, or nil for anonymous routes.
+func principalFromRequest(r *http.Request) *apiPrincipal {
+	p, _ := r.Context().Value(principalKey{}).(*apiPrincipal)
+	return p
+}
+
+// warnIfNoTokens logs once at start-up when protected routes cannot be reached.
+func warnIfNoTokens() {
+	usable := 0
+	for _, t := range common.GetSettings().CollatorApi.Tokens {
+		if t.Token == "" {
+			log.Log(log.Warn, "[CollatorAPI] API token %q has no Token value and is ignored", t.Name)
+			continue
+		}
+		usable++
+	}
+	if usable == 0 {
+		log.Log(log.Warn, "[CollatorAPI] No API tokens configured — all protected endpoints will return 401")
+	}
+}
+
+// anonymous marks a handler as intentionally public.
+func anonymous(next http.HandlerFunc) http.HandlerFunc {
+	return next
+}
+
+// requireScope rejects requests that do not carry a valid token granting scope.
+func requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := extractToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ibp-collator"`)
+			writeError(w, http.StatusUnauthorized, "Missing API token")
+			return
+		}
+
+		principal := lookupToken(token)
+		if principal == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ibp-collator", error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, "Invalid API token")
+			return
+		}
+
+		if !principal.HasScope(scope) {
+			log.Log(log.Debug, "[CollatorAPI] Token %q denied %s (missing scope %s)", principal.Name, r.URL.Path, scope)
+			writeError(w, http.StatusForbidden, "Insufficient scope: "+scope+" required")
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	}
+}
+
+// extractToken reads the token from "Authorization: Bearer …" or X-API-Key.
+func extractToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+			return strings.TrimSpace(auth[7:])
+		}
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// lookupToken resolves a presented token against the configured tokens.
+func lookupToken(token string) *apiPrincipal {
+	var match *common.ApiToken
+	tokens := common.GetSettings().CollatorApi.Tokens
+	for i := range tokens {
+		if tokens[i].Token == "" {
+			continue
+		}
+		// compare every entry so timing does not reveal which token matched
+		if subtle.ConstantTimeCompare([]byte(tokens[i].Token), []byte(token)) == 1 && match == nil {
+			match = &tokens[i]
+		}
+	}
+	if match == nil {
+		return nil
+	}
+
+	principal := &apiPrincipal{Name: match.Name, Scopes: make(map[string]bool, len(match.Scopes))}
+	for _, s := range match.Scopes {
+		principal.Scopes[strings.ToLower(strings.TrimSpace(s))] = true
+	}
+
+	if match.Member != "" {
+		principal.Member = match.Member
+		principal.MemberDBName = match.Member
+		if member, exists := cfg.GetConfig().Members[match.Member]; exists && member.Details.Name != "" {
+			principal.MemberDBName = member.Details.Name
+		}
+	}
+	return principal
+}
+
+// networkWide rejects member-bound tokens on endpoints that aggregate data
+// across all members and cannot be narrowed to a single one.
+func networkWide(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if principalFromRequest(r).IsMemberBound() {
+			writeError(w, http.StatusForbidden, "Member-scoped tokens cannot access network-wide data")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// enforceMemberParam applies member binding to a single member filter value.
+// For unbound callers the value is returned unchanged. For member-bound callers
+// an empty value is replaced by the bound member (config ID, or Details.Name
+// when dbName is set) and any other member yields a 403. The boolean is false
+// when a response has already been written.
+func enforceMemberParam(w http.ResponseWriter, r *http.Request, value string, dbName bool) (string, bool) {
+	p := principalFromRequest(r)
+	if !p.IsMemberBound() {
+		return value, true
+	}
+
+	if value != "" && !p.matchesMember(value) {
+		writeError(w, http.StatusForbidden, "Access to other members' data is not permitted")
+		return "", false
+	}
+
+	if dbName {
+		return p.MemberDBName, true
+	}
+	return p.Member, true
+}
+
+// enforceMemberFilter applies member binding to the request-statistics filters,
+// which match against the database member name.
+func enforceMemberFilter(w http.ResponseWriter, r *http.Request, filter *RequestFilter) bool {
+	p := principalFromRequest(r)
+	if !p.IsMemberBound() {
+		return true
+	}
+
+	for _, m := range filter.Members {
+		if !p.matchesMember(m) {
+			writeError(w, http.StatusForbidden, "Access to other members' data is not permitted")
+			return false
+		}
+	}
+	filter.Members = []string{p.MemberDBName}
+	return true
+}
@@ -1,7 +1,7 @@
 package api
 
 import (
-	"database/sql"
+	"bytes"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -11,7 +11,6 @@ import (
 	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
 
 	cfg "github.com/ibp-network/ibp-geodns-libs/config"
-	data2 "github.com/ibp-network/ibp-geodns-libs/data2"
 	log "github.com/ibp-network/ibp-geodns-libs/logging"
 
 	common "github.com/ibp-network/ibp-geodns-collator/src/common"
@@ -24,20 +23,44 @@ type BillingMember struct {
 	TotalBase    float64          `json:"total_base_cost"`
 	TotalBilled  float64          `json:"total_billed"`
 	TotalCredits float64          `json:"total_credits"`
+	CreditCapped bool             `json:"credit_capped,omitempty"`
 	MeetsSLA     bool             `json:"meets_sla"`
+	Period       *BillingPeriod   `json:"partial_period,omitempty"`
+
+	Adjustments      []AdjustmentResponse `json:"adjustments,omitempty"`
+	TotalAdjustments float64              `json:"total_adjustments,omitempty"`
+}
+
+// BillingPeriod is the active part of the month for members that joined or
+// left during it.
+type BillingPeriod struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Hours float64   `json:"hours"`
 }
 
 type BillingService struct {
-	Name       string          `json:"name"`
-	BaseCost   float64         `json:"base_cost"`
-	Uptime     float64         `json:"uptime_percentage"`
-	BilledCost float64         `json:"billed_cost"`
-	Credits    float64         `json:"credits"`
-	MeetsSLA   bool            `json:"meets_sla"`
-	Downtime   []DowntimeEvent `json:"downtime_events,omitempty"`
+	Name             string          `json:"name"`
+	BaseCost         float64         `json:"base_cost"`
+	Uptime           float64         `json:"uptime_percentage"`
+	DowntimeHours    float64         `json:"downtime_hours"`
+	MaintenanceHours float64         `json:"maintenance_hours,omitempty"`
+	SLATarget        float64         `json:"sla_target"`
+	BilledCost       float64         `json:"billed_cost"`
+	Credits          float64         `json:"credits"`
+	CreditPct        float64         `json:"credit_percentage"`
+	Capped           bool            `json:"credit_capped,omitempty"`
+	MeetsSLA         bool            `json:"meets_sla"`
+	Downtime         []DowntimeEvent `json:"downtime_events,omitempty"`
 }
 
 func handleBillingBreakdown(w http.ResponseWriter, r *http.Request) {
+	// Get member filter if specified (forced for member-scoped tokens)
+	memberFilter, ok := enforceMemberParam(w, r, r.URL.Query().Get("member"), false)
+	if !ok {
+		return
+	}
+
 	// Parse month and year
 	monthStr := r.URL.Query().Get("month")
 	yearStr := r.URL.Query().Get("year")
@@ -64,19 +87,32 @@ func handleBillingBreakdown(w http.ResponseWriter, r *http.Request) {
 
 	billingMonth := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
 
-	// Get current billing summary
-	summary := billing.GetSummary()
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "json", "csv", "xlsx":
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid format")
+		return
+	}
 
-	// Calculate SLA for the month
-	sla, err := billing.CalculateSLAAdjustments(billingMonth, &summary)
+	rate, ok := requestRate(w, r, billingMonth)
+	if !ok {
+		return
+	}
+
+	// Generated months come from their snapshot, others from live config
+	snap, err := billing.MonthSnapshot(billingMonth)
 	if err != nil {
 		log.Log(log.Error, "[CollatorAPI] Failed to calculate SLA: %v", err)
 		writeError(w, http.StatusInternalServerError, "Failed to calculate SLA")
 		return
 	}
 
-	// Get member filter if specified
-	memberFilter := r.URL.Query().Get("member")
+	if format == "csv" || format == "xlsx" {
+		writeBreakdownExport(w, billing.NewExport(snap, memberFilter), format)
+		return
+	}
+	summary, sla := snap.Summary, snap.SLA
 
 	var billingMembers []BillingMember
 
@@ -85,32 +121,43 @@ func handleBillingBreakdown(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
+		// Members removed from config since are still part of a snapshot
 		c := cfg.GetConfig()
-		memberConfig, exists := c.Members[memberName]
-		if !exists {
+		_, exists := c.Members[memberName]
+		if !exists && !snap.Persisted {
 			continue
 		}
 
 		billingMember := BillingMember{
 			Name:     memberName,
-			Level:    memberConfig.Membership.Level,
+			Level:    snap.MemberLevel(memberName),
 			Services: []BillingService{},
 		}
 
+		if p, partial := snap.MemberPeriod(memberName); partial {
+			billingMember.Period = &BillingPeriod{Start: p.Start, End: p.End, Hours: p.Hours()}
+		}
+
 		memberMeetsSLA := true
+		credits := snap.MemberCredits(memberName)
+		billingMember.CreditCapped = credits.Capped
 
 		for serviceName, baseCost := range memberCost.ServiceCosts {
-			breakdown := getSLABreakdown(sla, memberName, serviceName)
-			billedCost := baseCost * (breakdown.Uptime / 100.0)
-			credits := baseCost - billedCost
+			breakdown := sla.Breakdown(memberName, serviceName)
+			credit := credits.Services[serviceName]
 
 			service := BillingService{
-				Name:       serviceName,
-				BaseCost:   baseCost,
-				Uptime:     breakdown.Uptime,
-				BilledCost: billedCost,
-				Credits:    credits,
-				MeetsSLA:   breakdown.MeetsSLA,
+				Name:             serviceName,
+				BaseCost:         baseCost,
+				Uptime:           breakdown.Uptime,
+				DowntimeHours:    breakdown.HoursDown,
+				MaintenanceHours: breakdown.HoursExcluded,
+				SLATarget:        breakdown.SLAThreshold,
+				BilledCost:       credit.Billed,
+				Credits:          credit.Credit,
+				CreditPct:        credit.CreditPercent,
+				Capped:           credit.Capped,
+				MeetsSLA:         breakdown.MeetsSLA,
 			}
 
 			if !breakdown.MeetsSLA {
@@ -119,27 +166,40 @@ func handleBillingBreakdown(w http.ResponseWriter, r *http.Request) {
 
 			// Get downtime events for this service if requested
 			if r.URL.Query().Get("include_downtime") == "true" {
-				// Use the member's Details.Name for database lookup
-				dbMemberName := memberName
-				if memberConfig.Details.Name != "" {
-					dbMemberName = memberConfig.Details.Name
-				}
-				service.Downtime = getServiceDowntimeForAPI(dbMemberName, serviceName, billingMonth)
+				service.Downtime = getServiceDowntimeForAPI(snap, memberName, serviceName)
 			}
 
 			billingMember.Services = append(billingMember.Services, service)
 			billingMember.TotalBase += baseCost
-			billingMember.TotalBilled += billedCost
-			billingMember.TotalCredits += credits
+			billingMember.TotalBilled += credit.Billed
+			billingMember.TotalCredits += credit.Credit
 		}
 
+		// Manual adjustments apply on top of the SLA-adjusted bill
+		adjustments, adjustmentTotal := snap.MemberAdjustments(memberName)
+		for _, a := range adjustments {
+			billingMember.Adjustments = append(billingMember.Adjustments, newAdjustmentResponse(a))
+		}
+		billingMember.TotalAdjustments = adjustmentTotal
+		billingMember.TotalBilled += adjustmentTotal
+
 		billingMember.MeetsSLA = memberMeetsSLA
+		convertMember(&billingMember, rate)
 		billingMembers = append(billingMembers, billingMember)
 	}
 
+	source := "live"
+	if snap.Persisted {
+		source = "snapshot"
+	}
+
 	result := map[string]interface{}{
-		"month":   billingMonth.Format("2006-01"),
-		"members": billingMembers,
+		"month":         billingMonth.Format("2006-01"),
+		"source":        source,
+		"computed_at":   snap.Created,
+		"currency":      rate.Currency,
+		"exchange_rate": newRateResponse(rate),
+		"members":       billingMembers,
 		"total_base_cost": func() float64 {
 			var total float64
 			for _, m := range billingMembers {
@@ -161,6 +221,13 @@ func handleBillingBreakdown(w http.ResponseWriter, r *http.Request) {
 			}
 			return total
 		}(),
+		"total_adjustments": func() float64 {
+			var total float64
+			for _, m := range billingMembers {
+				total += m.TotalAdjustments
+			}
+			return total
+		}(),
 	}
 
 	writeJSON(w, http.StatusOK, result)
@@ -186,21 +253,25 @@ func handleBillingSummary(w http.ResponseWriter, r *http.Request) {
 	// Get SLA summary for current month
 	now := time.Now().UTC()
 	currentMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	rate, ok := requestRate(w, r, currentMonth)
+	if !ok {
+		return
+	}
+
 	sla, _ := billing.CalculateSLAAdjustments(currentMonth, &summary)
 
 	var totalCredits float64
 	var slaViolations int
 
 	for memberName, memberServices := range sla {
+		memberCost, exists := summary.Members[memberName]
+		if !exists {
+			continue
+		}
+		totalCredits += billing.CalculateMemberCredits(memberName, memberCost.ServiceCosts, sla).TotalCredit
 		for serviceName, breakdown := range memberServices {
-			if memberCost, exists := summary.Members[memberName]; exists {
-				if baseCost, exists := memberCost.ServiceCosts[serviceName]; exists {
-					credit := baseCost * (1 - breakdown.Uptime/100.0)
-					totalCredits += credit
-					if !breakdown.MeetsSLA {
-						slaViolations++
-					}
-				}
+			if _, exists := memberCost.ServiceCosts[serviceName]; exists && !breakdown.MeetsSLA {
+				slaViolations++
 			}
 		}
 	}
@@ -210,8 +281,10 @@ func handleBillingSummary(w http.ResponseWriter, r *http.Request) {
 		"total_members":                int(totalMembers),
 		"total_services":               int(totalServices),
 		"unique_services":              len(serviceCount),
-		"total_base_cost_monthly":      totalBaseCost,
-		"current_month_credits":        totalCredits,
+		"total_base_cost_monthly":      rate.Convert(totalBaseCost),
+		"current_month_credits":        rate.Convert(totalCredits),
+		"currency":                     rate.Currency,
+		"exchange_rate":                newRateResponse(rate),
 		"current_month_sla_violations": slaViolations,
 		"service_distribution":         serviceCount,
 	}
@@ -219,237 +292,67 @@ func handleBillingSummary(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, result)
 }
 
-func getSLABreakdown(sla billing.SLASummary, member, service string) billing.SLABreakdown {
-	if memberServices, ok := sla[member]; ok {
-		if breakdown, ok := memberServices[service]; ok {
-			return breakdown
+// writeBreakdownExport streams the breakdown as a CSV or XLSX attachment.
+// Amounts are always in USD.
+func writeBreakdownExport(w http.ResponseWriter, export *billing.Export, format string) {
+	write, contentType := export.WriteCSV, "text/csv; charset=utf-8"
+	if format == "xlsx" {
+		if err := export.LoadDowntime(); err != nil {
+			log.Log(log.Error, "[CollatorAPI] Failed to load downtime for export: %v", err)
+			writeError(w, http.StatusInternalServerError, "Failed to load downtime events")
+			return
 		}
+		write, contentType = export.WriteXLSX, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
 	}
-	// Return default if not found
-	return billing.SLABreakdown{
-		HoursTotal:   730,
-		HoursDown:    0,
-		HoursUp:      730,
-		Uptime:       100.0,
-		SLAThreshold: billing.DefaultSLAPercentage,
-		SLAHours:     730 * (billing.DefaultSLAPercentage / 100.0),
-		MeetsSLA:     true,
-	}
-}
 
-func getServiceDowntimeForAPI(memberName, serviceName string, month time.Time) []DowntimeEvent {
-	events := []DowntimeEvent{}
-	if data2.DB == nil {
-		return events
-	}
-
-	// Map service to domains
-	c := cfg.GetConfig()
-	domains := []string{}
-	if svc, exists := c.Services[serviceName]; exists {
-		for _, provider := range svc.Providers {
-			for _, rpcUrl := range provider.RpcUrls {
-				if domain := extractDomainFromURL(rpcUrl); domain != "" {
-					domains = append(domains, domain)
-				}
-			}
-		}
-	}
-
-	startTime := month
-	endTime := month.AddDate(0, 1, 0).Add(-time.Nanosecond)
-
-	// First get site-level events that affect all services
-	siteQuery := `
-		SELECT  
-			id,
-			check_type,
-			check_name,
-			COALESCE(domain_name, '') as domain_name,
-			COALESCE(endpoint, '') as endpoint,
-			start_time,
-			end_time,
-			COALESCE(error, '') as error,
-			is_ipv6
-		FROM member_events
-		WHERE member_name = ?
-		AND check_type IN ('site', '1')
-		AND status = 0
-		AND (
-			(start_time < ? AND (end_time IS NULL OR end_time > ?))
-			OR
-			(start_time >= ? AND start_time < ?)
-		)
-		ORDER BY start_time DESC
-	`
-
-	rows, err := data2.DB.Query(siteQuery, memberName, endTime, startTime, startTime, endTime)
-	if err != nil {
-		log.Log(log.Error, "[CollatorAPI] Failed to query site downtime events: %v", err)
-	} else {
-		defer rows.Close()
-		for rows.Next() {
-			var event DowntimeEvent
-			var domainName, endpoint, errorText sql.NullString
-			var isIPv6 int
-			var endTime sql.NullTime
-
-			err := rows.Scan(
-				&event.ID,
-				&event.CheckType,
-				&event.CheckName,
-				&domainName,
-				&endpoint,
-				&event.StartTime,
-				&endTime,
-				&errorText,
-				&isIPv6,
-			)
-			if err != nil {
-				log.Log(log.Error, "[CollatorAPI] Failed to scan site downtime event: %v", err)
-				continue
-			}
-
-			event.MemberName = memberName
-			event.CheckType = common.NormalizeCheckType(event.CheckType)
-			if domainName.Valid {
-				event.DomainName = domainName.String
-			}
-			if endpoint.Valid {
-				event.Endpoint = endpoint.String
-			}
-			if errorText.Valid {
-				event.Error = errorText.String
-			}
-
-			// Adjust times to be within month
-			if event.StartTime.Before(startTime) {
-				event.StartTime = startTime
-			}
-			if endTime.Valid {
-				event.EndTime = &endTime.Time
-				if event.EndTime.After(month.AddDate(0, 1, 0).Add(-time.Nanosecond)) {
-					t := month.AddDate(0, 1, 0).Add(-time.Nanosecond)
-					event.EndTime = &t
-				}
-				event.Status = "resolved"
-			} else {
-				t := month.AddDate(0, 1, 0).Add(-time.Nanosecond)
-				event.EndTime = &t
-				event.Status = "ongoing"
-			}
-
-			// Calculate duration
-			duration := event.EndTime.Sub(event.StartTime)
-			event.Duration = formatDuration(duration)
-
-			events = append(events, event)
-		}
+	// render first so a failure can still be reported as JSON
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to write %s export: %v", format, err)
+		writeError(w, http.StatusInternalServerError, "Failed to export billing breakdown")
+		return
 	}
 
-	// Then get service-specific events
-	if len(domains) == 0 {
-		return events
-	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", billing.ExportFileName(export.Month, format)))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
 
-	// Build parameterized query with proper placeholders
-	placeholders := make([]string, len(domains))
-	args := make([]interface{}, 0, len(domains)+5)
-	args = append(args, memberName)
-	for i, domain := range domains {
-		placeholders[i] = "?"
-		args = append(args, domain)
-	}
-	args = append(args, endTime, startTime, startTime, endTime)
-
-	// Safe query construction with parameterized inputs
-	query := fmt.Sprintf(`
-		SELECT  
-			id,
-			check_type,
-			check_name,
-			COALESCE(domain_name, '') as domain_name,
-			COALESCE(endpoint, '') as endpoint,
-			start_time,
-			end_time,
-			COALESCE(error, '') as error,
-			is_ipv6
-		FROM member_events
-		WHERE member_name = ?
-		AND status = 0
-		AND domain_name IN (%s)
-		AND (
-			(start_time < ? AND (end_time IS NULL OR end_time > ?))
-			OR
-			(start_time >= ? AND start_time < ?)
-		)
-		ORDER BY start_time DESC`, strings.Join(placeholders, ","))
-
-	rows, err = data2.DB.Query(query, args...)
+// getServiceDowntimeForAPI lists the outages the SLA engine counted for one
+// member service, so the events match the breakdown's downtime hours.
+func getServiceDowntimeForAPI(snap *billing.Snapshot, memberName, serviceName string) []DowntimeEvent {
+	events := []DowntimeEvent{}
+	outages, err := snap.ServiceOutages(memberName, serviceName)
 	if err != nil {
-		log.Log(log.Error, "[CollatorAPI] Failed to query service downtime events: %v", err)
+		log.Log(log.Error, "[CollatorAPI] Failed to load downtime events for %s/%s: %v", memberName, serviceName, err)
 		return events
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var event DowntimeEvent
-		var domainName, endpoint, errorText sql.NullString
-		var isIPv6 int
-		var endTime sql.NullTime
-
-		err := rows.Scan(
-			&event.ID,
-			&event.CheckType,
-			&event.CheckName,
-			&domainName,
-			&endpoint,
-			&event.StartTime,
-			&endTime,
-			&errorText,
-			&isIPv6,
-		)
-		if err != nil {
-			log.Log(log.Error, "[CollatorAPI] Failed to scan downtime event: %v", err)
-			continue
-		}
 
-		event.MemberName = memberName
-		event.CheckType = common.NormalizeCheckType(event.CheckType)
-		if domainName.Valid {
-			event.DomainName = domainName.String
+	for _, o := range outages {
+		to := o.To
+		event := DowntimeEvent{
+			ID:         o.ID,
+			MemberName: memberName,
+			CheckType:  common.NormalizeCheckType(o.CheckType),
+			CheckName:  o.CheckName,
+			DomainName: o.Domain,
+			Endpoint:   o.Endpoint,
+			StartTime:  o.From,
+			EndTime:    &to,
+			Duration:   formatDuration(to.Sub(o.From)),
+			Error:      o.Error,
+			IsIPv6:     o.IPv6,
+			Status:     "resolved",
+
+			MaintenanceHours: o.HoursExcluded,
 		}
-		if endpoint.Valid {
-			event.Endpoint = endpoint.String
-		}
-		if errorText.Valid {
-			event.Error = errorText.String
-		}
-
-		// Adjust times to be within month
-		if event.StartTime.Before(startTime) {
-			event.StartTime = startTime
-		}
-		if endTime.Valid {
-			event.EndTime = &endTime.Time
-			if event.EndTime.After(month.AddDate(0, 1, 0).Add(-time.Nanosecond)) {
-				t := month.AddDate(0, 1, 0).Add(-time.Nanosecond)
-				event.EndTime = &t
-			}
-			event.Status = "resolved"
-		} else {
-			t := month.AddDate(0, 1, 0).Add(-time.Nanosecond)
-			event.EndTime = &t
+		if o.End == nil {
 			event.Status = "ongoing"
 		}
-
-		// Calculate duration
-		duration := event.EndTime.Sub(event.StartTime)
-		event.Duration = formatDuration(duration)
-
 		events = append(events, event)
 	}
-
 	return events
 }
 
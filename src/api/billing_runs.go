@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
+
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+const (
+	defaultRunsLimit = 50
+	maxRunsLimit     = 500
+)
+
+type BillingRunResponse struct {
+	ID         int64      `json:"id"`
+	Month      string     `json:"month"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Duration   string     `json:"duration,omitempty"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Files      []string   `json:"files"`
+}
+
+// handleBillingRuns handles GET /api/billing/runs
+func handleBillingRuns(w http.ResponseWriter, r *http.Request) {
+	billingMonth, err := parseOptionalMonth(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit := defaultRunsLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 1 || n > maxRunsLimit {
+			writeError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	runs, err := billing.ListRuns(billingMonth, limit)
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to list billing runs: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to list billing runs")
+		return
+	}
+
+	data := make([]BillingRunResponse, 0, len(runs))
+	for _, run := range runs {
+		resp := BillingRunResponse{
+			ID:         run.ID,
+			Month:      run.Month.Format("2006-01"),
+			StartedAt:  run.Started,
+			FinishedAt: run.Finished,
+			Status:     run.Status,
+			Error:      run.Error,
+			Files:      run.Files,
+		}
+		if run.Finished != nil {
+			resp.Duration = run.Finished.Sub(run.Started).Round(time.Second).String()
+		}
+		data = append(data, resp)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total": len(data),
+		"data":  data,
+	})
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
+
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+type ForecastService struct {
+	Name            string  `json:"name"`
+	Risk            string  `json:"risk"`
+	SLATarget       float64 `json:"sla_target"`
+	HoursTotal      float64 `json:"hours_total"`
+	HoursElapsed    float64 `json:"hours_elapsed"`
+	HoursRemaining  float64 `json:"hours_remaining"`
+	DowntimeHours   float64 `json:"downtime_hours"`
+	AllowedDowntime float64 `json:"allowed_downtime_hours"`
+	BudgetRemaining float64 `json:"remaining_budget_hours"`
+	ProjectedDown   float64 `json:"projected_downtime_hours"`
+	UptimeToDate    float64 `json:"uptime_to_date_percentage"`
+	ProjectedUptime float64 `json:"projected_uptime_percentage"`
+	BaseCost        float64 `json:"base_cost"`
+	CreditsToDate   float64 `json:"credits_to_date"`
+	ProjectedCredit float64 `json:"projected_credits"`
+	ProjectedBilled float64 `json:"projected_billed"`
+}
+
+type ForecastMember struct {
+	Name            string            `json:"name"`
+	Risk            string            `json:"risk"`
+	BaseCost        float64           `json:"base_cost"`
+	CreditsToDate   float64           `json:"credits_to_date"`
+	ProjectedCredit float64           `json:"projected_credits"`
+	Adjustments     float64           `json:"adjustments,omitempty"`
+	ProjectedBilled float64           `json:"projected_billed"`
+	Services        []ForecastService `json:"services"`
+}
+
+// handleBillingForecast handles GET /api/billing/forecast. It projects the
+// current month to its end from the downtime recorded so far.
+func handleBillingForecast(w http.ResponseWriter, r *http.Request) {
+	memberFilter, ok := enforceMemberParam(w, r, r.URL.Query().Get("member"), false)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	rate, ok := requestRate(w, r, now)
+	if !ok {
+		return
+	}
+
+	forecast, err := billing.ForecastMonth(now)
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to forecast billing: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to forecast billing")
+		return
+	}
+
+	members := []ForecastMember{}
+	var totalBase, totalToDate, totalCredits, totalBilled float64
+	risks := map[string]int{billing.RiskAtRisk: 0, billing.RiskBreached: 0}
+
+	for memberName, mf := range forecast.Members {
+		if memberFilter != "" && memberFilter != memberName {
+			continue
+		}
+
+		member := ForecastMember{
+			Name:            memberName,
+			Risk:            mf.Risk,
+			BaseCost:        rate.Convert(mf.BaseCost),
+			CreditsToDate:   rate.Convert(mf.CreditsToDate),
+			ProjectedCredit: rate.Convert(mf.ProjectedCredit),
+			Adjustments:     rate.Convert(mf.Adjustments),
+			ProjectedBilled: rate.Convert(mf.ProjectedBilled),
+			Services:        []ForecastService{},
+		}
+
+		for serviceName, sf := range mf.Services {
+			member.Services = append(member.Services, ForecastService{
+				Name:            serviceName,
+				Risk:            sf.Risk,
+				SLATarget:       sf.SLA.SLAThreshold,
+				HoursTotal:      sf.SLA.HoursTotal,
+				HoursElapsed:    sf.HoursElapsed,
+				HoursRemaining:  sf.HoursRemaining,
+				DowntimeHours:   sf.SLA.HoursDown,
+				AllowedDowntime: sf.AllowedDown,
+				BudgetRemaining: sf.BudgetRemaining,
+				ProjectedDown:   sf.ProjectedDown,
+				UptimeToDate:    uptimeToDate(sf),
+				ProjectedUptime: sf.ProjectedUptime,
+				BaseCost:        rate.Convert(sf.BaseCost),
+				CreditsToDate:   rate.Convert(sf.CreditsToDate),
+				ProjectedCredit: rate.Convert(sf.ProjectedCredit),
+				ProjectedBilled: rate.Convert(sf.ProjectedBilled),
+			})
+			if _, counted := risks[sf.Risk]; counted {
+				risks[sf.Risk]++
+			}
+		}
+		sort.Slice(member.Services, func(i, j int) bool { return member.Services[i].Name < member.Services[j].Name })
+
+		totalBase += member.BaseCost
+		totalToDate += member.CreditsToDate
+		totalCredits += member.ProjectedCredit
+		totalBilled += member.ProjectedBilled
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"month":                   forecast.Month.Format("2006-01"),
+		"generated_at":            forecast.Generated,
+		"currency":                rate.Currency,
+		"exchange_rate":           newRateResponse(rate),
+		"members":                 members,
+		"total_base_cost":         totalBase,
+		"total_credits_to_date":   totalToDate,
+		"projected_total_credits": totalCredits,
+		"projected_total_billed":  totalBilled,
+		"services_at_risk":        risks[billing.RiskAtRisk],
+		"services_breached":       risks[billing.RiskBreached],
+	})
+}
+
+// uptimeToDate is the uptime over the elapsed part of the period only.
+func uptimeToDate(sf billing.ServiceForecast) float64 {
+	if sf.HoursElapsed <= 0 {
+		return 100.0
+	}
+	up := (sf.HoursElapsed - sf.SLA.HoursDown) / sf.HoursElapsed * 100.0
+	if up < 0 {
+		return 0
+	}
+	return up
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
+
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+const maxInvoiceReference = 255
+
+type InvoiceResponse struct {
+	ID          int64      `json:"id"`
+	Number      string     `json:"invoice_number"`
+	Member      string     `json:"member"`
+	Month       string     `json:"month"`
+	IssueDate   string     `json:"issue_date"`
+	DueDate     string     `json:"due_date"`
+	BaseTotal   float64    `json:"total_base_cost"`
+	Credits     float64    `json:"total_credits"`
+	Adjustments float64    `json:"total_adjustments"`
+	AmountDue   float64    `json:"amount_due"`
+	Status      string     `json:"status"`
+	Overdue     bool       `json:"overdue,omitempty"`
+	PaidAt      *time.Time `json:"paid_at,omitempty"`
+	Reference   string     `json:"reference,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type invoiceStatusRequest struct {
+	PaidAt    time.Time `json:"paid_at"`
+	Reference string    `json:"reference"`
+	Reason    string    `json:"reason"`
+}
+
+func newInvoiceResponse(inv billing.Invoice) InvoiceResponse {
+	return InvoiceResponse{
+		ID:          inv.ID,
+		Number:      inv.Number,
+		Member:      inv.Member,
+		Month:       inv.Month.Format("2006-01"),
+		IssueDate:   inv.IssueDate.Format("2006-01-02"),
+		DueDate:     inv.DueDate.Format("2006-01-02"),
+		BaseTotal:   inv.BaseTotal,
+		Credits:     inv.Credits,
+		Adjustments: inv.Adjustments,
+		AmountDue:   inv.AmountDue,
+		Status:      inv.Status,
+		Overdue:     inv.Status == billing.InvoiceIssued && time.Now().UTC().After(inv.DueDate.Add(24*time.Hour)),
+		PaidAt:      inv.PaidAt,
+		Reference:   inv.Reference,
+		CreatedAt:   inv.Created,
+	}
+}
+
+// handleListInvoices handles GET /api/billing/invoices
+func handleListInvoices(w http.ResponseWriter, r *http.Request) {
+	member, ok := memberFilter(w, r)
+	if !ok {
+		return
+	}
+
+	billingMonth, err := parseOptionalMonth(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", billing.InvoiceIssued, billing.InvoicePaid, billing.InvoiceVoid:
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	invoices, err := billing.ListInvoices(member, billingMonth, status)
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to list invoices: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to list invoices")
+		return
+	}
+
+	data := make([]InvoiceResponse, 0, len(invoices))
+	outstanding := 0.0
+	for _, inv := range invoices {
+		data = append(data, newInvoiceResponse(inv))
+		if inv.Status == billing.InvoiceIssued {
+			outstanding += inv.AmountDue
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total":       len(data),
+		"outstanding": outstanding,
+		"data":        data,
+	})
+}
+
+// handlePayInvoice handles POST /api/billing/invoices/pay?id=N
+func handlePayInvoice(w http.ResponseWriter, r *http.Request) {
+	updateInvoiceStatus(w, r, billing.InvoicePaid)
+}
+
+// handleVoidInvoice handles POST /api/billing/invoices/void?id=N. The next
+// billing generation of the month issues a replacement under a new number.
+func handleVoidInvoice(w http.ResponseWriter, r *http.Request) {
+	updateInvoiceStatus(w, r, billing.InvoiceVoid)
+}
+
+func updateInvoiceStatus(w http.ResponseWriter, r *http.Request, status string) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || id < 1 {
+		writeError(w, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	var req invoiceStatusRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	reference := sanitizeString(req.Reference)
+	if status == billing.InvoiceVoid {
+		reference = sanitizeString(req.Reason)
+	}
+	if len(reference) > maxInvoiceReference {
+		writeError(w, http.StatusBadRequest, "reference is too long")
+		return
+	}
+
+	if status == billing.InvoicePaid {
+		paidAt := req.PaidAt
+		if paidAt.IsZero() {
+			paidAt = time.Now().UTC()
+		}
+		if paidAt.After(time.Now().Add(time.Minute)) {
+			writeError(w, http.StatusBadRequest, "paid_at must not be in the future")
+			return
+		}
+		err = billing.MarkInvoicePaid(id, paidAt.Truncate(time.Second), reference)
+	} else {
+		err = billing.VoidInvoice(id, reference)
+	}
+
+	switch {
+	case errors.Is(err, billing.ErrInvoiceNotFound):
+		writeError(w, http.StatusNotFound, "Invoice not found")
+		return
+	case errors.Is(err, billing.ErrInvoiceNotIssued):
+		writeError(w, http.StatusConflict, "Invoice is already paid or void")
+		return
+	case err != nil:
+		log.Log(log.Error, "[CollatorAPI] Failed to update invoice %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Failed to update invoice")
+		return
+	}
+
+	log.Log(log.Info, "[CollatorAPI] Invoice %d marked %s by %s", id, status, principalFromRequest(r).Name)
+
+	inv, err := billing.GetInvoice(id)
+	if err != nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"id": id, "status": status})
+		return
+	}
+	writeJSON(w, http.StatusOK, newInvoiceResponse(inv))
+}
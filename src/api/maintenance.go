@@ -0,0 +1,290 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
+	common "github.com/ibp-network/ibp-geodns-collator/src/common"
+
+	cfg "github.com/ibp-network/ibp-geodns-libs/config"
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+const maxMaintenanceReason = 1000
+
+type MaintenanceResponse struct {
+	ID        int64     `json:"id"`
+	Member    string    `json:"member"`
+	Services  []string  `json:"services"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Duration  string    `json:"duration"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type maintenanceRequest struct {
+	Member    string    `json:"member"`
+	Services  []string  `json:"services"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Reason    string    `json:"reason"`
+}
+
+func newMaintenanceResponse(mw billing.MaintenanceWindow) MaintenanceResponse {
+	return MaintenanceResponse{
+		ID:        mw.ID,
+		Member:    mw.Member,
+		Services:  mw.Services,
+		StartTime: mw.Start,
+		EndTime:   mw.End,
+		Duration:  formatDuration(mw.End.Sub(mw.Start)),
+		Reason:    mw.Reason,
+		CreatedAt: mw.Created,
+	}
+}
+
+// resolveConfigMember maps a config member ID or Details.Name to the config ID.
+func resolveConfigMember(name string) (string, bool) {
+	c := cfg.GetConfig()
+	if _, ok := c.Members[name]; ok {
+		return name, true
+	}
+	for id, member := range c.Members {
+		if strings.EqualFold(id, name) || (member.Details.Name != "" && strings.EqualFold(member.Details.Name, name)) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// memberFilter reads the optional member filter of a listing: validated,
+// bound to the caller's member for member-scoped tokens and resolved to the
+// config ID. The boolean is false when a response has already been written.
+func memberFilter(w http.ResponseWriter, r *http.Request) (string, bool) {
+	member := sanitizeString(r.URL.Query().Get("member"))
+	if member != "" && !validateMemberName(member) {
+		writeError(w, http.StatusBadRequest, "Invalid member name")
+		return "", false
+	}
+
+	member, ok := enforceMemberParam(w, r, member, false)
+	if !ok || member == "" {
+		return member, ok
+	}
+	id, exists := resolveConfigMember(member)
+	if !exists {
+		writeError(w, http.StatusNotFound, "Member not found")
+		return "", false
+	}
+	return id, true
+}
+
+// handleListMaintenance handles GET /api/maintenance
+func handleListMaintenance(w http.ResponseWriter, r *http.Request) {
+	member, ok := memberFilter(w, r)
+	if !ok {
+		return
+	}
+
+	startStr := r.URL.Query().Get("start")
+	endStr := r.URL.Query().Get("end")
+	if !validateDate(startStr) || !validateDate(endStr) {
+		writeError(w, http.StatusBadRequest, "Invalid date format")
+		return
+	}
+
+	var from, to time.Time
+	if startStr != "" {
+		from, _ = time.Parse("2006-01-02", startStr)
+	}
+	if endStr != "" {
+		to, _ = time.Parse("2006-01-02", endStr)
+		to = to.Add(24 * time.Hour)
+	}
+
+	windows, err := billing.ListMaintenance(member, from, to)
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to list maintenance windows: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to list maintenance windows")
+		return
+	}
+
+	data := make([]MaintenanceResponse, 0, len(windows))
+	for _, mw := range windows {
+		data = append(data, newMaintenanceResponse(mw))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total": len(data),
+		"data":  data,
+	})
+}
+
+// handleCreateMaintenance handles POST /api/maintenance. Member-scoped tokens
+// may only announce maintenance for their own member, and only in advance.
+func handleCreateMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req maintenanceRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	member := sanitizeString(req.Member)
+	if member == "" && !principalFromRequest(r).IsMemberBound() {
+		writeError(w, http.StatusBadRequest, "member is required")
+		return
+	}
+	if !validateMemberName(member) {
+		writeError(w, http.StatusBadRequest, "Invalid member name")
+		return
+	}
+
+	member, ok := enforceMemberParam(w, r, member, false)
+	if !ok {
+		return
+	}
+	memberID, exists := resolveConfigMember(member)
+	if !exists {
+		writeError(w, http.StatusNotFound, "Member not found")
+		return
+	}
+
+	c := cfg.GetConfig()
+	services := []string{}
+	for _, s := range req.Services {
+		s = sanitizeString(s)
+		if _, exists := c.Services[s]; !exists {
+			writeError(w, http.StatusBadRequest, "Unknown service: "+s)
+			return
+		}
+		services = append(services, s)
+	}
+
+	if req.StartTime.IsZero() || req.EndTime.IsZero() {
+		writeError(w, http.StatusBadRequest, "start_time and end_time are required")
+		return
+	}
+	if !req.EndTime.After(req.StartTime) {
+		writeError(w, http.StatusBadRequest, "end_time must be after start_time")
+		return
+	}
+	if principalFromRequest(r).IsMemberBound() && !req.StartTime.After(time.Now()) {
+		writeError(w, http.StatusForbidden, "Maintenance must be announced before it starts")
+		return
+	}
+
+	reason := sanitizeString(req.Reason)
+	if len(reason) > maxMaintenanceReason {
+		writeError(w, http.StatusBadRequest, "reason is too long")
+		return
+	}
+
+	mw := billing.MaintenanceWindow{
+		Member:   memberID,
+		Services: services,
+		Start:    req.StartTime.UTC().Truncate(time.Second),
+		End:      req.EndTime.UTC().Truncate(time.Second),
+		Reason:   reason,
+	}
+
+	if principalFromRequest(r).IsMemberBound() && !checkMemberMaintenanceLimits(w, mw) {
+		return
+	}
+
+	id, err := billing.CreateMaintenance(mw)
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to create maintenance window: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create maintenance window")
+		return
+	}
+
+	log.Log(log.Info, "[CollatorAPI] Maintenance window %d for %s (%s – %s) created by %s",
+		id, memberID, mw.Start.Format(time.RFC3339), mw.End.Format(time.RFC3339), principalFromRequest(r).Name)
+
+	created, err := billing.GetMaintenance(id)
+	if err != nil {
+		mw.ID = id
+		mw.Created = time.Now().UTC()
+		created = mw
+	}
+	writeJSON(w, http.StatusCreated, newMaintenanceResponse(created))
+}
+
+// checkMemberMaintenanceLimits enforces the Maintenance settings on a window
+// announced with a member-bound token: its length, and the hours the member's
+// windows cover in every month it touches. It writes the error response and
+// returns false when a limit is exceeded.
+func checkMemberMaintenanceLimits(w http.ResponseWriter, mw billing.MaintenanceWindow) bool {
+	limits := common.GetSettings().Maintenance
+
+	if maxDuration := limits.MemberMaxDuration(); mw.End.Sub(mw.Start) > maxDuration {
+		writeError(w, http.StatusForbidden, fmt.Sprintf(
+			"Maintenance windows longer than %.0f hours must be created by an administrator", maxDuration.Hours()))
+		return false
+	}
+
+	budget := limits.MemberMonthlyBudget().Hours()
+	month := time.Date(mw.Start.Year(), mw.Start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for ; month.Before(mw.End); month = month.AddDate(0, 1, 0) {
+		hours, err := billing.MemberMaintenanceHours(mw.Member, month, mw)
+		if err != nil {
+			log.Log(log.Error, "[CollatorAPI] Failed to load maintenance windows of %s: %v", mw.Member, err)
+			writeError(w, http.StatusInternalServerError, "Failed to check the maintenance budget")
+			return false
+		}
+		if hours > budget {
+			writeError(w, http.StatusForbidden, fmt.Sprintf(
+				"Maintenance in %s would exceed the monthly budget of %.0f hours", month.Format("January 2006"), budget))
+			return false
+		}
+	}
+	return true
+}
+
+// handleDeleteMaintenance handles DELETE /api/maintenance?id=N. Member-scoped
+// tokens may only withdraw their own windows that have not started yet.
+func handleDeleteMaintenance(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || id < 1 {
+		writeError(w, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	mw, err := billing.GetMaintenance(id)
+	if errors.Is(err, billing.ErrMaintenanceNotFound) {
+		writeError(w, http.StatusNotFound, "Maintenance window not found")
+		return
+	}
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to load maintenance window %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Failed to load maintenance window")
+		return
+	}
+
+	if p := principalFromRequest(r); p.IsMemberBound() {
+		if !p.matchesMember(mw.Member) {
+			writeError(w, http.StatusNotFound, "Maintenance window not found")
+			return
+		}
+		if !mw.Start.After(time.Now()) {
+			writeError(w, http.StatusForbidden, "Maintenance that has started can no longer be withdrawn")
+			return
+		}
+	}
+
+	if err := billing.DeleteMaintenance(id); err != nil && !errors.Is(err, billing.ErrMaintenanceNotFound) {
+		log.Log(log.Error, "[CollatorAPI] Failed to delete maintenance window %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Failed to delete maintenance window")
+		return
+	}
+
+	log.Log(log.Info, "[CollatorAPI] Maintenance window %d deleted by %s", id, principalFromRequest(r).Name)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deleted": id})
+}
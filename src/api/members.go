@@ -27,7 +27,10 @@ type MemberInfo struct {
 
 func handleMembers(w http.ResponseWriter, r *http.Request) {
 	c := cfg.GetConfig()
-	memberName := r.URL.Query().Get("name")
+	memberName, ok := enforceMemberParam(w, r, r.URL.Query().Get("name"), false)
+	if !ok {
+		return
+	}
 
 	if memberName != "" {
 		// Get specific member
@@ -58,7 +61,10 @@ func handleMemberStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	memberName := r.URL.Query().Get("name")
+	memberName, ok := enforceMemberParam(w, r, r.URL.Query().Get("name"), false)
+	if !ok {
+		return
+	}
 	if memberName == "" {
 		writeError(w, http.StatusBadRequest, "Member name required")
 		return
@@ -0,0 +1,302 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
+	cluster "github.com/ibp-network/ibp-geodns-collator/src/cluster"
+
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// slaGaugeTTL bounds how often a scrape may trigger a fresh SLA calculation.
+const slaGaugeTTL = 5 * time.Minute
+
+// latencyBuckets are the upper bounds (seconds) of the request latency histogram.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type routeKey struct {
+	route  string
+	method string
+	code   int
+}
+
+type latencyHistogram struct {
+	buckets []uint64 // cumulative counts are derived at render time
+	count   uint64
+	sum     float64
+}
+
+var httpMetrics struct {
+	sync.Mutex
+	requests map[routeKey]uint64
+	latency  map[string]*latencyHistogram // route → histogram
+}
+
+var slaGauges struct {
+	sync.Mutex
+	computed time.Time
+	sla      billing.SLASummary
+}
+
+// handle registers a handler on mux with per-route request metrics.
+func handle(route string, h http.HandlerFunc) {
+	mux.HandleFunc(route, instrumentRoute(route, h))
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	sr.status = code
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+// instrumentRoute counts requests and observes latency under the registered
+// route pattern, keeping label cardinality bounded.
+func instrumentRoute(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		observeRequest(route, r.Method, rec.status, time.Since(start).Seconds())
+	}
+}
+
+func observeRequest(route, method string, code int, seconds float64) {
+	httpMetrics.Lock()
+	defer httpMetrics.Unlock()
+
+	if httpMetrics.requests == nil {
+		httpMetrics.requests = make(map[routeKey]uint64)
+		httpMetrics.latency = make(map[string]*latencyHistogram)
+	}
+	httpMetrics.requests[routeKey{route: route, method: method, code: code}]++
+
+	h, ok := httpMetrics.latency[route]
+	if !ok {
+		h = &latencyHistogram{buckets: make([]uint64, len(latencyBuckets))}
+		httpMetrics.latency[route] = h
+	}
+	for i, le := range latencyBuckets {
+		if seconds <= le {
+			h.buckets[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// currentMonthSLA returns the cached current-month SLA, recalculating it when stale.
+func currentMonthSLA() billing.SLASummary {
+	slaGauges.Lock()
+	defer slaGauges.Unlock()
+
+	if slaGauges.sla != nil && time.Since(slaGauges.computed) < slaGaugeTTL {
+		return slaGauges.sla
+	}
+
+	now := time.Now().UTC()
+	currentMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	summary := billing.GetSummary()
+	sla, err := billing.CalculateSLAAdjustments(currentMonth, &summary)
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to calculate SLA for metrics: %v", err)
+		return slaGauges.sla
+	}
+
+	slaGauges.sla = sla
+	slaGauges.computed = now
+	return sla
+}
+
+// handleMetrics handles GET /metrics in OpenMetrics text format
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+
+	writeHTTPMetrics(&buf)
+
+	// Leader election
+	writeMetricHeader(&buf, "ibp_collator_leader", "gauge", "1 if this collator is the elected leader running scheduled jobs.")
+	leader := 0
+	if cluster.IsLeader() {
+		leader = 1
+	}
+	fmt.Fprintf(&buf, "ibp_collator_leader%s %d\n", labels("node_id", cluster.GetStatus().NodeID), leader)
+
+	// Billing snapshot freshness
+	writeMetricHeader(&buf, "ibp_collator_billing_refresh_age_seconds", "gauge", "Seconds since the billing snapshot was last refreshed.")
+	refreshAge := math.NaN()
+	if last := billing.LastRefresh(); !last.IsZero() {
+		refreshAge = time.Since(last).Seconds()
+	}
+	fmt.Fprintf(&buf, "ibp_collator_billing_refresh_age_seconds %s\n", formatFloat(refreshAge))
+
+	// PDF index
+	writeMetricHeader(&buf, "ibp_collator_pdf_files", "gauge", "Number of billing PDFs found by the last PDF scan.")
+	pdfCount := 0
+	if pdfManager != nil {
+		pdfCount = pdfManager.Count()
+	}
+	fmt.Fprintf(&buf, "ibp_collator_pdf_files %d\n", pdfCount)
+
+	// Monthly billing generation
+	status, ran := billing.LastGenerationStatus()
+	month := labels("month", status.Month.Format("2006-01"))
+	writeMetricHeader(&buf, "ibp_collator_monthly_billing_last_success", "gauge", "1 if the last monthly billing PDF run succeeded, 0 if it failed.")
+	if ran {
+		success := 0
+		if status.Success {
+			success = 1
+		}
+		fmt.Fprintf(&buf, "ibp_collator_monthly_billing_last_success%s %d\n", month, success)
+	}
+	writeMetricHeader(&buf, "ibp_collator_monthly_billing_last_run_timestamp_seconds", "gauge", "Unix time the last monthly billing PDF run finished.")
+	if ran {
+		fmt.Fprintf(&buf, "ibp_collator_monthly_billing_last_run_timestamp_seconds%s %d\n", month, status.Finished.Unix())
+	}
+
+	// Current-month SLA per member/service
+	sla := currentMonthSLA()
+	writeMetricHeader(&buf, "ibp_collator_sla_uptime_percent", "gauge", "Current-month uptime percentage per member and service.")
+	forEachSLA(sla, func(member, service string, bd billing.SLABreakdown) {
+		fmt.Fprintf(&buf, "ibp_collator_sla_uptime_percent%s %s\n", labels("member", member, "service", service), formatFloat(bd.Uptime))
+	})
+	writeMetricHeader(&buf, "ibp_collator_sla_downtime_hours", "gauge", "Current-month downtime hours per member and service.")
+	forEachSLA(sla, func(member, service string, bd billing.SLABreakdown) {
+		fmt.Fprintf(&buf, "ibp_collator_sla_downtime_hours%s %s\n", labels("member", member, "service", service), formatFloat(bd.HoursDown))
+	})
+	writeMetricHeader(&buf, "ibp_collator_sla_met", "gauge", "1 if the member/service currently meets its SLA target.")
+	forEachSLA(sla, func(member, service string, bd billing.SLABreakdown) {
+		met := 0
+		if bd.MeetsSLA {
+			met = 1
+		}
+		fmt.Fprintf(&buf, "ibp_collator_sla_met%s %d\n", labels("member", member, "service", service), met)
+	})
+
+	buf.WriteString("# EOF\n")
+
+	w.Header().Set("Content-Type", openMetricsContentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to write metrics: %v", err)
+	}
+}
+
+func writeHTTPMetrics(buf *bytes.Buffer) {
+	httpMetrics.Lock()
+	defer httpMetrics.Unlock()
+
+	writeMetricHeader(buf, "ibp_collator_http_requests", "counter", "HTTP requests served, by route, method and status code.")
+	keys := make([]routeKey, 0, len(httpMetrics.requests))
+	for k := range httpMetrics.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, k := range keys {
+		fmt.Fprintf(buf, "ibp_collator_http_requests_total%s %d\n",
+			labels("route", k.route, "method", k.method, "code", strconv.Itoa(k.code)), httpMetrics.requests[k])
+	}
+
+	writeMetricHeader(buf, "ibp_collator_http_request_duration_seconds", "histogram", "HTTP request latency by route.")
+	routes := make([]string, 0, len(httpMetrics.latency))
+	for route := range httpMetrics.latency {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		h := httpMetrics.latency[route]
+		var cumulative uint64
+		for i, le := range latencyBuckets {
+			cumulative += h.buckets[i]
+			fmt.Fprintf(buf, "ibp_collator_http_request_duration_seconds_bucket%s %d\n",
+				labels("route", route, "le", formatFloat(le)), cumulative)
+		}
+		fmt.Fprintf(buf, "ibp_collator_http_request_duration_seconds_bucket%s %d\n", labels("route", route, "le", "+Inf"), h.count)
+		fmt.Fprintf(buf, "ibp_collator_http_request_duration_seconds_count%s %d\n", labels("route", route), h.count)
+		fmt.Fprintf(buf, "ibp_collator_http_request_duration_seconds_sum%s %s\n", labels("route", route), formatFloat(h.sum))
+	}
+}
+
+// forEachSLA walks an SLA summary in a stable member/service order.
+func forEachSLA(sla billing.SLASummary, fn func(member, service string, bd billing.SLABreakdown)) {
+	members := make([]string, 0, len(sla))
+	for m := range sla {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+
+	for _, m := range members {
+		services := make([]string, 0, len(sla[m]))
+		for s := range sla[m] {
+			services = append(services, s)
+		}
+		sort.Strings(services)
+		for _, s := range services {
+			fn(m, s, sla[m][s])
+		}
+	}
+}
+
+func writeMetricHeader(buf *bytes.Buffer, name, metricType, help string) {
+	fmt.Fprintf(buf, "# TYPE %s %s\n# HELP %s %s\n", name, metricType, name, help)
+}
+
+// labels renders name/value pairs as an OpenMetrics label set.
+func labels(kv ...string) string {
+	if len(kv) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(kv[i])
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabelValue(kv[i+1]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
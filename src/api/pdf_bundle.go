@@ -0,0 +1,105 @@
+package api
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
+
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+// isBundleArtifact reports whether a file of a month that the PDF index does
+// not track belongs in the full bundle: the breakdown exports and
+// the signed manifest.
+func isBundleArtifact(name string) bool {
+	return name == billing.ManifestFileName || strings.HasSuffix(name, ".csv") || strings.HasSuffix(name, ".xlsx")
+}
+
+// handlePDFBundle handles GET /api/billing/pdfs/bundle?year=&month=[&member=]
+// and streams a zip of the month's reports. With a member (always the token's
+// own for member-scoped tokens) only that member's PDF is included; otherwise
+// every indexed report plus the breakdown exports and the manifest.
+func handlePDFBundle(w http.ResponseWriter, r *http.Request) {
+	if pdfManager == nil {
+		writeError(w, http.StatusInternalServerError, "PDF manager not initialized")
+		return
+	}
+
+	year := r.URL.Query().Get("year")
+	month := r.URL.Query().Get("month")
+	if !validateYear(year) || !validateMonth(month) {
+		writeError(w, http.StatusBadRequest, "Valid year and month are required")
+		return
+	}
+
+	memberName, ok := enforceMemberParam(w, r, sanitizeString(r.URL.Query().Get("member")), false)
+	if !ok {
+		return
+	}
+
+	var keys []string
+	for _, pdf := range pdfManager.GetPDFList(year, month, memberName) {
+		keys = append(keys, pdf.Key)
+	}
+	if len(keys) == 0 {
+		writeError(w, http.StatusNotFound, "No reports found for this month")
+		return
+	}
+
+	if memberName == "" {
+		monthKey := pdfManager.monthKeys(year, month)[0]
+		artifacts, err := pdfManager.store.List(monthKey + "/")
+		if err != nil {
+			log.Log(log.Warn, "[CollatorAPI] Failed to list %s for bundle: %v", monthKey, err)
+		}
+		for _, a := range artifacts {
+			if isBundleArtifact(a.Name()) {
+				keys = append(keys, a.Key)
+			}
+		}
+	}
+
+	monthInt := 0
+	fmt.Sscanf(month, "%d", &monthInt)
+	downloadName := fmt.Sprintf("%s_%02d-Billing_Bundle.zip", year, monthInt)
+	if memberName != "" {
+		downloadName = fmt.Sprintf("%s_%02d-Billing_Bundle_%s.zip", year, monthInt, strings.ReplaceAll(memberName, " ", "_"))
+	}
+
+	// the zip is streamed, so failures past this point can only be logged
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", downloadName))
+	w.WriteHeader(http.StatusOK)
+
+	zw := zip.NewWriter(w)
+	for _, key := range keys {
+		if err := addZipFile(zw, pdfManager.store, key); err != nil {
+			log.Log(log.Error, "[CollatorAPI] Failed to add %s to bundle: %v", key, err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to finish bundle %s: %v", downloadName, err)
+	}
+}
+
+// addZipFile copies the artifact key into zw under its file name.
+func addZipFile(zw *zip.Writer, store billing.ArtifactStore, key string) error {
+	f, info, err := store.Get(key)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header := &zip.FileHeader{Name: info.Name(), Method: zip.Deflate, Modified: info.Modified}
+	dst, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, f)
+	return err
+}
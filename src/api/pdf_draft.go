@@ -0,0 +1,76 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
+
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+// handleDraftPDF handles GET /api/billing/pdfs/draft. The PDF of the month in
+// progress is rendered in memory and watermarked as a draft; nothing is
+// written to the PDF directory.
+func handleDraftPDF(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("type")
+	switch kind {
+	case reportOverview:
+		if principalFromRequest(r).IsMemberBound() {
+			writeError(w, http.StatusForbidden, "Member-scoped tokens cannot download the monthly overview")
+			return
+		}
+	case "", reportMember:
+		kind = reportMember
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid type; expected member or overview")
+		return
+	}
+
+	memberName, ok := enforceMemberParam(w, r, sanitizeString(r.URL.Query().Get("member")), false)
+	if !ok {
+		return
+	}
+
+	var (
+		buf    bytes.Buffer
+		err    error
+		member string
+	)
+	if kind == reportMember {
+		if memberName == "" {
+			writeError(w, http.StatusBadRequest, "Member name is required for non-overview PDFs")
+			return
+		}
+		id, found := resolveConfigMember(memberName)
+		if !found {
+			writeError(w, http.StatusNotFound, "Member not found")
+			return
+		}
+		member = id
+		err = billing.WriteDraftMemberPDF(member, &buf)
+	} else {
+		err = billing.WriteDraftOverviewPDF(&buf)
+	}
+	if errors.Is(err, billing.ErrMemberNotBilled) {
+		writeError(w, http.StatusNotFound, "Member has no charges this month")
+		return
+	}
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to render draft %s PDF: %v", kind, err)
+		writeError(w, http.StatusInternalServerError, "Failed to render draft PDF")
+		return
+	}
+
+	downloadName := billing.DraftFileName(time.Now().UTC(), member)
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", downloadName))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
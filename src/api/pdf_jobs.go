@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
+
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+type pdfJobRequest struct {
+	Year    int      `json:"year"`
+	Month   int      `json:"month"`
+	Members []string `json:"members"`
+	Types   []string `json:"types"`
+}
+
+type PDFJobResponse struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Month      string     `json:"month,omitempty"`
+	Types      []string   `json:"types"`
+	Members    []string   `json:"members,omitempty"`
+	Author     string     `json:"requested_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Total      int        `json:"total"`
+	Done       int        `json:"done"`
+	Files      []string   `json:"files"`
+	Errors     []string   `json:"errors,omitempty"`
+}
+
+func newPDFJobResponse(job billing.PDFJob) PDFJobResponse {
+	resp := PDFJobResponse{
+		ID:         job.ID,
+		Status:     job.Status,
+		Types:      job.Request.Types,
+		Members:    job.Request.Members,
+		Author:     job.Author,
+		CreatedAt:  job.Created,
+		StartedAt:  job.Started,
+		FinishedAt: job.Finished,
+		Total:      job.Total,
+		Done:       job.Done,
+		Files:      job.Files,
+		Errors:     job.Errors,
+	}
+	if !job.Request.Month.IsZero() {
+		resp.Month = job.Request.Month.Format("2006-01")
+	}
+	if resp.Files == nil {
+		resp.Files = []string{}
+	}
+	return resp
+}
+
+// handleGeneratePDFs handles POST /api/billing/pdfs/generate. The PDFs are
+// written by a background job; the PDF listing is rescanned when it finishes.
+func handleGeneratePDFs(w http.ResponseWriter, r *http.Request) {
+	var req pdfJobRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	jobReq := billing.PDFJobRequest{Types: req.Types}
+	if req.Year != 0 || req.Month != 0 {
+		if req.Year < 2020 || req.Year > 2100 || req.Month < 1 || req.Month > 12 {
+			writeError(w, http.StatusBadRequest, "Invalid year or month")
+			return
+		}
+		jobReq.Month = time.Date(req.Year, time.Month(req.Month), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	for _, name := range req.Members {
+		name = sanitizeString(name)
+		if !validateMemberName(name) {
+			writeError(w, http.StatusBadRequest, "Invalid member name")
+			return
+		}
+		// members removed since are still part of the month's snapshot
+		if id, ok := resolveConfigMember(name); ok {
+			name = id
+		}
+		jobReq.Members = append(jobReq.Members, name)
+	}
+
+	author := principalFromRequest(r).Name
+	job, err := billing.StartPDFJob(jobReq, author, func(billing.PDFJob) {
+		if pdfManager != nil {
+			pdfManager.scanPDFFiles()
+		}
+	})
+	if errors.Is(err, billing.ErrInvalidJob) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to start PDF job: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to start PDF generation")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, newPDFJobResponse(job))
+}
+
+// handlePDFJobs handles GET /api/billing/pdfs/jobs[?id=...]
+func handlePDFJobs(w http.ResponseWriter, r *http.Request) {
+	if id := r.URL.Query().Get("id"); id != "" {
+		job := billing.GetPDFJob(id)
+		if job.ID == "" {
+			writeError(w, http.StatusNotFound, "Job not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, newPDFJobResponse(job))
+		return
+	}
+
+	jobs := billing.ListPDFJobs()
+	data := make([]PDFJobResponse, 0, len(jobs))
+	for _, job := range jobs {
+		data = append(data, newPDFJobResponse(job))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total": len(data),
+		"data":  data,
+	})
+}
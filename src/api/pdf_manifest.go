@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+
+	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
+
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+// maxVerifyUpload bounds the size of files accepted by /api/billing/pdfs/verify.
+const maxVerifyUpload = 64 << 20
+
+// PDFVerification is the result of checking an uploaded file against the
+// signed manifests.
+type PDFVerification struct {
+	Verified       bool   `json:"verified"`
+	SHA256         string `json:"sha256"`
+	Size           int64  `json:"size"`
+	Month          string `json:"month,omitempty"`
+	FileName       string `json:"file_name,omitempty"`
+	SignatureValid bool   `json:"signature_valid"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+// monthKeys returns the months (YYYY-MM) to look in: the one of year and
+// month when given, otherwise every scanned month, newest first.
+func (pm *PDFManager) monthKeys(year, month string) []string {
+	if year != "" {
+		monthInt := 0
+		fmt.Sscanf(month, "%d", &monthInt)
+		return []string{fmt.Sprintf("%s-%02d", year, monthInt)}
+	}
+
+	pm.mu.RLock()
+	keys := make([]string, 0, len(pm.pdfFiles))
+	for key := range pm.pdfFiles {
+		keys = append(keys, key)
+	}
+	pm.mu.RUnlock()
+
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	return keys
+}
+
+// handlePDFManifest handles GET /api/billing/pdfs/manifest?year=&month=
+func handlePDFManifest(w http.ResponseWriter, r *http.Request) {
+	if pdfManager == nil {
+		writeError(w, http.StatusInternalServerError, "PDF manager not initialized")
+		return
+	}
+
+	year := r.URL.Query().Get("year")
+	month := r.URL.Query().Get("month")
+	if !validateYear(year) || !validateMonth(month) {
+		writeError(w, http.StatusBadRequest, "Valid year and month are required")
+		return
+	}
+
+	// served as written so the signature can be checked byte for byte
+	key := path.Join(pdfManager.monthKeys(year, month)[0], billing.ManifestFileName)
+	data, err := billing.ReadArtifact(key)
+	if errors.Is(err, billing.ErrArtifactNotFound) {
+		writeError(w, http.StatusNotFound, "No manifest for this month")
+		return
+	}
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to read manifest %s: %v", key, err)
+		writeError(w, http.StatusInternalServerError, "Failed to read manifest")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// handleVerifyPDF handles POST /api/billing/pdfs/verify. The file is sent as
+// the request body or as the "file" field of a multipart form; year and month
+// optionally restrict the manifests searched.
+func handleVerifyPDF(w http.ResponseWriter, r *http.Request) {
+	if pdfManager == nil {
+		writeError(w, http.StatusInternalServerError, "PDF manager not initialized")
+		return
+	}
+
+	year := r.URL.Query().Get("year")
+	month := r.URL.Query().Get("month")
+	if (year != "" || month != "") && (!validateYear(year) || !validateMonth(month)) {
+		writeError(w, http.StatusBadRequest, "Invalid year or month")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxVerifyUpload)
+	var body io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Missing file upload")
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	sum, size, err := billing.HashReader(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to read upload")
+		return
+	}
+	if size == 0 {
+		writeError(w, http.StatusBadRequest, "Empty upload")
+		return
+	}
+
+	pub, err := billing.SigningPublicKey()
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to load signing key: %v", err)
+		writeError(w, http.StatusInternalServerError, "Signing key unavailable")
+		return
+	}
+
+	result := PDFVerification{SHA256: sum, Size: size, Reason: "File does not match any manifest"}
+	for _, monthKey := range pdfManager.monthKeys(year, month) {
+		manifest, err := billing.ReadManifest(monthKey)
+		if errors.Is(err, billing.ErrManifestNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Log(log.Warn, "[CollatorAPI] Skipping manifest of %s: %v", monthKey, err)
+			continue
+		}
+		entry, ok := manifest.Match(sum)
+		if !ok {
+			continue
+		}
+
+		result.Month, result.FileName, result.Reason = manifest.Month, entry.Name, ""
+		switch {
+		case pub == nil:
+			result.Reason = "No signing key configured"
+		case manifest.VerifySignature(pub) != nil:
+			result.Reason = "Manifest signature does not verify"
+		default:
+			result.SignatureValid = true
+			result.Verified = entry.Size == size
+		}
+		break
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
@@ -1,27 +1,24 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
-	cfg "github.com/ibp-network/ibp-geodns-libs/config"
+	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
+
 	log "github.com/ibp-network/ibp-geodns-libs/logging"
 )
 
 // initPDFManager initializes the PDF manager
 func initPDFManager() {
-	c := cfg.GetConfig()
-	baseDir := filepath.Join(c.Local.System.WorkDir, "tmp")
-
 	pdfManager = &PDFManager{
 		pdfFiles: make(map[string][]PDFInfo),
-		baseDir:  baseDir,
+		store:    billing.Artifacts(),
 	}
 
 	// Initial scan
@@ -36,41 +33,43 @@ func (pm *PDFManager) startPeriodicScan() {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		pm.scanPDFFiles()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			pm.scanPDFFiles()
+		}
 	}
 }
 
-// scanPDFFiles scans the tmp directory for YYYY-MM folders containing PDFs
+// scanPDFFiles lists the artifact store for YYYY-MM prefixes containing PDFs
 func (pm *PDFManager) scanPDFFiles() {
-	log.Log(log.Debug, "[PDFManager] Starting PDF file scan in %s", pm.baseDir)
+	log.Log(log.Debug, "[PDFManager] Starting PDF file scan")
 
 	newFiles := make(map[string][]PDFInfo)
 
-	// Read the tmp directory
-	entries, err := os.ReadDir(pm.baseDir)
+	artifacts, err := pm.store.List("")
 	if err != nil {
-		log.Log(log.Error, "[PDFManager] Failed to read directory %s: %v", pm.baseDir, err)
+		log.Log(log.Error, "[PDFManager] Failed to list artifacts: %v", err)
 		return
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-
-		// Check if directory name matches YYYY-MM pattern
-		if !monthDirPattern.MatchString(entry.Name()) {
+	// Group the artifacts by month (YYYY-MM/<file>)
+	months := make(map[string][]billing.Artifact)
+	for _, a := range artifacts {
+		monthKey, name, ok := strings.Cut(a.Key, "/")
+		if !ok || strings.Contains(name, "/") || !monthDirPattern.MatchString(monthKey) {
 			continue
 		}
+		months[monthKey] = append(months[monthKey], a)
+	}
 
-		monthDir := filepath.Join(pm.baseDir, entry.Name())
-		monthKey := entry.Name() // YYYY-MM
-
-		// Scan PDFs in this month directory
-		pdfFiles, err := pm.scanMonthDirectory(monthDir, monthKey)
+	for monthKey, list := range months {
+		// Scan PDFs of this month
+		pdfFiles, err := pm.scanMonth(monthKey, list)
 		if err != nil {
-			log.Log(log.Error, "[PDFManager] Failed to scan month directory %s: %v", monthDir, err)
+			log.Log(log.Error, "[PDFManager] Failed to scan month %s: %v", monthKey, err)
 			continue
 		}
 
@@ -92,62 +91,68 @@ func (pm *PDFManager) scanPDFFiles() {
 	log.Log(log.Info, "[PDFManager] Scan complete: found %d PDF files across %d months", totalFiles, len(newFiles))
 }
 
-// scanMonthDirectory scans a specific month directory for PDF files
-func (pm *PDFManager) scanMonthDirectory(dirPath, monthKey string) ([]PDFInfo, error) {
+// scanMonth indexes the PDF files among the artifacts of a month
+func (pm *PDFManager) scanMonth(monthKey string, artifacts []billing.Artifact) ([]PDFInfo, error) {
 	var pdfInfos []PDFInfo
 
-	files, err := os.ReadDir(dirPath)
-	if err != nil {
-		return nil, err
-	}
-
 	parts := strings.Split(monthKey, "-")
 	if len(parts) != 2 {
 		return nil, fmt.Errorf("invalid month key format: %s", monthKey)
 	}
 	year, month := parts[0], parts[1]
 
-	for _, file := range files {
-		if file.IsDir() || !strings.HasSuffix(file.Name(), ".pdf") {
-			continue
-		}
+	manifest, err := billing.ReadManifest(monthKey)
+	if err != nil && !errors.Is(err, billing.ErrManifestNotFound) {
+		log.Log(log.Warn, "[PDFManager] Failed to read manifest of %s: %v", monthKey, err)
+	}
 
-		info, err := file.Info()
-		if err != nil {
-			log.Log(log.Error, "[PDFManager] Failed to get file info for %s: %v", file.Name(), err)
+	for _, a := range artifacts {
+		name := a.Name()
+		if !(strings.HasSuffix(name, ".pdf") || strings.HasSuffix(name, ".md")) {
 			continue
 		}
 
 		pdfInfo := PDFInfo{
 			Year:     year,
 			Month:    month,
-			FileName: file.Name(),
-			FilePath: filepath.Join(dirPath, file.Name()),
-			FileSize: info.Size(),
-			ModTime:  info.ModTime().Format(time.RFC3339),
+			FileName: name,
+			Key:      a.Key,
+			FileSize: a.Size,
+			ModTime:  a.Modified.UTC().Format(time.RFC3339),
+		}
+		if manifest != nil {
+			if entry, ok := manifest.File(name); ok {
+				pdfInfo.SHA256 = entry.SHA256
+			}
 		}
 
 		// Check if it's an overview file
-		if matches := overviewPattern.FindStringSubmatch(file.Name()); matches != nil {
+		if matches := overviewPattern.FindStringSubmatch(name); matches != nil {
 			pdfInfo.IsOverview = true
-		} else if matches := pdfFilePattern.FindStringSubmatch(file.Name()); matches != nil {
+		} else if matches := proposalPattern.FindStringSubmatch(name); matches != nil {
+			// Treasury proposal written with the overview
+			pdfInfo.IsProposal = true
+		} else if matches := pdfFilePattern.FindStringSubmatch(name); matches != nil {
 			// Extract member name and convert underscores back to spaces for display
 			memberName := strings.ReplaceAll(matches[3], "_", " ")
 			pdfInfo.MemberName = memberName
 		} else {
 			// Skip files that don't match expected patterns
-			log.Log(log.Debug, "[PDFManager] Skipping file with unexpected name: %s", file.Name())
+			log.Log(log.Debug, "[PDFManager] Skipping file with unexpected name: %s", name)
 			continue
 		}
 
 		pdfInfos = append(pdfInfos, pdfInfo)
 	}
 
-	// Sort by member name (overview files first)
+	// Sort by member name (overview and proposal first)
 	sort.Slice(pdfInfos, func(i, j int) bool {
 		if pdfInfos[i].IsOverview != pdfInfos[j].IsOverview {
 			return pdfInfos[i].IsOverview
 		}
+		if pdfInfos[i].IsProposal != pdfInfos[j].IsProposal {
+			return pdfInfos[i].IsProposal
+		}
 		return pdfInfos[i].MemberName < pdfInfos[j].MemberName
 	})
 
@@ -171,7 +176,7 @@ func (pm *PDFManager) GetPDFList(year, month, memberName string) []PDFInfo {
 			for _, pdf := range files {
 				// Filter by member name if specified
 				if memberName != "" {
-					if !pdf.IsOverview && strings.EqualFold(pdf.MemberName, memberName) {
+					if pdf.MemberName != "" && pdfMemberMatches(pdf.MemberName, memberName) {
 						results = append(results, pdf)
 					}
 				} else {
@@ -185,7 +190,7 @@ func (pm *PDFManager) GetPDFList(year, month, memberName string) []PDFInfo {
 			for _, pdf := range files {
 				// Filter by member name if specified
 				if memberName != "" {
-					if !pdf.IsOverview && strings.EqualFold(pdf.MemberName, memberName) {
+					if pdf.MemberName != "" && pdfMemberMatches(pdf.MemberName, memberName) {
 						results = append(results, pdf)
 					}
 				} else {
@@ -198,8 +203,28 @@ func (pm *PDFManager) GetPDFList(year, month, memberName string) []PDFInfo {
 	return results
 }
 
-// GetPDFFile returns the file path for a specific PDF
-func (pm *PDFManager) GetPDFFile(year, month, memberName string, isOverview bool) (string, error) {
+// Count returns the number of PDFs found by the last scan
+func (pm *PDFManager) Count() int {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	total := 0
+	for _, files := range pm.pdfFiles {
+		total += len(files)
+	}
+	return total
+}
+
+// Report types accepted by GetPDFFile and the download endpoint's type parameter.
+const (
+	reportMember   = "member"
+	reportOverview = "overview"
+	reportProposal = "proposal"
+)
+
+// GetPDFFile returns the artifact key of a specific report of kind reportMember,
+// reportOverview or reportProposal
+func (pm *PDFManager) GetPDFFile(year, month, memberName, kind string) (string, error) {
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
 
@@ -213,19 +238,37 @@ func (pm *PDFManager) GetPDFFile(year, month, memberName string, isOverview bool
 	}
 
 	for _, pdf := range files {
-		if isOverview && pdf.IsOverview {
-			return pdf.FilePath, nil
-		} else if !isOverview && !pdf.IsOverview && strings.EqualFold(pdf.MemberName, memberName) {
-			return pdf.FilePath, nil
+		switch kind {
+		case reportOverview:
+			if pdf.IsOverview {
+				return pdf.Key, nil
+			}
+		case reportProposal:
+			if pdf.IsProposal {
+				return pdf.Key, nil
+			}
+		default:
+			if pdf.MemberName != "" && pdfMemberMatches(pdf.MemberName, memberName) {
+				return pdf.Key, nil
+			}
 		}
 	}
 
-	if isOverview {
+	switch kind {
+	case reportOverview:
 		return "", fmt.Errorf("overview PDF not found for %s", monthKey)
+	case reportProposal:
+		return "", fmt.Errorf("treasury proposal not found for %s", monthKey)
 	}
 	return "", fmt.Errorf("member PDF not found for %s in %s", memberName, monthKey)
 }
 
+// pdfMemberMatches compares a member name parsed from a PDF file name (where
+// underscores became spaces) against a requested member name or config ID.
+func pdfMemberMatches(pdfMember, memberName string) bool {
+	return strings.EqualFold(pdfMember, strings.ReplaceAll(memberName, "_", " "))
+}
+
 // API Handlers
 
 // handleListPDFs handles GET /api/billing/pdfs
@@ -238,7 +281,12 @@ func handleListPDFs(w http.ResponseWriter, r *http.Request) {
 	// Get query parameters
 	year := r.URL.Query().Get("year")
 	month := r.URL.Query().Get("month")
-	memberName := r.URL.Query().Get("member")
+
+	// Member-scoped tokens only ever see their own files
+	memberName, ok := enforceMemberParam(w, r, r.URL.Query().Get("member"), false)
+	if !ok {
+		return
+	}
 
 	// Validate year if provided
 	if year != "" && !validateYear(year) {
@@ -305,8 +353,21 @@ func handleDownloadPDF(w http.ResponseWriter, r *http.Request) {
 	// Get query parameters
 	year := r.URL.Query().Get("year")
 	month := r.URL.Query().Get("month")
-	memberName := r.URL.Query().Get("member")
-	isOverview := r.URL.Query().Get("type") == "overview"
+	kind := r.URL.Query().Get("type")
+	switch kind {
+	case reportOverview, reportProposal:
+		if principalFromRequest(r).IsMemberBound() {
+			writeError(w, http.StatusForbidden, "Member-scoped tokens cannot download the monthly "+kind)
+			return
+		}
+	default:
+		kind = reportMember
+	}
+
+	memberName, ok := enforceMemberParam(w, r, r.URL.Query().Get("member"), false)
+	if !ok {
+		return
+	}
 
 	// Validate required parameters
 	if year == "" || month == "" {
@@ -314,7 +375,7 @@ func handleDownloadPDF(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !isOverview && memberName == "" {
+	if kind == reportMember && memberName == "" {
 		writeError(w, http.StatusBadRequest, "Member name is required for non-overview PDFs")
 		return
 	}
@@ -330,15 +391,19 @@ func handleDownloadPDF(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the PDF file path
-	filePath, err := pdfManager.GetPDFFile(year, month, memberName, isOverview)
+	// Get the PDF artifact key
+	key, err := pdfManager.GetPDFFile(year, month, memberName, kind)
 	if err != nil {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
 
-	// Open the file
-	file, err := os.Open(filePath)
+	// Open the artifact
+	file, info, err := pdfManager.store.Get(key)
+	if errors.Is(err, billing.ErrArtifactNotFound) {
+		writeError(w, http.StatusNotFound, "PDF file no longer available")
+		return
+	}
 	if err != nil {
 		log.Log(log.Error, "[API] Failed to open PDF file: %v", err)
 		writeError(w, http.StatusInternalServerError, "Failed to open PDF file")
@@ -346,14 +411,6 @@ func handleDownloadPDF(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	// Get file info
-	fileInfo, err := file.Stat()
-	if err != nil {
-		log.Log(log.Error, "[API] Failed to get PDF file info: %v", err)
-		writeError(w, http.StatusInternalServerError, "Failed to get file information")
-		return
-	}
-
 	// Generate download filename
 	var downloadName string
 	// Normalize month to 2-digit format for filename
@@ -361,18 +418,25 @@ func handleDownloadPDF(w http.ResponseWriter, r *http.Request) {
 	fmt.Sscanf(month, "%d", &monthInt)
 	monthFormatted := fmt.Sprintf("%02d", monthInt)
 
-	if isOverview {
+	contentType := "application/pdf"
+	switch kind {
+	case reportOverview:
 		downloadName = fmt.Sprintf("%s_%s-Monthly_Overview.pdf", year, monthFormatted)
-	} else {
+	case reportProposal:
+		downloadName = fmt.Sprintf("%s_%s-Treasury_Proposal.md", year, monthFormatted)
+		contentType = "text/markdown; charset=utf-8"
+	default:
 		// Convert spaces to underscores in member name for filename
 		safeMemberName := strings.ReplaceAll(memberName, " ", "_")
 		downloadName = fmt.Sprintf("%s_%s-IBP-Service_%s.pdf", year, monthFormatted, safeMemberName)
 	}
 
 	// Set headers
-	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", downloadName))
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
+	if info.Size >= 0 {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size))
+	}
 
 	// Stream the file
 	_, err = io.Copy(w, file)
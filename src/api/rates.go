@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
+
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+type RateResponse struct {
+	Currency   string  `json:"currency"`
+	Month      string  `json:"month"`
+	UsdPerUnit float64 `json:"usd_per_unit"`
+	RateDate   string  `json:"rate_date"`
+	Source     string  `json:"source"`
+}
+
+type rateRequest struct {
+	Currency   string  `json:"currency"`
+	Year       int     `json:"year"`
+	Month      int     `json:"month"`
+	UsdPerUnit float64 `json:"usd_per_unit"`
+	RateDate   string  `json:"rate_date"`
+}
+
+func newRateResponse(rate billing.ExchangeRate) RateResponse {
+	return RateResponse{
+		Currency:   rate.Currency,
+		Month:      rate.Month.Format("2006-01"),
+		UsdPerUnit: rate.UsdPerUnit,
+		RateDate:   rate.Date.Format("2006-01-02"),
+		Source:     rate.Source,
+	}
+}
+
+// requestRate resolves the currency query parameter to the rate of month
+// (USD when absent). The boolean is false when a response has been written.
+func requestRate(w http.ResponseWriter, r *http.Request, month time.Time) (billing.ExchangeRate, bool) {
+	currency := strings.ToUpper(sanitizeString(r.URL.Query().Get("currency")))
+
+	rate, err := billing.RateFor(currency, month)
+	switch {
+	case errors.Is(err, billing.ErrUnknownCurrency):
+		writeError(w, http.StatusBadRequest, "Unsupported currency")
+		return rate, false
+	case errors.Is(err, billing.ErrRateNotFound):
+		writeError(w, http.StatusNotFound, "No "+currency+" exchange rate for "+month.Format("2006-01"))
+		return rate, false
+	case err != nil:
+		log.Log(log.Error, "[CollatorAPI] Failed to load %s exchange rate: %v", currency, err)
+		writeError(w, http.StatusInternalServerError, "Failed to load exchange rate")
+		return rate, false
+	}
+	return rate, true
+}
+
+// convertMember converts a member's USD amounts with rate.
+func convertMember(m *BillingMember, rate billing.ExchangeRate) {
+	if rate.Currency == billing.BaseCurrency {
+		return
+	}
+	m.TotalBase = rate.Convert(m.TotalBase)
+	m.TotalBilled = rate.Convert(m.TotalBilled)
+	m.TotalCredits = rate.Convert(m.TotalCredits)
+	m.TotalAdjustments = rate.Convert(m.TotalAdjustments)
+	for i := range m.Services {
+		s := &m.Services[i]
+		s.BaseCost = rate.Convert(s.BaseCost)
+		s.BilledCost = rate.Convert(s.BilledCost)
+		s.Credits = rate.Convert(s.Credits)
+	}
+	for i := range m.Adjustments {
+		m.Adjustments[i].Amount = rate.Convert(m.Adjustments[i].Amount)
+	}
+}
+
+// handleListRates handles GET /api/billing/rates
+func handleListRates(w http.ResponseWriter, r *http.Request) {
+	currency := strings.ToUpper(sanitizeString(r.URL.Query().Get("currency")))
+	if currency != "" && !validateIdentifier(currency) {
+		writeError(w, http.StatusBadRequest, "Invalid currency")
+		return
+	}
+
+	rates, err := billing.ListRates(currency)
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to list exchange rates: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to list exchange rates")
+		return
+	}
+
+	currencies := []string{billing.BaseCurrency}
+	for _, c := range billing.Currencies() {
+		currencies = append(currencies, strings.ToUpper(c.Code))
+	}
+
+	data := make([]RateResponse, 0, len(rates))
+	for _, rate := range rates {
+		data = append(data, newRateResponse(rate))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"currencies": currencies,
+		"total":      len(data),
+		"data":       data,
+	})
+}
+
+// handleSetRate handles POST /api/billing/rates. The rate replaces any earlier
+// API or file rate for the same currency and month.
+func handleSetRate(w http.ResponseWriter, r *http.Request) {
+	var req rateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Year < 2020 || req.Year > 2100 || req.Month < 1 || req.Month > 12 {
+		writeError(w, http.StatusBadRequest, "Invalid year or month")
+		return
+	}
+	if req.UsdPerUnit <= 0 || math.IsNaN(req.UsdPerUnit) || math.IsInf(req.UsdPerUnit, 0) {
+		writeError(w, http.StatusBadRequest, "usd_per_unit must be positive")
+		return
+	}
+
+	month := time.Date(req.Year, time.Month(req.Month), 1, 0, 0, 0, 0, time.UTC)
+	rateDate := month.AddDate(0, 1, -1)
+	if req.RateDate != "" {
+		d, err := time.Parse("2006-01-02", req.RateDate)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid rate_date format")
+			return
+		}
+		rateDate = d
+	}
+
+	rate := billing.ExchangeRate{
+		Currency:   strings.ToUpper(sanitizeString(req.Currency)),
+		Month:      month,
+		UsdPerUnit: req.UsdPerUnit,
+		Date:       rateDate,
+		Source:     "api",
+	}
+
+	author := principalFromRequest(r).Name
+	err := billing.SetRate(rate, author)
+	if errors.Is(err, billing.ErrUnknownCurrency) {
+		writeError(w, http.StatusBadRequest, "Unsupported currency")
+		return
+	}
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to store exchange rate: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to store exchange rate")
+		return
+	}
+
+	log.Log(log.Info, "[CollatorAPI] %s rate for %s set to $%.6f by %s",
+		rate.Currency, month.Format("2006-01"), rate.UsdPerUnit, author)
+	writeJSON(w, http.StatusOK, newRateResponse(rate))
+}
@@ -139,6 +139,9 @@ func handleRequestsByCountry(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid filter: %v", err))
 		return
 	}
+	if !enforceMemberFilter(w, r, &filters) {
+		return
+	}
 
 	baseQuery := `
 		SELECT 
@@ -197,6 +200,9 @@ func handleRequestsByASN(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid filter: %v", err))
 		return
 	}
+	if !enforceMemberFilter(w, r, &filters) {
+		return
+	}
 
 	baseQuery := `
 		SELECT 
@@ -248,6 +254,9 @@ func handleRequestsByService(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid filter: %v", err))
 		return
 	}
+	if !enforceMemberFilter(w, r, &filters) {
+		return
+	}
 
 	baseQuery := `
 		SELECT 
@@ -330,6 +339,9 @@ func handleRequestsByMember(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid filter: %v", err))
 		return
 	}
+	if !enforceMemberFilter(w, r, &filters) {
+		return
+	}
 
 	baseQuery := `
 		SELECT 
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+
+	billing "github.com/ibp-network/ibp-geodns-collator/src/billing"
+
+	cfg "github.com/ibp-network/ibp-geodns-libs/config"
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+type simulateRequest struct {
+	Pricing   map[string]cfg.IaasPricing `json:"pricing"`
+	Resources map[string]cfg.Resources   `json:"resources"`
+}
+
+type SimulatedCost struct {
+	Name      string  `json:"name,omitempty"`
+	Current   float64 `json:"current"`
+	Simulated float64 `json:"simulated"`
+	Delta     float64 `json:"delta"`
+}
+
+type SimulatedMember struct {
+	SimulatedCost
+	Services []SimulatedCost `json:"services"`
+}
+
+func newSimulatedCost(name string, d billing.CostDelta) SimulatedCost {
+	return SimulatedCost{Name: name, Current: d.Current, Simulated: d.Simulated, Delta: d.Change()}
+}
+
+// sortedCosts returns deltas as a list ordered by name.
+func sortedCosts(deltas map[string]billing.CostDelta) []SimulatedCost {
+	costs := make([]SimulatedCost, 0, len(deltas))
+	for name, d := range deltas {
+		costs = append(costs, newSimulatedCost(name, d))
+	}
+	sort.Slice(costs, func(i, j int) bool { return costs[i].Name < costs[j].Name })
+	return costs
+}
+
+func validPrice(values ...float64) bool {
+	for _, v := range values {
+		if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleSimulateBilling handles POST /api/billing/simulate. It prices the
+// current member assignments with the given pricing and resources and returns
+// the change against the live summary; nothing is stored.
+func handleSimulateBilling(w http.ResponseWriter, r *http.Request) {
+	var req simulateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 256<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.Pricing) == 0 && len(req.Resources) == 0 {
+		writeError(w, http.StatusBadRequest, "pricing or resources is required")
+		return
+	}
+
+	for region, p := range req.Pricing {
+		if !validPrice(p.Cores, p.Memory, p.Disk, p.Bandwidth) {
+			writeError(w, http.StatusBadRequest, "Invalid pricing for region "+sanitizeString(region))
+			return
+		}
+	}
+	for service, res := range req.Resources {
+		if res.Nodes < 0 || !validPrice(res.Cores, res.Memory, res.Disk, res.Bandwidth) {
+			writeError(w, http.StatusBadRequest, "Invalid resources for service "+sanitizeString(service))
+			return
+		}
+	}
+
+	sim, err := billing.Simulate(billing.Scenario{Pricing: req.Pricing, Resources: req.Resources})
+	if errors.Is(err, billing.ErrUnknownScenarioKey) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Log(log.Error, "[CollatorAPI] Failed to simulate billing: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to simulate billing")
+		return
+	}
+
+	members := make([]SimulatedMember, 0, len(sim.Members))
+	for name, md := range sim.Members {
+		members = append(members, SimulatedMember{
+			SimulatedCost: newSimulatedCost(name, md.CostDelta),
+			Services:      sortedCosts(md.Services),
+		})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"baseline_refresh": sim.Baseline,
+		"total":            newSimulatedCost("", sim.Total),
+		"members":          members,
+		"services":         sortedCosts(sim.Services),
+	})
+}
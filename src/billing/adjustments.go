@@ -0,0 +1,227 @@
+package billing
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	data2 "github.com/ibp-network/ibp-geodns-libs/data2"
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+var (
+	// ErrAdjustmentNotFound is returned when an adjustment id is unknown.
+	ErrAdjustmentNotFound = errors.New("billing adjustment not found")
+	// ErrAdjustmentVoided is returned when voiding an adjustment twice.
+	ErrAdjustmentVoided = errors.New("billing adjustment already voided")
+)
+
+// Adjustment is a manual entry in the billing ledger: a one-off credit
+// (negative amount) or surcharge (positive amount) granted by treasury for a
+// member's month. Adjustments are never deleted; voiding keeps the record and
+// removes it from the totals.
+type Adjustment struct {
+	ID         int64
+	Member     string // config member ID
+	Month      time.Time
+	Service    string // empty = not tied to a service
+	Amount     float64
+	Reason     string
+	Author     string
+	Created    time.Time
+	Voided     *time.Time
+	VoidedBy   string
+	VoidReason string
+}
+
+// Active reports whether the adjustment counts towards the bill.
+func (a Adjustment) Active() bool {
+	return a.Voided == nil
+}
+
+const createAdjustmentsTable = `
+	CREATE TABLE IF NOT EXISTS billing_adjustments (
+		id            INT UNSIGNED  NOT NULL AUTO_INCREMENT,
+		member_name   VARCHAR(255)  NOT NULL,
+		billing_month DATE          NOT NULL,
+		service_name  VARCHAR(255)  NOT NULL DEFAULT '',
+		amount        DECIMAL(14,2) NOT NULL,
+		reason        TEXT          NOT NULL,
+		created_by    VARCHAR(255)  NOT NULL,
+		created_at    DATETIME      NOT NULL,
+		voided_at     DATETIME      NULL,
+		voided_by     VARCHAR(255)  NULL,
+		void_reason   TEXT          NULL,
+		PRIMARY KEY (id),
+		KEY idx_adjustments_month_member (billing_month, member_name)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci`
+
+// adjustmentsEnabled is false when the billing_adjustments table could not be
+// prepared; bills are then computed without manual adjustments.
+var adjustmentsEnabled bool
+
+// initAdjustmentState prepares the billing_adjustments table.
+func initAdjustmentState() {
+	if data2.DB == nil {
+		log.Log(log.Warn, "[billing] database unavailable — billing adjustments disabled")
+		return
+	}
+
+	if _, err := data2.DB.Exec(createAdjustmentsTable); err != nil {
+		log.Log(log.Error, "[billing] failed to prepare billing_adjustments table: %v", err)
+		return
+	}
+	adjustmentsEnabled = true
+}
+
+// CreateAdjustment records a new adjustment and returns its id.
+func CreateAdjustment(a Adjustment) (int64, error) {
+	if !adjustmentsEnabled {
+		return 0, fmt.Errorf("billing adjustments are not available")
+	}
+	if a.Amount == 0 || math.IsNaN(a.Amount) || math.IsInf(a.Amount, 0) {
+		return 0, fmt.Errorf("adjustment amount must be a non-zero number")
+	}
+
+	res, err := data2.DB.Exec(`
+		INSERT INTO billing_adjustments
+			(member_name, billing_month, service_name, amount, reason, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`,
+		a.Member, monthStart(a.Month).Format("2006-01-02"), a.Service,
+		roundCents(a.Amount), a.Reason, a.Author)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetAdjustment returns a single adjustment, voided or not.
+func GetAdjustment(id int64) (Adjustment, error) {
+	if !adjustmentsEnabled {
+		return Adjustment{}, fmt.Errorf("billing adjustments are not available")
+	}
+
+	rows, err := data2.DB.Query(selectAdjustments+" WHERE id = ?", id)
+	if err != nil {
+		return Adjustment{}, err
+	}
+	adjustments, err := scanAdjustments(rows)
+	if err != nil {
+		return Adjustment{}, err
+	}
+	if len(adjustments) == 0 {
+		return Adjustment{}, ErrAdjustmentNotFound
+	}
+	return adjustments[0], nil
+}
+
+// VoidAdjustment marks an adjustment as voided by author.
+func VoidAdjustment(id int64, author, reason string) error {
+	if !adjustmentsEnabled {
+		return fmt.Errorf("billing adjustments are not available")
+	}
+
+	res, err := data2.DB.Exec(`
+		UPDATE billing_adjustments
+		SET voided_at = UTC_TIMESTAMP(), voided_by = ?, void_reason = ?
+		WHERE id = ? AND voided_at IS NULL`, author, reason, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	if _, err := GetAdjustment(id); err != nil {
+		return err
+	}
+	return ErrAdjustmentVoided
+}
+
+// ListAdjustments returns the adjustments of month (zero = every month),
+// oldest first, optionally restricted to one member (config ID). Voided
+// adjustments are only included when requested.
+func ListAdjustments(member string, month time.Time, includeVoided bool) ([]Adjustment, error) {
+	if !adjustmentsEnabled {
+		return nil, fmt.Errorf("billing adjustments are not available")
+	}
+
+	query := selectAdjustments + " WHERE 1=1"
+	args := []interface{}{}
+
+	if member != "" {
+		query += " AND member_name = ?"
+		args = append(args, member)
+	}
+	if !month.IsZero() {
+		query += " AND billing_month = ?"
+		args = append(args, monthStart(month).Format("2006-01-02"))
+	}
+	if !includeVoided {
+		query += " AND voided_at IS NULL"
+	}
+	query += " ORDER BY billing_month, created_at, id"
+
+	rows, err := data2.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanAdjustments(rows)
+}
+
+const selectAdjustments = `
+	SELECT id, member_name, billing_month, service_name, amount, reason,
+	       created_by, created_at, voided_at, COALESCE(voided_by, ''), COALESCE(void_reason, '')
+	FROM billing_adjustments`
+
+func scanAdjustments(rows *sql.Rows) ([]Adjustment, error) {
+	defer rows.Close()
+
+	adjustments := []Adjustment{}
+	for rows.Next() {
+		var (
+			a      Adjustment
+			voided sql.NullTime
+		)
+		if err := rows.Scan(&a.ID, &a.Member, &a.Month, &a.Service, &a.Amount, &a.Reason,
+			&a.Author, &a.Created, &voided, &a.VoidedBy, &a.VoidReason); err != nil {
+			return nil, err
+		}
+		if voided.Valid {
+			t := voided.Time
+			a.Voided = &t
+		}
+		adjustments = append(adjustments, a)
+	}
+	return adjustments, rows.Err()
+}
+
+// monthAdjustments returns the active adjustments of month by member, or none
+// when they cannot be loaded.
+func monthAdjustments(month time.Time) map[string][]Adjustment {
+	byMember := make(map[string][]Adjustment)
+	if !adjustmentsEnabled {
+		return byMember
+	}
+
+	adjustments, err := ListAdjustments("", month, false)
+	if err != nil {
+		log.Log(log.Error, "[billing] Failed to load adjustments for %s: %v", month.Format("2006-01"), err)
+		return byMember
+	}
+	for _, a := range adjustments {
+		byMember[a.Member] = append(byMember[a.Member], a)
+	}
+	return byMember
+}
+
+// adjustmentsTotal sums the amounts of adjustments.
+func adjustmentsTotal(adjustments []Adjustment) float64 {
+	total := 0.0
+	for _, a := range adjustments {
+		total += a.Amount
+	}
+	return total
+}
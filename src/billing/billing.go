@@ -5,13 +5,17 @@ package billing
 // ─────────────────────────────────────────────────────────────────────────────
 
 import (
-	"os"
+	"context"
+	"errors"
+	"fmt"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	cluster "github.com/ibp-network/ibp-geodns-collator/src/cluster"
+
 	cfg "github.com/ibp-network/ibp-geodns-libs/config"
 	log "github.com/ibp-network/ibp-geodns-libs/logging"
 )
@@ -47,13 +51,50 @@ var billingStore struct {
 	Summary
 }
 
-// Track last generated billing month to avoid duplicates
+// Track last generated billing month to avoid duplicates, and the months a
+// scheduled run or PDF job is writing right now so they never overlap.
 var (
 	lastGeneratedBillingMonth   time.Time
 	billingGenMutex             sync.Mutex
-	billingGenerationInProgress bool
+	billingGenerationInProgress = make(map[time.Time]bool)
+	billingGenerationDone       = sync.NewCond(&billingGenMutex)
 )
 
+// lifecycle of the background schedulers started by Init
+var (
+	runCtx    context.Context    = context.Background()
+	runCancel context.CancelFunc = func() {}
+	runWG     sync.WaitGroup
+)
+
+// GenerationStatus describes the outcome of the most recent monthly billing run.
+type GenerationStatus struct {
+	Month    time.Time // billing month that was generated
+	Finished time.Time
+	Success  bool
+}
+
+var lastGeneration struct {
+	sync.RWMutex
+	status GenerationStatus
+	ran    bool
+}
+
+// LastGenerationStatus returns the result of the last monthly billing run and
+// false if no run has happened since start-up.
+func LastGenerationStatus() (GenerationStatus, bool) {
+	lastGeneration.RLock()
+	defer lastGeneration.RUnlock()
+	return lastGeneration.status, lastGeneration.ran
+}
+
+// LastRefresh returns when the billing snapshot was last rebuilt.
+func LastRefresh() time.Time {
+	billingStore.RLock()
+	defer billingStore.RUnlock()
+	return billingStore.Refresh
+}
+
 // GetSummary returns a deep-copy of the current billing snapshot.
 func GetSummary() Summary {
 	billingStore.RLock()
@@ -85,31 +126,56 @@ func GetSummary() Summary {
 //  Initialisation
 // ─────────────────────────────────────────────────────────────────────────────
 
+// leaderPollInterval is how often a follower checks whether it was elected.
+const leaderPollInterval = 5 * time.Second
+
 // Init kicks off periodic billing refreshes and monthly billing PDF generation.
+// Every node refreshes its own snapshot for the read API; PDF generation only
+// runs on the elected leader.
 func Init() {
+	runCtx, runCancel = context.WithCancel(context.Background())
+
+	// restore generation state persisted by previous runs
+	initRunState()
+	initMaintenanceState()
+	initSnapshotState()
+	initHistoryState()
+	initAdjustmentState()
+	initInvoiceState()
+	initRateState()
+
 	// synchronous first refresh with verbose output
 	refresh(true)
 
 	// hourly refresh (top of the hour, UTC)
-	go func() {
+	goScheduled(func() {
 		for {
 			next := time.Now().UTC().Truncate(time.Hour).Add(time.Hour)
-			time.Sleep(time.Until(next))
+			if !sleepUntil(next) {
+				return
+			}
 			refresh(false)
 		}
-	}()
+	})
+
+	// Record pricing, resource and assignment changes for proration
+	goScheduled(trackConfigVersions)
 
 	// Daily service cost PDF generation at 00:05 UTC
-	go func() {
+	goScheduled(func() {
 		for {
 			next := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour).Add(5 * time.Minute)
-			time.Sleep(time.Until(next))
-			generateServiceCostPDF()
+			if !sleepUntil(next) {
+				return
+			}
+			if leaderOnly("service cost PDF") {
+				generateServiceCostPDF()
+			}
 		}
-	}()
+	})
 
 	// Monthly member billing PDF generation
-	go func() {
+	goScheduled(func() {
 		for {
 			// Calculate next month's first day at 00:05 UTC
 			now := time.Now().UTC()
@@ -124,44 +190,141 @@ func Init() {
 			log.Log(log.Info, "[billing] Next member billing PDF generation scheduled for %s (in %v)",
 				nextMonth.Format("2006-01-02 15:04:05"), waitDuration)
 
-			time.Sleep(waitDuration)
-			generateMonthlyBillingPDF()
+			if !sleepUntil(nextMonth) {
+				return
+			}
+			if leaderOnly("member billing PDF") {
+				generateMonthlyBillingPDF()
+			}
 		}
-	}()
+	})
 
-	// Generate initial PDFs if we haven't generated for the previous month yet
-	go func() {
-		time.Sleep(5 * time.Second) // Reduced delay since DB is now ready
+	// Generate initial PDFs if we haven't generated for the previous month yet,
+	// and again whenever this node takes over leadership
+	goScheduled(func() {
+		if !sleepUntil(time.Now().Add(5 * time.Second)) { // Reduced delay since DB is now ready
+			return
+		}
 
-		// Check if we need to generate last month's billing
-		now := time.Now().UTC()
-		if now.Day() >= 1 { // We're past the first of the month
-			lastMonth := now.AddDate(0, -1, 0)
-			lastMonthStart := time.Date(lastMonth.Year(), lastMonth.Month(), 1, 0, 0, 0, 0, time.UTC)
+		for {
+			if !waitForLeadership(true) {
+				return
+			}
 
-			billingGenMutex.Lock()
-			needsGeneration := lastGeneratedBillingMonth.Before(lastMonthStart)
-			billingGenMutex.Unlock()
+			// Catch up on every month without a successful billing run
+			for _, month := range pendingBillingMonths(time.Now().UTC()) {
+				if stopping() {
+					return
+				}
+				log.Log(log.Info, "[billing] Generating missing member billing PDFs for %s", month.Format("January 2006"))
+				generateBillingForMonth(month)
+			}
 
-			if needsGeneration {
-				log.Log(log.Info, "[billing] Generating initial member billing PDF for previous month")
-				generateMonthlyBillingPDF()
+			// Always generate current service cost PDF
+			generateServiceCostPDF()
+
+			if !waitForLeadership(false) {
+				return
 			}
 		}
+	})
+}
+
+// leaderOnly reports whether a scheduled job may run on this node.
+func leaderOnly(job string) bool {
+	if cluster.IsLeader() {
+		return true
+	}
+	log.Log(log.Debug, "[billing] follower node — skipping scheduled %s", job)
+	return false
+}
+
+// waitForLeadership blocks until this node's leadership equals leader; it
+// returns false when Shutdown is called first.
+func waitForLeadership(leader bool) bool {
+	for cluster.IsLeader() != leader {
+		if !sleepUntil(time.Now().Add(leaderPollInterval)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Shutdown stops the schedulers started by Init and waits, bounded by ctx, for
+// any PDF generation that is already running to wind down.
+func Shutdown(ctx context.Context) error {
+	runCancel()
+
+	done := make(chan struct{})
+	go func() {
+		runWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Log(log.Info, "[billing] schedulers stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("billing shutdown: %w", ctx.Err())
+	}
+}
 
-		// Always generate current service cost PDF
-		generateServiceCostPDF()
+// goScheduled runs fn in a goroutine tracked by Shutdown.
+func goScheduled(fn func()) {
+	runWG.Add(1)
+	go func() {
+		defer runWG.Done()
+		fn()
 	}()
 }
 
+// sleepUntil blocks until t or until Shutdown is called; it reports whether
+// the caller should carry on.
+func sleepUntil(t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-runCtx.Done():
+		return false
+	}
+}
+
+// stopping reports whether Shutdown has been requested.
+func stopping() bool {
+	return runCtx.Err() != nil
+}
+
 // ─────────────────────────────────────────────────────────────────────────────
 //  Refresh logic
 // ─────────────────────────────────────────────────────────────────────────────
 
 func refresh(verbose bool) {
 	start := time.Now()
-	c := cfg.GetConfig()
+	newMemberCosts, newServiceCosts := computeCosts(cfg.GetConfig(), time.Now().UTC())
+
+	// publish atomically
+	billingStore.Lock()
+	billingStore.Members = newMemberCosts
+	billingStore.Services = newServiceCosts
+	billingStore.Refresh = time.Now().UTC()
+	billingStore.Unlock()
 
+	duration := time.Since(start).Round(time.Millisecond)
+	log.Log(log.Info, "[billing] refresh complete — %d members, %d services, in %s",
+		len(newMemberCosts), len(newServiceCosts), duration)
+
+	if verbose {
+		logDetails(newMemberCosts, newServiceCosts)
+	}
+}
+
+// computeCosts prices every active service assignment of c's members that
+// have joined by now.
+func computeCosts(c cfg.Config, now time.Time) (map[string]MemberCost, map[string]ServiceCost) {
 	newMemberCosts := make(map[string]MemberCost)
 	newServiceCosts := make(map[string]ServiceCost)
 
@@ -177,6 +340,11 @@ func refresh(verbose bool) {
 	}
 
 	for memName, mem := range c.Members {
+		if joined := joinedTime(mem.Membership.Joined); joined.After(now) {
+			log.Log(log.Debug, "[billing] member %s joins %s — not billed yet", memName, joined.Format("2006-01-02"))
+			continue
+		}
+
 		regionKey := strings.ToLower(strings.TrimSpace(mem.Location.Region))
 		price, ok := priceByRegion[regionKey]
 		if !ok {
@@ -222,20 +390,7 @@ func refresh(verbose bool) {
 		}
 	}
 
-	// publish atomically
-	billingStore.Lock()
-	billingStore.Members = newMemberCosts
-	billingStore.Services = newServiceCosts
-	billingStore.Refresh = time.Now().UTC()
-	billingStore.Unlock()
-
-	duration := time.Since(start).Round(time.Millisecond)
-	log.Log(log.Info, "[billing] refresh complete — %d members, %d services, in %s",
-		len(newMemberCosts), len(newServiceCosts), duration)
-
-	if verbose {
-		logDetails(newMemberCosts, newServiceCosts)
-	}
+	return newMemberCosts, newServiceCosts
 }
 
 // ─────────────────────────────────────────────────────────────────────────────
@@ -243,15 +398,8 @@ func refresh(verbose bool) {
 // ─────────────────────────────────────────────────────────────────────────────
 
 func generateServiceCostPDF() {
-	conf := cfg.GetConfig()
-	tmpDir := resolveTempDir(conf)
-	if tmpDir == "" {
-		log.Log(log.Warn, "[billing] tmp directory not configured — service cost PDF skipped")
-		return
-	}
-
 	snap := GetSummary()
-	if err := writeServiceCostPDF(&snap, tmpDir); err != nil {
+	if _, err := writeServiceCostPDF(&snap); err != nil {
 		log.Log(log.Error, "[billing] failed to write service-cost PDF: %v", err)
 	}
 }
@@ -259,63 +407,70 @@ func generateServiceCostPDF() {
 func generateMonthlyBillingPDF() {
 	// Get the previous month
 	now := time.Now().UTC()
-	previousMonth := now.AddDate(0, -1, 0)
-	billingMonth := time.Date(previousMonth.Year(), previousMonth.Month(), 1, 0, 0, 0, 0, time.UTC)
+	generateBillingForMonth(monthStart(now.AddDate(0, -1, 0)))
+}
 
-	// Check if we've already generated for this month
+// generateBillingForMonth writes the overview and member PDFs for billingMonth
+// and records the attempt in billing_runs.
+func generateBillingForMonth(billingMonth time.Time) {
+	// Check if we (or a previous leader) already generated this month, once
+	// any run or PDF job still writing it has finished
+	syncLastGeneratedMonth()
 	billingGenMutex.Lock()
+	if billingGenerationInProgress[billingMonth] {
+		log.Log(log.Info, "[billing] Waiting for the PDF generation in progress for %s", billingMonth.Format("January 2006"))
+	}
+	for billingGenerationInProgress[billingMonth] {
+		billingGenerationDone.Wait()
+	}
 	if !lastGeneratedBillingMonth.Before(billingMonth) {
 		billingGenMutex.Unlock()
 		log.Log(log.Info, "[billing] Member billing PDF already generated for %s", billingMonth.Format("January 2006"))
 		return
 	}
-	if billingGenerationInProgress {
-		billingGenMutex.Unlock()
-		log.Log(log.Info, "[billing] Member billing PDF generation already in progress for %s", billingMonth.Format("January 2006"))
-		return
-	}
-	billingGenerationInProgress = true
+	billingGenerationInProgress[billingMonth] = true
 	billingGenMutex.Unlock()
 
+	runID := startRun(billingMonth)
+	status := RunFailed
+	var (
+		runErrs []string
+		files   []string
+	)
+
 	success := false
 	defer func() {
+		if success {
+			status = RunSucceeded
+		}
+		finishRun(runID, status, strings.Join(runErrs, "; "), files)
+
 		billingGenMutex.Lock()
-		billingGenerationInProgress = false
+		delete(billingGenerationInProgress, billingMonth)
 		if success && lastGeneratedBillingMonth.Before(billingMonth) {
 			lastGeneratedBillingMonth = billingMonth
 		}
+		billingGenerationDone.Broadcast()
 		billingGenMutex.Unlock()
+
+		lastGeneration.Lock()
+		lastGeneration.status = GenerationStatus{Month: billingMonth, Finished: time.Now().UTC(), Success: success}
+		lastGeneration.ran = true
+		lastGeneration.Unlock()
 	}()
 
 	log.Log(log.Info, "[billing] Starting member billing PDF generation for %s", billingMonth.Format("January 2006"))
 
-	conf := cfg.GetConfig()
-	tmpDir := resolveTempDir(conf)
-	if tmpDir == "" {
-		log.Log(log.Warn, "[billing] tmp directory not configured — member billing PDF skipped")
-		return
-	}
-
-	// Create month directory (YYYY-MM format)
-	monthDir := filepath.Join(tmpDir, billingMonth.Format("2006-01"))
-	if err := os.MkdirAll(monthDir, 0755); err != nil {
-		log.Log(log.Error, "[billing] Failed to create month directory: %v", err)
-		return
-	}
-
-	snap := GetSummary()
-
-	// Calculate SLA for the billing month
-	sla, err := CalculateSLAAdjustments(billingMonth, &snap)
+	snap, err := freezeSnapshot(billingMonth)
 	if err != nil {
-		log.Log(log.Error, "[billing] failed SLA calculation: %v", err)
-		// Continue anyway with empty SLA data
-		sla = make(SLASummary)
+		log.Log(log.Error, "[billing] failed to prepare billing snapshot: %v", err)
+		runErrs = append(runErrs, err.Error())
+		return
 	}
 
 	// Log members not meeting SLA
 	violationCount := 0
-	for memberName, services := range sla {
+	for memberName, services := range snap.SLA {
 		for serviceName, breakdown := range services {
 			if !breakdown.MeetsSLA {
 				violationCount++
@@ -331,22 +486,84 @@ func generateMonthlyBillingPDF() {
 		log.Log(log.Info, "[billing] Total SLA violations for %s: %d", billingMonth.Format("January 2006"), violationCount)
 	}
 
+	// Keys written by this run, removed again if shutdown interrupts it so
+	// the next start regenerates a complete set.
+	var written []string
+	interrupted := func() bool {
+		if !stopping() {
+			return false
+		}
+		log.Log(log.Warn, "[billing] Shutdown requested — discarding partial billing run for %s", billingMonth.Format("January 2006"))
+		for _, key := range written {
+			if err := Artifacts().Delete(key); err != nil {
+				log.Log(log.Error, "[billing] failed to remove partial PDF %s: %v", key, err)
+			}
+		}
+		written = nil
+		status = RunInterrupted
+		runErrs = append(runErrs, "shutdown requested")
+		return true
+	}
+	defer func() {
+		files = append(files, written...)
+	}()
+
 	// Generate the monthly overview PDF
-	hadError := false
-	if err := writeMonthlyOverviewPDF(&snap, sla, monthDir, billingMonth); err != nil {
-		hadError = true
+	if overview, err := writeMonthlyOverviewPDF(snap); err != nil {
+		runErrs = append(runErrs, fmt.Sprintf("overview PDF: %v", err))
 		log.Log(log.Error, "[billing] failed to write monthly overview PDF: %v", err)
+	} else {
+		written = append(written, overview)
+	}
+
+	// Spreadsheet exports of the same figures for finance
+	exports, err := writeExports(snap)
+	written = append(written, exports...)
+	if err != nil {
+		runErrs = append(runErrs, fmt.Sprintf("breakdown export: %v", err))
+		log.Log(log.Error, "[billing] failed to write billing breakdown export: %v", err)
 	}
 
 	// Generate individual member PDFs
-	for memberName := range snap.Members {
-		if err := writeMemberPDF(memberName, &snap, sla, monthDir, billingMonth); err != nil {
-			hadError = true
+	for memberName := range snap.Summary.Members {
+		if interrupted() {
+			return
+		}
+		// Issue (or reuse) the member's invoice so its number is on the PDF
+		inv, err := issueInvoice(memberName, snap)
+		if err != nil {
+			runErrs = append(runErrs, fmt.Sprintf("invoice %s: %v", memberName, err))
+			log.Log(log.Error, "[billing] failed to issue invoice for %s: %v", memberName, err)
+			continue
+		}
+		if key, err := writeMemberPDF(memberName, snap, inv); err != nil {
+			runErrs = append(runErrs, fmt.Sprintf("member PDF %s: %v", memberName, err))
 			log.Log(log.Error, "[billing] failed to write member PDF for %s: %v", memberName, err)
+		} else {
+			written = append(written, key)
 		}
 	}
 
-	if hadError {
+	// Treasury proposal from the same figures, with the invoice numbers above
+	if interrupted() {
+		return
+	}
+	if proposal, err := writeProposal(snap); err != nil {
+		runErrs = append(runErrs, fmt.Sprintf("treasury proposal: %v", err))
+		log.Log(log.Error, "[billing] failed to write treasury proposal: %v", err)
+	} else {
+		written = append(written, proposal)
+	}
+
+	// Manifest last, so it covers every file above
+	if manifest, err := writeManifest(billingMonth); err != nil {
+		runErrs = append(runErrs, fmt.Sprintf("manifest: %v", err))
+		log.Log(log.Error, "[billing] failed to write manifest: %v", err)
+	} else {
+		written = append(written, manifest)
+	}
+
+	if len(runErrs) > 0 {
 		log.Log(log.Warn, "[billing] Monthly billing generation for %s completed with errors; will retry on next run", billingMonth.Format("January 2006"))
 		return
 	}
@@ -359,6 +576,33 @@ func generateMonthlyBillingPDF() {
 //  Helpers
 // ─────────────────────────────────────────────────────────────────────────────
 
+// freezeSnapshot returns the snapshot to bill month from. The first successful
+// calculation is persisted so later config changes do not alter the month; a
+// snapshot whose SLA calculation failed is used without being persisted.
+func freezeSnapshot(month time.Time) (*Snapshot, error) {
+	snap, err := LoadSnapshot(month)
+	switch {
+	case err == nil:
+		log.Log(log.Info, "[billing] Using billing snapshot of %s taken %s",
+			month.Format("January 2006"), snap.Created.Format("2006-01-02 15:04:05"))
+		return snap, nil
+	case errors.Is(err, ErrSnapshotNotFound):
+		snap, err = LiveSnapshot(month)
+		if err != nil {
+			log.Log(log.Error, "[billing] failed SLA calculation: %v", err)
+			// Continue anyway with empty SLA data, but do not freeze it
+			log.Log(log.Warn, "[billing] billing snapshot for %s not saved", month.Format("January 2006"))
+			return snap, nil
+		}
+		if err := saveSnapshot(snap); err != nil {
+			return nil, fmt.Errorf("save snapshot: %w", err)
+		}
+		return snap, nil
+	default:
+		return nil, fmt.Errorf("load snapshot: %w", err)
+	}
+}
+
 func costForServiceInstance(res cfg.Resources, price cfg.IaasPricing) float64 {
 	if res.Nodes == 0 {
 		return 0
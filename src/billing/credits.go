@@ -0,0 +1,134 @@
+package billing
+
+import (
+	"fmt"
+
+	common "github.com/ibp-network/ibp-geodns-collator/src/common"
+
+	cfg "github.com/ibp-network/ibp-geodns-libs/config"
+)
+
+// ServiceCredit is the credit granted on one member/service for a month.
+type ServiceCredit struct {
+	BaseCost      float64
+	Credit        float64
+	Billed        float64
+	CreditPercent float64 // share of BaseCost credited, 0-100
+	Capped        bool    // reduced by the service or member cap
+}
+
+// MemberCredit groups a member's service credits after caps are applied.
+type MemberCredit struct {
+	Services    map[string]ServiceCredit
+	TotalBase   float64
+	TotalCredit float64
+	TotalBilled float64
+	Capped      bool
+}
+
+// creditPolicyFor returns the credit policy for a member's membership level.
+func creditPolicyFor(memberID string) common.CreditPolicy {
+	credits := common.GetSettings().Credits
+	if m, ok := cfg.GetConfig().Members[memberID]; ok {
+		return credits.PolicyForLevel(m.Membership.Level)
+	}
+	return credits.Policy
+}
+
+// CalculateMemberCredits applies the member's credit policy to every service
+// in serviceCosts. This is the single place base cost, SLA and policy turn into
+// billed amounts; the API, the summary and the PDFs all go through it.
+func CalculateMemberCredits(memberID string, serviceCosts map[string]float64, sla SLASummary) MemberCredit {
+	return applyCreditPolicy(creditPolicyFor(memberID), serviceCosts, func(svc string) float64 {
+		return sla.Breakdown(memberID, svc).Uptime
+	})
+}
+
+// applyCreditPolicy computes the credits of policy for serviceCosts, with
+// uptime returning each service's uptime percentage.
+func applyCreditPolicy(policy common.CreditPolicy, serviceCosts map[string]float64, uptime func(svc string) float64) MemberCredit {
+	out := MemberCredit{Services: make(map[string]ServiceCredit, len(serviceCosts))}
+
+	for svc, base := range serviceCosts {
+		sc := ServiceCredit{BaseCost: base}
+		sc.Credit, sc.Capped = serviceCredit(policy, base, uptime(svc))
+		out.Services[svc] = sc
+		out.TotalBase += base
+		out.TotalCredit += sc.Credit
+	}
+
+	// Member cap: scale every service credit down by the same factor
+	if policy.MemberCap > 0 && out.TotalBase > 0 {
+		limit := out.TotalBase * policy.MemberCap / 100.0
+		if out.TotalCredit > limit {
+			factor := limit / out.TotalCredit
+			for svc, sc := range out.Services {
+				sc.Credit *= factor
+				sc.Capped = true
+				out.Services[svc] = sc
+			}
+			out.TotalCredit = limit
+			out.Capped = true
+		}
+	}
+
+	for svc, sc := range out.Services {
+		sc.Billed = sc.BaseCost - sc.Credit
+		if sc.BaseCost > 0 {
+			sc.CreditPercent = sc.Credit / sc.BaseCost * 100.0
+		}
+		if sc.Capped {
+			out.Capped = true
+		}
+		out.Services[svc] = sc
+	}
+	out.TotalBilled = out.TotalBase - out.TotalCredit
+
+	return out
+}
+
+// serviceCredit returns the credit for one service before the member cap.
+// Without steps the credit is prorated linearly by downtime.
+func serviceCredit(policy common.CreditPolicy, base, uptime float64) (float64, bool) {
+	var pct float64
+	if len(policy.Steps) == 0 {
+		pct = 100.0 - uptime
+	} else {
+		matched := false
+		lowest := 0.0
+		for _, st := range policy.Steps {
+			if uptime < st.Below && (!matched || st.Below < lowest) {
+				matched = true
+				lowest = st.Below
+				pct = st.Credit
+			}
+		}
+	}
+
+	if pct < 0 {
+		pct = 0
+	}
+	if pct > 100 {
+		pct = 100
+	}
+
+	capped := false
+	if policy.ServiceCap > 0 && pct > policy.ServiceCap {
+		pct = policy.ServiceCap
+		capped = true
+	}
+
+	return base * pct / 100.0, capped
+}
+
+// creditNote describes a service credit for the PDFs, e.g. " - 25.00% credit (capped)".
+func creditNote(sc ServiceCredit) string {
+	if sc.Credit <= 0 {
+		return ""
+	}
+	note := fmt.Sprintf(" - %.2f%% credit", sc.CreditPercent)
+	if sc.Capped {
+		note += " (capped)"
+	}
+	return note
+}
@@ -0,0 +1,118 @@
+package billing
+
+import (
+	"math"
+	"testing"
+
+	common "github.com/ibp-network/ibp-geodns-collator/src/common"
+)
+
+func TestServiceCredit(t *testing.T) {
+	steps := []common.CreditStep{
+		{Below: 99.9, Credit: 10},
+		{Below: 99, Credit: 25},
+		{Below: 95, Credit: 100},
+	}
+
+	tests := []struct {
+		name       string
+		policy     common.CreditPolicy
+		uptime     float64
+		wantCredit float64
+		wantCapped bool
+	}{
+		{"meets every step", common.CreditPolicy{Steps: steps}, 99.95, 0, false},
+		{"exactly at the first threshold", common.CreditPolicy{Steps: steps}, 99.9, 0, false},
+		{"just below the first threshold", common.CreditPolicy{Steps: steps}, 99.89, 10, false},
+		{"exactly at a lower threshold keeps the step above", common.CreditPolicy{Steps: steps}, 99, 10, false},
+		{"lowest matching step wins", common.CreditPolicy{Steps: steps}, 98, 25, false},
+		{"full credit", common.CreditPolicy{Steps: steps}, 50, 100, false},
+		{"unordered steps", common.CreditPolicy{Steps: []common.CreditStep{steps[2], steps[0], steps[1]}}, 98, 25, false},
+		{"linear without steps", common.CreditPolicy{}, 97.5, 2.5, false},
+		{"linear at full uptime", common.CreditPolicy{}, 100, 0, false},
+		{"linear never negative", common.CreditPolicy{}, 100.5, 0, false},
+		{"service cap", common.CreditPolicy{Steps: steps, ServiceCap: 30}, 50, 30, true},
+		{"service cap not reached", common.CreditPolicy{Steps: steps, ServiceCap: 30}, 98, 25, false},
+		{"service cap on linear credit", common.CreditPolicy{ServiceCap: 5}, 90, 5, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credit, capped := serviceCredit(tt.policy, 100, tt.uptime)
+			if math.Abs(credit-tt.wantCredit) > 1e-9 {
+				t.Errorf("credit = %v, want %v", credit, tt.wantCredit)
+			}
+			if capped != tt.wantCapped {
+				t.Errorf("capped = %v, want %v", capped, tt.wantCapped)
+			}
+		})
+	}
+}
+
+func TestApplyCreditPolicy(t *testing.T) {
+	steps := []common.CreditStep{{Below: 99, Credit: 50}}
+	costs := map[string]float64{"RPC": 200, "ETH": 100, "BOOT": 100}
+	uptime := map[string]float64{"RPC": 90, "ETH": 90, "BOOT": 100}
+
+	tests := []struct {
+		name        string
+		policy      common.CreditPolicy
+		wantCredits map[string]float64
+		wantCapped  bool
+	}{
+		{
+			name:        "no member cap",
+			policy:      common.CreditPolicy{Steps: steps},
+			wantCredits: map[string]float64{"RPC": 100, "ETH": 50, "BOOT": 0},
+		},
+		{
+			name:        "member cap above total credit",
+			policy:      common.CreditPolicy{Steps: steps, MemberCap: 50},
+			wantCredits: map[string]float64{"RPC": 100, "ETH": 50, "BOOT": 0},
+		},
+		{
+			// 150 credited of 400 base, capped at 20% = 80
+			name:        "member cap scales every service",
+			policy:      common.CreditPolicy{Steps: steps, MemberCap: 20},
+			wantCredits: map[string]float64{"RPC": 80 * 100 / 150.0, "ETH": 80 * 50 / 150.0, "BOOT": 0},
+			wantCapped:  true,
+		},
+		{
+			// service cap first (25% each = 50 + 25), then member cap 10% = 40
+			name:        "service and member cap",
+			policy:      common.CreditPolicy{Steps: steps, ServiceCap: 25, MemberCap: 10},
+			wantCredits: map[string]float64{"RPC": 40 * 50 / 75.0, "ETH": 40 * 25 / 75.0, "BOOT": 0},
+			wantCapped:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := applyCreditPolicy(tt.policy, costs, func(svc string) float64 { return uptime[svc] })
+
+			total := 0.0
+			for svc, want := range tt.wantCredits {
+				sc := got.Services[svc]
+				if math.Abs(sc.Credit-want) > 1e-9 {
+					t.Errorf("%s credit = %v, want %v", svc, sc.Credit, want)
+				}
+				if math.Abs(sc.Billed-(costs[svc]-want)) > 1e-9 {
+					t.Errorf("%s billed = %v, want %v", svc, sc.Billed, costs[svc]-want)
+				}
+				if math.Abs(sc.CreditPercent-want/costs[svc]*100) > 1e-9 {
+					t.Errorf("%s credit percent = %v, want %v", svc, sc.CreditPercent, want/costs[svc]*100)
+				}
+				if tt.wantCapped && tt.policy.ServiceCap == 0 && !sc.Capped {
+					t.Errorf("%s not marked capped by the member cap", svc)
+				}
+				total += want
+			}
+			if math.Abs(got.TotalCredit-total) > 1e-9 || math.Abs(got.TotalBilled-(400-total)) > 1e-9 || got.TotalBase != 400 {
+				t.Errorf("totals = base %v credit %v billed %v, want 400 / %v / %v", got.TotalBase, got.TotalCredit, got.TotalBilled, total, 400-total)
+			}
+			if got.Capped != tt.wantCapped {
+				t.Errorf("Capped = %v, want %v", got.Capped, tt.wantCapped)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package billing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	data2 "github.com/ibp-network/ibp-geodns-libs/data2"
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+
+	common "github.com/ibp-network/ibp-geodns-collator/src/common"
+)
+
+// BaseCurrency is the currency all prices and bills are computed in.
+const BaseCurrency = "USD"
+
+var (
+	// ErrUnknownCurrency is returned for currencies not listed in Currencies.Enabled.
+	ErrUnknownCurrency = errors.New("currency not enabled")
+	// ErrRateNotFound is returned when no rate is known for a month or earlier.
+	ErrRateNotFound = errors.New("exchange rate not found")
+)
+
+// ExchangeRate converts USD amounts of a billing month into another currency.
+// UsdPerUnit is the USD value of one unit (e.g. 4.36 for DOT at $4.36) and
+// Date the day the rate was taken. A month without a rate of its own uses the
+// most recent earlier month.
+type ExchangeRate struct {
+	Currency   string
+	Month      time.Time
+	UsdPerUnit float64
+	Date       time.Time
+	Source     string // "file" or "api"
+}
+
+// Convert returns usd in units of the rate's currency.
+func (r ExchangeRate) Convert(usd float64) float64 {
+	if r.UsdPerUnit <= 0 {
+		return 0
+	}
+	return usd / r.UsdPerUnit
+}
+
+// usdRate is the identity rate of the base currency.
+func usdRate(month time.Time) ExchangeRate {
+	return ExchangeRate{Currency: BaseCurrency, Month: monthStart(month), UsdPerUnit: 1, Date: monthStart(month), Source: "base"}
+}
+
+// Currencies returns the enabled currencies besides USD.
+func Currencies() []common.CurrencyConfig {
+	return common.GetSettings().Currencies.Enabled
+}
+
+// FormatAmount renders an amount already converted to currency, e.g.
+// "$1234.50" or "12.5000 DOT".
+func FormatAmount(amount float64, currency string) string {
+	if currency == "" || strings.EqualFold(currency, BaseCurrency) {
+		return fmt.Sprintf("$%.2f", amount)
+	}
+	decimals := 2
+	if c, ok := common.GetSettings().Currencies.Lookup(currency); ok {
+		decimals = c.Decimals
+	}
+	return fmt.Sprintf("%.*f %s", decimals, amount, strings.ToUpper(currency))
+}
+
+const createRatesTable = `
+	CREATE TABLE IF NOT EXISTS billing_exchange_rates (
+		currency      VARCHAR(10)    NOT NULL,
+		billing_month DATE           NOT NULL,
+		usd_per_unit  DECIMAL(24,10) NOT NULL,
+		rate_date     DATE           NOT NULL,
+		updated_by    VARCHAR(255)   NOT NULL,
+		updated_at    DATETIME       NOT NULL,
+		PRIMARY KEY (currency, billing_month)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci`
+
+// ratesEnabled is false when the billing_exchange_rates table could not be
+// prepared; only rates from Currencies.RatesFile are then available.
+var ratesEnabled bool
+
+// initRateState prepares the billing_exchange_rates table.
+func initRateState() {
+	if data2.DB == nil {
+		log.Log(log.Warn, "[billing] database unavailable — API-provided exchange rates disabled")
+		return
+	}
+
+	if _, err := data2.DB.Exec(createRatesTable); err != nil {
+		log.Log(log.Error, "[billing] failed to prepare billing_exchange_rates table: %v", err)
+		return
+	}
+	ratesEnabled = true
+}
+
+// rateFile caches Currencies.RatesFile, reloaded when it changes on disk.
+var rateFile struct {
+	sync.Mutex
+	path    string
+	modTime time.Time
+	rates   []ExchangeRate
+}
+
+// rateFileEntry is one rate in Currencies.RatesFile, which maps currency code
+// → "YYYY-MM" → entry:
+//
+//	{ "DOT": { "2024-09": { "UsdPerUnit": 4.36, "Date": "2024-09-30" } } }
+type rateFileEntry struct {
+	UsdPerUnit float64 `json:"UsdPerUnit"`
+	Date       string  `json:"Date"`
+}
+
+// fileRates returns the rates of Currencies.RatesFile, or none when it is
+// unset or unreadable.
+func fileRates() []ExchangeRate {
+	path := common.GetSettings().Currencies.RatesFile
+	if path == "" {
+		return nil
+	}
+
+	rateFile.Lock()
+	defer rateFile.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Log(log.Error, "[billing] exchange rate file %s unavailable: %v", path, err)
+		return rateFile.rates
+	}
+	if path == rateFile.path && info.ModTime().Equal(rateFile.modTime) {
+		return rateFile.rates
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		log.Log(log.Error, "[billing] failed to read exchange rate file %s: %v", path, err)
+		return rateFile.rates
+	}
+	rates, err := parseRateFile(raw)
+	if err != nil {
+		log.Log(log.Error, "[billing] invalid exchange rate file %s: %v", path, err)
+		return rateFile.rates
+	}
+
+	rateFile.path, rateFile.modTime, rateFile.rates = path, info.ModTime(), rates
+	log.Log(log.Info, "[billing] Loaded %d exchange rates from %s", len(rates), path)
+	return rates
+}
+
+func parseRateFile(raw []byte) ([]ExchangeRate, error) {
+	var doc map[string]map[string]rateFileEntry
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	rates := []ExchangeRate{}
+	for code, months := range doc {
+		for m, e := range months {
+			month, err := time.Parse("2006-01", m)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid month %q", code, m)
+			}
+			if e.UsdPerUnit <= 0 || math.IsInf(e.UsdPerUnit, 0) {
+				return nil, fmt.Errorf("%s %s: UsdPerUnit must be positive", code, m)
+			}
+			date := month.AddDate(0, 1, -1)
+			if e.Date != "" {
+				if date, err = time.Parse("2006-01-02", e.Date); err != nil {
+					return nil, fmt.Errorf("%s %s: invalid date %q", code, m, e.Date)
+				}
+			}
+			rates = append(rates, ExchangeRate{
+				Currency:   strings.ToUpper(code),
+				Month:      month,
+				UsdPerUnit: e.UsdPerUnit,
+				Date:       date,
+				Source:     "file",
+			})
+		}
+	}
+	return rates, nil
+}
+
+// SetRate stores an API-provided rate, replacing any earlier one for the same
+// currency and month. API rates take precedence over the rates file.
+func SetRate(rate ExchangeRate, author string) error {
+	if !ratesEnabled {
+		return fmt.Errorf("exchange rates are not available")
+	}
+	if _, ok := common.GetSettings().Currencies.Lookup(rate.Currency); !ok {
+		return ErrUnknownCurrency
+	}
+	if rate.UsdPerUnit <= 0 || math.IsInf(rate.UsdPerUnit, 0) || math.IsNaN(rate.UsdPerUnit) {
+		return fmt.Errorf("exchange rate must be positive")
+	}
+
+	_, err := data2.DB.Exec(`
+		INSERT INTO billing_exchange_rates (currency, billing_month, usd_per_unit, rate_date, updated_by, updated_at)
+		VALUES (?, ?, ?, ?, ?, UTC_TIMESTAMP())
+		ON DUPLICATE KEY UPDATE usd_per_unit = VALUES(usd_per_unit), rate_date = VALUES(rate_date),
+			updated_by = VALUES(updated_by), updated_at = VALUES(updated_at)`,
+		strings.ToUpper(rate.Currency), monthStart(rate.Month).Format("2006-01-02"),
+		rate.UsdPerUnit, rate.Date.Format("2006-01-02"), author)
+	return err
+}
+
+// ListRates returns the known rates of currency (all enabled currencies when
+// empty), oldest month first, with API rates replacing file rates.
+func ListRates(currency string) ([]ExchangeRate, error) {
+	type key struct{ code, month string }
+	merged := make(map[key]ExchangeRate)
+	for _, r := range fileRates() {
+		merged[key{r.Currency, r.Month.Format("2006-01")}] = r
+	}
+
+	if ratesEnabled {
+		rows, err := data2.DB.Query(`SELECT currency, billing_month, usd_per_unit, rate_date FROM billing_exchange_rates`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			r := ExchangeRate{Source: "api"}
+			if err := rows.Scan(&r.Currency, &r.Month, &r.UsdPerUnit, &r.Date); err != nil {
+				return nil, err
+			}
+			r.Month = monthStart(r.Month)
+			merged[key{r.Currency, r.Month.Format("2006-01")}] = r
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	settings := common.GetSettings().Currencies
+	rates := []ExchangeRate{}
+	for _, r := range merged {
+		if _, ok := settings.Lookup(r.Currency); !ok {
+			continue
+		}
+		if currency != "" && !strings.EqualFold(r.Currency, currency) {
+			continue
+		}
+		rates = append(rates, r)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		if !rates[i].Month.Equal(rates[j].Month) {
+			return rates[i].Month.Before(rates[j].Month)
+		}
+		return rates[i].Currency < rates[j].Currency
+	})
+	return rates, nil
+}
+
+// RateFor returns the rate converting month's USD amounts into currency.
+func RateFor(currency string, month time.Time) (ExchangeRate, error) {
+	if currency == "" || strings.EqualFold(currency, BaseCurrency) {
+		return usdRate(month), nil
+	}
+	if _, ok := common.GetSettings().Currencies.Lookup(currency); !ok {
+		return ExchangeRate{}, ErrUnknownCurrency
+	}
+
+	rates, err := ListRates(currency)
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+	if r, ok := pickRate(rates, month); ok {
+		return r, nil
+	}
+	return ExchangeRate{}, ErrRateNotFound
+}
+
+// pickRate returns the rate of month, or of the latest earlier month.
+func pickRate(rates []ExchangeRate, month time.Time) (ExchangeRate, bool) {
+	month = monthStart(month)
+	var (
+		best  ExchangeRate
+		found bool
+	)
+	for _, r := range rates {
+		if r.Month.After(month) {
+			continue
+		}
+		if !found || r.Month.After(best.Month) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// monthRates returns the rate of month for every enabled currency that has
+// one; currencies without a rate are logged and left out.
+func monthRates(month time.Time) []ExchangeRate {
+	rates := []ExchangeRate{}
+	for _, c := range Currencies() {
+		r, err := RateFor(c.Code, month)
+		if err != nil {
+			log.Log(log.Warn, "[billing] no %s exchange rate for %s: %v", c.Code, month.Format("2006-01"), err)
+			continue
+		}
+		rates = append(rates, r)
+	}
+	return rates
+}
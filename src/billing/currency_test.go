@@ -0,0 +1,75 @@
+package billing
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseRateFile(t *testing.T) {
+	rates, err := parseRateFile([]byte(`{
+		"dot":  { "2024-09": { "UsdPerUnit": 4.0, "Date": "2024-09-30" } },
+		"USDC": { "2024-09": { "UsdPerUnit": 1.0 } }
+	}`))
+	if err != nil {
+		t.Fatalf("parseRateFile: %v", err)
+	}
+	if len(rates) != 2 {
+		t.Fatalf("got %d rates, want 2", len(rates))
+	}
+	for _, r := range rates {
+		switch r.Currency {
+		case "DOT":
+			if !r.Date.Equal(at("2024-09-30 00:00")) {
+				t.Errorf("DOT date = %s", r.Date)
+			}
+			if got := r.Convert(100); math.Abs(got-25) > 1e-9 {
+				t.Errorf("100 USD = %v DOT, want 25", got)
+			}
+		case "USDC":
+			// without a date the rate is taken to be of the month's last day
+			if !r.Date.Equal(at("2024-09-30 00:00")) {
+				t.Errorf("USDC date = %s", r.Date)
+			}
+		default:
+			t.Errorf("unexpected currency %q", r.Currency)
+		}
+	}
+
+	for _, bad := range []string{
+		`{ "DOT": { "2024-13": { "UsdPerUnit": 4 } } }`,
+		`{ "DOT": { "2024-09": { "UsdPerUnit": 0 } } }`,
+		`{ "DOT": { "2024-09": { "UsdPerUnit": 4, "Date": "30/09/2024" } } }`,
+	} {
+		if _, err := parseRateFile([]byte(bad)); err == nil {
+			t.Errorf("parseRateFile(%s) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestPickRate(t *testing.T) {
+	rates := []ExchangeRate{
+		{Currency: "DOT", Month: at("2024-07-01 00:00"), UsdPerUnit: 5},
+		{Currency: "DOT", Month: at("2024-09-01 00:00"), UsdPerUnit: 4},
+	}
+
+	tests := []struct {
+		name   string
+		month  string
+		want   float64
+		wantOK bool
+	}{
+		{"exact month", "2024-09-15 12:00", 4, true},
+		{"falls back to latest earlier month", "2024-08-01 00:00", 5, true},
+		{"later months use the newest rate", "2025-01-01 00:00", 4, true},
+		{"no rate before the first month", "2024-06-30 23:59", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := pickRate(rates, at(tt.month))
+			if ok != tt.wantOK || got.UsdPerUnit != tt.want {
+				t.Errorf("pickRate = %v, %v; want %v, %v", got.UsdPerUnit, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package billing
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+// ErrMemberNotBilled is returned for draft PDFs of members without charges in
+// the month in progress.
+var ErrMemberNotBilled = errors.New("member not billed this month")
+
+// WriteDraftMemberPDF renders the member PDF of the month in progress to w.
+// Nothing is written to the artifact store and no invoice is issued.
+func WriteDraftMemberPDF(memberName string, w io.Writer) error {
+	snap := draftSnapshot()
+	if _, ok := snap.Summary.Members[memberName]; !ok {
+		return ErrMemberNotBilled
+	}
+	return renderMemberPDF(memberName, snap, nil, reportLogo(), true).Output(w)
+}
+
+// WriteDraftOverviewPDF renders the monthly overview of the month in
+// progress to w.
+func WriteDraftOverviewPDF(w io.Writer) error {
+	return renderOverviewPDF(draftSnapshot(), reportLogo(), true).Output(w)
+}
+
+// DraftFileName is the download name of a draft PDF; member is empty for the
+// overview.
+func DraftFileName(month time.Time, member string) string {
+	name := overviewPDFName(month)
+	if member != "" {
+		name = memberPDFName(member, month)
+	}
+	return strings.TrimSuffix(name, ".pdf") + "-DRAFT.pdf"
+}
+
+// draftSnapshot computes the month to date: members are charged and measured
+// only up to now. A failed SLA calculation leaves the draft without SLA
+// results rather than failing it.
+func draftSnapshot() *Snapshot {
+	now := time.Now().UTC()
+	month := monthStart(now)
+	snap, err := liveSnapshot(month, now)
+	if err != nil {
+		log.Log(log.Warn, "[billing] draft for %s rendered without SLA results: %v", month.Format("January 2006"), err)
+	}
+	return snap
+}
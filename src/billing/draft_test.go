@@ -0,0 +1,88 @@
+package billing
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	cfg "github.com/ibp-network/ibp-geodns-libs/config"
+)
+
+func TestDraftFileName(t *testing.T) {
+	month := at("2025-05-10 12:00")
+
+	tests := []struct {
+		member string
+		want   string
+	}{
+		{"", "2025_05-Monthly_Overview-DRAFT.pdf"},
+		{"Stake Plus", "2025_05-IBP-Service_Stake_Plus-DRAFT.pdf"},
+	}
+	for _, tt := range tests {
+		if got := DraftFileName(month, tt.member); got != tt.want {
+			t.Errorf("DraftFileName(%q) = %q, want %q", tt.member, got, tt.want)
+		}
+	}
+}
+
+// A draft on April 11th covers 10 of April's 30 days: members pay a third of
+// the month and their SLA window ends on the 11th.
+func TestMonthToDate(t *testing.T) {
+	full := monthWindow(at("2025-04-01 00:00"))
+	toDate := slaWindow{start: full.start, end: at("2025-04-11 00:00")}
+	joined6 := at("2025-04-06 00:00")
+
+	price := cfg.IaasPricing{Cores: 1}
+	node := serviceState{Resources: cfg.Resources{Nodes: 1, Cores: 30}, Active: true}
+	h := newBillingHistory()
+	h.pricing["eu"] = []version[cfg.IaasPricing]{{From: historyEpoch, Value: &price}}
+	h.services["rpc"] = []version[serviceState]{{From: historyEpoch, Value: &node}}
+	h.members["alice"] = []version[memberState]{{From: historyEpoch, Value: &memberState{Region: "eu", Services: []string{"RPC"}}}}
+	h.members["bob"] = []version[memberState]{{From: historyEpoch, Value: &memberState{Region: "eu", Services: []string{"RPC"}, Joined: int(joined6.Unix())}}}
+	historySum := h.monthSummary(toDate)
+	historyPeriods := h.periods(toDate)
+
+	live := Summary{Members: map[string]MemberCost{
+		"alice": {MemberName: "alice", ServiceCosts: map[string]float64{"RPC": 30}, Total: 30},
+	}}
+	// configSummary without a loaded config: every member gets the whole window
+	configPeriods := map[string]ActivePeriod{"alice": toDate.period()}
+	configSum := prorateSummary(live, configPeriods, full)
+
+	tests := []struct {
+		name      string
+		sum       Summary
+		periods   map[string]ActivePeriod
+		member    string
+		wantCost  float64
+		wantStart time.Time
+	}{
+		{"history, whole month", historySum, historyPeriods, "alice", 10, full.start},
+		{"history, joined on the 6th", historySum, historyPeriods, "bob", 5, joined6},
+		{"live config", configSum, configPeriods, "alice", 10, full.start},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sum.Members[tt.member].ServiceCosts["RPC"]; math.Abs(got-tt.wantCost) > 1e-9 {
+				t.Errorf("cost = %v, want %v", got, tt.wantCost)
+			}
+			p := tt.periods[tt.member]
+			if !p.Start.Equal(tt.wantStart) || !p.End.Equal(toDate.end) {
+				t.Errorf("period = %s – %s, want %s – %s", p.Start, p.End, tt.wantStart, toDate.end)
+			}
+		})
+	}
+
+	// the SLA engine measures the period, so an outage on the 10th weighs
+	// against 240 hours rather than the whole month
+	p := historyPeriods["alice"]
+	w := slaWindow{start: p.Start, end: p.End}
+	down, _ := serviceDowntime([]OutageEvent{
+		{CheckType: "site", Start: at("2025-04-10 00:00"), End: ptr(at("2025-04-10 12:00"))},
+	}, nil, nil, w, toDate.end)
+	bd := newSLABreakdown(w.hours(), down, 0, 99)
+	if bd.HoursTotal != 240 || math.Abs(bd.Uptime-95) > 1e-9 || bd.MeetsSLA {
+		t.Errorf("SLA to date = %v hours, %v%% uptime, meets %v; want 240 hours, 95%%, false", bd.HoursTotal, bd.Uptime, bd.MeetsSLA)
+	}
+}
@@ -0,0 +1,244 @@
+package billing
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+
+	common "github.com/ibp-network/ibp-geodns-collator/src/common"
+)
+
+// BreakdownRow is one member/service line of a billing export.
+type BreakdownRow struct {
+	Member        string
+	Service       string
+	BaseCost      float64
+	Uptime        float64
+	DowntimeHours float64
+	SLATarget     float64
+	Billed        float64
+	Credits       float64
+	MeetsSLA      bool
+}
+
+// DowntimeRow is one outage counted against a member/service in an export.
+type DowntimeRow struct {
+	Member        string
+	Service       string
+	CheckType     string
+	Domain        string
+	Start         time.Time
+	End           time.Time // zero while the outage is still open
+	HoursDown     float64   // counted against the SLA
+	HoursExcluded float64   // inside maintenance windows
+}
+
+// Export is the spreadsheet view of a month's billing: the same figures as
+// the member PDFs, one row per member/service.
+type Export struct {
+	Month    time.Time
+	Rows     []BreakdownRow
+	Downtime []DowntimeRow
+	snap     *Snapshot
+	members  []string
+}
+
+// NewExport builds the export of snap, restricted to member (config ID) when
+// set. Downtime events are only loaded for XLSX output, see LoadDowntime.
+func NewExport(snap *Snapshot, member string) *Export {
+	e := &Export{Month: snap.Month, snap: snap}
+
+	for memberID := range snap.Summary.Members {
+		if member == "" || member == memberID {
+			e.members = append(e.members, memberID)
+		}
+	}
+	sort.Strings(e.members)
+
+	for _, memberID := range e.members {
+		credits := snap.MemberCredits(memberID)
+		services := sortedKeys(snap.Summary.Members[memberID].ServiceCosts)
+		for _, svc := range services {
+			bd := snap.SLA.Breakdown(memberID, svc)
+			sc := credits.Services[svc]
+			e.Rows = append(e.Rows, BreakdownRow{
+				Member:        memberID,
+				Service:       svc,
+				BaseCost:      sc.BaseCost,
+				Uptime:        bd.Uptime,
+				DowntimeHours: bd.HoursDown,
+				SLATarget:     bd.SLAThreshold,
+				Billed:        sc.Billed,
+				Credits:       sc.Credit,
+				MeetsSLA:      bd.MeetsSLA,
+			})
+		}
+	}
+	return e
+}
+
+// LoadDowntime fills e.Downtime with the outages the SLA engine counted for
+// every exported member/service, see Snapshot.ServiceOutages.
+func (e *Export) LoadDowntime() error {
+	for _, memberID := range e.members {
+		for _, svc := range sortedKeys(e.snap.Summary.Members[memberID].ServiceCosts) {
+			outages, err := e.snap.ServiceOutages(memberID, svc)
+			if err != nil {
+				return err
+			}
+			for _, o := range outages {
+				row := DowntimeRow{
+					Member:        memberID,
+					Service:       svc,
+					CheckType:     common.NormalizeCheckType(o.CheckType),
+					Domain:        o.Domain,
+					Start:         o.From,
+					HoursDown:     o.HoursDown,
+					HoursExcluded: o.HoursExcluded,
+				}
+				if o.End != nil {
+					row.End = o.To
+				}
+				e.Downtime = append(e.Downtime, row)
+			}
+		}
+	}
+
+	sort.SliceStable(e.Downtime, func(i, j int) bool {
+		a, b := e.Downtime[i], e.Downtime[j]
+		if a.Member != b.Member {
+			return a.Member < b.Member
+		}
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		return a.Start.Before(b.Start)
+	})
+	return nil
+}
+
+var breakdownHeader = []string{
+	"month", "member", "service", "base_cost", "uptime_percentage", "downtime_hours",
+	"sla_target", "billed_cost", "credits", "meets_sla",
+}
+
+// WriteCSV writes one row per member/service.
+func (e *Export) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(breakdownHeader); err != nil {
+		return err
+	}
+
+	money := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	month := e.Month.Format("2006-01")
+	for _, r := range e.Rows {
+		if err := cw.Write([]string{
+			month, r.Member, r.Service, money(r.BaseCost),
+			strconv.FormatFloat(r.Uptime, 'f', 4, 64),
+			strconv.FormatFloat(r.DowntimeHours, 'f', 4, 64),
+			strconv.FormatFloat(r.SLATarget, 'f', -1, 64),
+			money(r.Billed), money(r.Credits), strconv.FormatBool(r.MeetsSLA),
+		}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteXLSX writes a workbook with a Members sheet (totals per member), a
+// Services sheet (one row per member/service) and a Downtime sheet.
+func (e *Export) WriteXLSX(w io.Writer) error {
+	month := e.Month.Format("2006-01")
+
+	members := xlsxSheet{Name: "Members", Rows: [][]interface{}{{
+		"month", "member", "level", "base_cost", "credits", "adjustments", "billed", "meets_sla",
+		"period_start", "period_end",
+	}}}
+	for _, memberID := range e.members {
+		credits := e.snap.MemberCredits(memberID)
+		_, adjustments := e.snap.MemberAdjustments(memberID)
+		meets := true
+		for _, r := range e.Rows {
+			if r.Member == memberID && !r.MeetsSLA {
+				meets = false
+			}
+		}
+		period, _ := e.snap.MemberPeriod(memberID)
+		members.Rows = append(members.Rows, []interface{}{
+			month, memberID, e.snap.MemberLevel(memberID), roundCents(credits.TotalBase),
+			roundCents(credits.TotalCredit), roundCents(adjustments),
+			roundCents(credits.TotalBilled + adjustments), meets, period.Start, period.End,
+		})
+	}
+
+	header := make([]interface{}, len(breakdownHeader))
+	for i, h := range breakdownHeader {
+		header[i] = h
+	}
+	services := xlsxSheet{Name: "Services", Rows: [][]interface{}{header}}
+	for _, r := range e.Rows {
+		services.Rows = append(services.Rows, []interface{}{
+			month, r.Member, r.Service, roundCents(r.BaseCost), r.Uptime, r.DowntimeHours,
+			r.SLATarget, roundCents(r.Billed), roundCents(r.Credits), r.MeetsSLA,
+		})
+	}
+
+	downtime := xlsxSheet{Name: "Downtime", Rows: [][]interface{}{{
+		"member", "service", "check_type", "domain", "start", "end", "downtime_hours", "maintenance_hours",
+	}}}
+	for _, d := range e.Downtime {
+		downtime.Rows = append(downtime.Rows, []interface{}{
+			d.Member, d.Service, d.CheckType, d.Domain, d.Start, d.End, d.HoursDown, d.HoursExcluded,
+		})
+	}
+
+	return writeXLSX(w, []xlsxSheet{members, services, downtime})
+}
+
+// ExportFileName is the file name of a month's breakdown export with ext
+// ("csv" or "xlsx").
+func ExportFileName(month time.Time, ext string) string {
+	return fmt.Sprintf("%s-Billing_Breakdown.%s", month.Format("2006_01"), ext)
+}
+
+// writeExports stores the CSV and XLSX breakdown of snap with the month's
+// reports and returns the keys written.
+func writeExports(snap *Snapshot) ([]string, error) {
+	e := NewExport(snap, "")
+	if err := e.LoadDowntime(); err != nil {
+		log.Log(log.Warn, "[billing] exporting %s without downtime events: %v", snap.Month.Format("January 2006"), err)
+	}
+
+	var written []string
+	for _, f := range []struct {
+		ext   string
+		write func(io.Writer) error
+	}{
+		{"csv", e.WriteCSV},
+		{"xlsx", e.WriteXLSX},
+	} {
+		key := monthKey(snap.Month, ExportFileName(snap.Month, f.ext))
+		if err := putArtifact(key, f.write); err != nil {
+			return written, err
+		}
+		written = append(written, key)
+	}
+	return written, nil
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
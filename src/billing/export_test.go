@@ -0,0 +1,132 @@
+package billing
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestXLSXColumn(t *testing.T) {
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{27, "AB"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+
+	for _, tt := range tests {
+		if got := xlsxColumn(tt.index); got != tt.want {
+			t.Errorf("xlsxColumn(%d) = %q, want %q", tt.index, got, tt.want)
+		}
+	}
+}
+
+func testExport() *Export {
+	snap := &Snapshot{
+		Month: at("2025-04-01 00:00"),
+		Summary: Summary{Members: map[string]MemberCost{
+			"beta":  {ServiceCosts: map[string]float64{"RPC": 100}, Total: 100},
+			"alpha": {ServiceCosts: map[string]float64{"RPC": 100, "ETH": 50}, Total: 150},
+		}},
+		SLA: SLASummary{
+			"alpha": {
+				"RPC": newSLABreakdown(720, 7.2, 0, 99.9),
+				"ETH": newSLABreakdown(720, 0, 0, 99.9),
+			},
+			"beta": {"RPC": newSLABreakdown(720, 0, 0, 99.9)},
+		},
+		Credits: map[string]MemberCredit{
+			"alpha": {Services: map[string]ServiceCredit{
+				"RPC": {BaseCost: 100, Credit: 1, Billed: 99},
+				"ETH": {BaseCost: 50, Billed: 50},
+			}, TotalBase: 150, TotalCredit: 1, TotalBilled: 149},
+			"beta": {Services: map[string]ServiceCredit{
+				"RPC": {BaseCost: 100, Billed: 100},
+			}, TotalBase: 100, TotalBilled: 100},
+		},
+		Levels: map[string]int{"alpha": 5, "beta": 6},
+	}
+	return NewExport(snap, "")
+}
+
+func TestExportCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testExport().WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"month,member,service,base_cost,uptime_percentage,downtime_hours,sla_target,billed_cost,credits,meets_sla",
+		"2025-04,alpha,ETH,50.00,100.0000,0.0000,99.9,50.00,0.00,true",
+		"2025-04,alpha,RPC,100.00,99.0000,7.2000,99.9,99.00,1.00,false",
+		"2025-04,beta,RPC,100.00,100.0000,0.0000,99.9,100.00,0.00,true",
+	}, "\n") + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteCSV =\n%s\nwant\n%s", got, want)
+	}
+
+	if rows := NewExport(testExport().snap, "beta").Rows; len(rows) != 1 || rows[0].Member != "beta" {
+		t.Errorf("member filter rows = %+v", rows)
+	}
+}
+
+func TestExportXLSX(t *testing.T) {
+	e := testExport()
+	e.Downtime = []DowntimeRow{{
+		Member: "alpha", Service: "RPC", CheckType: "domain", Domain: "rpc.alpha.<io>&co",
+		Start: at("2025-04-10 00:00"), End: at("2025-04-10 07:12"), HoursDown: 5.2, HoursExcluded: 2,
+	}}
+
+	var buf bytes.Buffer
+	if err := e.WriteXLSX(&buf); err != nil {
+		t.Fatalf("WriteXLSX: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("workbook is not a zip archive: %v", err)
+	}
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(body)
+	}
+
+	for _, name := range []string{
+		"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels",
+		"xl/styles.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml", "xl/worksheets/sheet3.xml",
+	} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+
+	for _, sheet := range []string{`name="Members"`, `name="Services"`, `name="Downtime"`} {
+		if !strings.Contains(parts["xl/workbook.xml"], sheet) {
+			t.Errorf("workbook has no sheet %s", sheet)
+		}
+	}
+	if !strings.Contains(parts["xl/worksheets/sheet1.xml"], `<c r="C2"><v>5</v></c>`) {
+		t.Errorf("Members sheet lacks alpha's level: %s", parts["xl/worksheets/sheet1.xml"])
+	}
+	if !strings.Contains(parts["xl/worksheets/sheet3.xml"], "rpc.alpha.&lt;io&gt;&amp;co") {
+		t.Errorf("Downtime sheet does not escape text: %s", parts["xl/worksheets/sheet3.xml"])
+	}
+	for _, cell := range []string{`<c r="G2"><v>5.2</v></c>`, `<c r="H2"><v>2</v></c>`} {
+		if !strings.Contains(parts["xl/worksheets/sheet3.xml"], cell) {
+			t.Errorf("Downtime sheet lacks %s: %s", cell, parts["xl/worksheets/sheet3.xml"])
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package billing
+
+import (
+	"time"
+)
+
+// SLA risk of a member/service pair at month end.
+const (
+	RiskOK       = "ok"       // projected to meet the SLA
+	RiskAtRisk   = "at_risk"  // projected to exceed the allowed downtime
+	RiskBreached = "breached" // allowed downtime already exceeded
+)
+
+// ServiceForecast projects one member/service pair to the end of the month.
+// Downtime to date is extrapolated at the rate observed so far over the
+// hours that remain in the member's active period.
+type ServiceForecast struct {
+	SLA             SLABreakdown // to date, remaining hours counted as up
+	HoursElapsed    float64
+	HoursRemaining  float64
+	AllowedDown     float64 // HoursTotal - SLAHours
+	BudgetRemaining float64 // AllowedDown - HoursDown, negative once breached
+	ProjectedDown   float64
+	ProjectedUptime float64
+	Risk            string
+
+	BaseCost        float64
+	CreditsToDate   float64
+	ProjectedCredit float64
+	ProjectedBilled float64
+}
+
+// MemberForecast groups a member's service forecasts; Risk is the worst of
+// its services.
+type MemberForecast struct {
+	Services        map[string]ServiceForecast
+	BaseCost        float64
+	CreditsToDate   float64
+	ProjectedCredit float64
+	Adjustments     float64
+	ProjectedBilled float64 // after projected credits and adjustments so far
+	Risk            string
+}
+
+// Forecast is the month-end projection of the month in progress.
+type Forecast struct {
+	Month     time.Time
+	Generated time.Time
+	Members   map[string]MemberForecast
+}
+
+// ForecastMonth projects the current month's billed cost, credits and SLA
+// risk from the downtime recorded up to now.
+func ForecastMonth(now time.Time) (*Forecast, error) {
+	now = now.UTC()
+	month := monthStart(now)
+
+	snap, err := LiveSnapshot(month)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Forecast{
+		Month:     month,
+		Generated: now,
+		Members:   make(map[string]MemberForecast, len(snap.Summary.Members)),
+	}
+
+	for memberID, mc := range snap.Summary.Members {
+		period, _ := snap.MemberPeriod(memberID)
+		elapsed, remaining := splitPeriod(period, now)
+
+		// credits follow from the projected uptime through the member's policy
+		projected := SLASummary{memberID: make(map[string]SLABreakdown, len(mc.ServiceCosts))}
+		services := make(map[string]ServiceForecast, len(mc.ServiceCosts))
+		for svc := range mc.ServiceCosts {
+			bd, ok := snap.SLA[memberID][svc]
+			if !ok {
+				continue
+			}
+			sf := forecastService(bd, elapsed, remaining)
+			services[svc] = sf
+			projected[memberID][svc] = newSLABreakdown(bd.HoursTotal, sf.ProjectedDown, bd.HoursExcluded, bd.SLAThreshold)
+		}
+
+		toDate := snap.MemberCredits(memberID)
+		credits := CalculateMemberCredits(memberID, mc.ServiceCosts, projected)
+		_, adjustments := snap.MemberAdjustments(memberID)
+
+		mf := MemberForecast{
+			Services:        make(map[string]ServiceForecast, len(mc.ServiceCosts)),
+			BaseCost:        credits.TotalBase,
+			CreditsToDate:   toDate.TotalCredit,
+			ProjectedCredit: credits.TotalCredit,
+			Adjustments:     adjustments,
+			ProjectedBilled: credits.TotalBilled + adjustments,
+			Risk:            RiskOK,
+		}
+		for svc, sc := range credits.Services {
+			sf, ok := services[svc]
+			if !ok {
+				sf = ServiceForecast{HoursElapsed: elapsed, HoursRemaining: remaining, Risk: RiskOK}
+			}
+			sf.BaseCost = sc.BaseCost
+			sf.CreditsToDate = toDate.Services[svc].Credit
+			sf.ProjectedCredit = sc.Credit
+			sf.ProjectedBilled = sc.Billed
+			mf.Services[svc] = sf
+			mf.Risk = worseRisk(mf.Risk, sf.Risk)
+		}
+		f.Members[memberID] = mf
+	}
+
+	return f, nil
+}
+
+// splitPeriod returns the hours of p before and after now.
+func splitPeriod(p ActivePeriod, now time.Time) (elapsed, remaining float64) {
+	switch {
+	case !now.After(p.Start):
+		return 0, p.Hours()
+	case !now.Before(p.End):
+		return p.Hours(), 0
+	}
+	return now.Sub(p.Start).Hours(), p.End.Sub(now).Hours()
+}
+
+// forecastService extrapolates bd's downtime to date over the remaining hours
+// and classifies the pair's SLA risk.
+func forecastService(bd SLABreakdown, elapsed, remaining float64) ServiceForecast {
+	sf := ServiceForecast{
+		SLA:            bd,
+		HoursElapsed:   elapsed,
+		HoursRemaining: remaining,
+		AllowedDown:    bd.HoursTotal - bd.SLAHours,
+		ProjectedDown:  bd.HoursDown,
+	}
+	sf.BudgetRemaining = sf.AllowedDown - bd.HoursDown
+
+	if elapsed > 0 {
+		sf.ProjectedDown += bd.HoursDown / elapsed * remaining
+	}
+	if sf.ProjectedDown > bd.HoursTotal {
+		sf.ProjectedDown = bd.HoursTotal
+	}
+
+	sf.ProjectedUptime = 100.0
+	if bd.HoursTotal > 0 {
+		sf.ProjectedUptime = (bd.HoursTotal - sf.ProjectedDown) / bd.HoursTotal * 100.0
+	}
+
+	switch {
+	case bd.HoursDown > sf.AllowedDown:
+		sf.Risk = RiskBreached
+	case sf.ProjectedDown > sf.AllowedDown:
+		sf.Risk = RiskAtRisk
+	default:
+		sf.Risk = RiskOK
+	}
+	return sf
+}
+
+// worseRisk returns the more severe of a and b.
+func worseRisk(a, b string) string {
+	rank := map[string]int{RiskOK: 0, RiskAtRisk: 1, RiskBreached: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,68 @@
+package billing
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSplitPeriod(t *testing.T) {
+	p := monthWindow(at("2025-04-01 00:00")).period() // 720 hours
+
+	tests := []struct {
+		name          string
+		now           string
+		elapsed, left float64
+	}{
+		{"before the period", "2025-03-31 12:00", 0, 720},
+		{"mid-month", "2025-04-11 00:00", 240, 480},
+		{"after the period", "2025-05-02 00:00", 720, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elapsed, left := splitPeriod(p, at(tt.now))
+			if elapsed != tt.elapsed || left != tt.left {
+				t.Errorf("splitPeriod = %v, %v; want %v, %v", elapsed, left, tt.elapsed, tt.left)
+			}
+		})
+	}
+}
+
+func TestForecastService(t *testing.T) {
+	// 720-hour month at 99% leaves 7.2 hours of allowed downtime
+	tests := []struct {
+		name          string
+		down          float64
+		elapsed       float64
+		wantProjected float64
+		wantRisk      string
+	}{
+		{"no downtime", 0, 240, 0, RiskOK},
+		{"on track", 1, 240, 3, RiskOK},
+		{"projected past the budget", 3, 240, 9, RiskAtRisk},
+		{"budget already spent", 8, 240, 24, RiskBreached},
+		{"month over", 5, 720, 5, RiskOK},
+		{"projection capped at the month", 400, 300, 720, RiskBreached},
+		{"nothing elapsed", 0, 0, 0, RiskOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bd := newSLABreakdown(720, tt.down, 0, 99)
+			sf := forecastService(bd, tt.elapsed, 720-tt.elapsed)
+
+			if math.Abs(sf.ProjectedDown-tt.wantProjected) > 1e-9 {
+				t.Errorf("ProjectedDown = %v, want %v", sf.ProjectedDown, tt.wantProjected)
+			}
+			if sf.Risk != tt.wantRisk {
+				t.Errorf("Risk = %q, want %q", sf.Risk, tt.wantRisk)
+			}
+			if math.Abs(sf.AllowedDown-7.2) > 1e-9 {
+				t.Errorf("AllowedDown = %v, want 7.2", sf.AllowedDown)
+			}
+			if math.Abs(sf.BudgetRemaining-(7.2-tt.down)) > 1e-9 {
+				t.Errorf("BudgetRemaining = %v, want %v", sf.BudgetRemaining, 7.2-tt.down)
+			}
+		})
+	}
+}
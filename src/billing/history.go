@@ -0,0 +1,452 @@
+package billing
+
+// ─────────────────────────────────────────────────────────────────────────────
+//  Effective-dated billing config
+//
+//  Pricing per region, resources per service and the region and service
+//  assignments per member are recorded in billing_config_history whenever the
+//  leader sees them change after a config reload. Each version is in force from
+//  its effective_from until the next version of the same entry; removal is
+//  recorded as a "null" version. The config present when tracking starts is
+//  treated as having always been in force.
+//
+//  A month is billed by splitting it at every version change and join date and
+//  prorating each member/service by the share of the month each segment
+//  covers. Members are not billed before their Membership.Joined.
+// ─────────────────────────────────────────────────────────────────────────────
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	cluster "github.com/ibp-network/ibp-geodns-collator/src/cluster"
+
+	cfg "github.com/ibp-network/ibp-geodns-libs/config"
+	data2 "github.com/ibp-network/ibp-geodns-libs/data2"
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+// Kinds of entries recorded in billing_config_history.
+const (
+	historyPricing = "pricing" // name = lower-case region
+	historyService = "service" // name = lower-case service name
+	historyMember  = "member"  // name = config member ID
+)
+
+// historyEpoch is the effective date of the versions recorded when tracking
+// starts.
+var historyEpoch = time.Unix(0, 0).UTC()
+
+// defaultHistoryInterval is used when the config reload interval is unset.
+const defaultHistoryInterval = time.Minute
+
+// serviceState is the billing-relevant part of a service's config.
+type serviceState struct {
+	Resources cfg.Resources
+	Active    bool
+}
+
+// memberState is the billing-relevant part of a member's config.
+type memberState struct {
+	Region   string   // lower-case pricing region
+	Services []string // assigned services as listed in ServiceAssignments, sorted
+	Joined   int      // Membership.Joined, unix seconds
+}
+
+// version is one state of an entry; Value is nil once the entry was removed.
+type version[T any] struct {
+	From  time.Time
+	Value *T
+}
+
+// valueAt returns the value in force at t from versions sorted oldest first.
+func valueAt[T any](versions []version[T], t time.Time) (T, bool) {
+	var (
+		value T
+		ok    bool
+	)
+	for _, v := range versions {
+		if v.From.After(t) {
+			break
+		}
+		if v.Value == nil {
+			var zero T
+			value, ok = zero, false
+		} else {
+			value, ok = *v.Value, true
+		}
+	}
+	return value, ok
+}
+
+// billingHistory holds every recorded version, oldest first.
+type billingHistory struct {
+	pricing  map[string][]version[cfg.IaasPricing]
+	services map[string][]version[serviceState]
+	members  map[string][]version[memberState]
+}
+
+func newBillingHistory() *billingHistory {
+	return &billingHistory{
+		pricing:  make(map[string][]version[cfg.IaasPricing]),
+		services: make(map[string][]version[serviceState]),
+		members:  make(map[string][]version[memberState]),
+	}
+}
+
+func (h *billingHistory) empty() bool {
+	return len(h.pricing) == 0 && len(h.services) == 0 && len(h.members) == 0
+}
+
+// boundaries returns w's start and end and every version change inside w.
+func (h *billingHistory) boundaries(w slaWindow) []time.Time {
+	seen := map[time.Time]bool{}
+	bounds := []time.Time{w.start, w.end}
+	add := func(t time.Time) {
+		if t.After(w.start) && t.Before(w.end) && !seen[t] {
+			seen[t] = true
+			bounds = append(bounds, t)
+		}
+	}
+
+	for _, vs := range h.pricing {
+		for _, v := range vs {
+			add(v.From)
+		}
+	}
+	for _, vs := range h.services {
+		for _, v := range vs {
+			add(v.From)
+		}
+	}
+	for _, vs := range h.members {
+		for _, v := range vs {
+			add(v.From)
+			if v.Value != nil {
+				add(joinedTime(v.Value.Joined))
+			}
+		}
+	}
+
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i].Before(bounds[j]) })
+	return bounds
+}
+
+// monthSummary prices every member/service for w, prorated by the share of
+// the month each version was in force. A window that ends before the month
+// does (the month to date) is only charged for the hours it covers.
+func (h *billingHistory) monthSummary(w slaWindow) Summary {
+	members := make(map[string]MemberCost)
+	services := make(map[string]ServiceCost)
+	total := monthWindow(w.start).hours() * 3600
+
+	bounds := h.boundaries(w)
+	for i := 0; i+1 < len(bounds); i++ {
+		at := bounds[i]
+		share := bounds[i+1].Sub(at).Seconds() / total
+
+		for memName, mvs := range h.members {
+			if !memberActiveAt(mvs, at) {
+				continue
+			}
+			mem, _ := valueAt(mvs, at)
+			price, ok := valueAt(h.pricing[mem.Region], at)
+			if !ok {
+				continue
+			}
+
+			for _, svcName := range mem.Services {
+				svc, ok := valueAt(h.services[strings.ToLower(strings.TrimSpace(svcName))], at)
+				if !ok || !svc.Active {
+					continue
+				}
+				cost := costForServiceInstance(svc.Resources, price) * share
+
+				mc := members[memName]
+				if mc.MemberName == "" {
+					mc.MemberName = memName
+					mc.ServiceCosts = map[string]float64{}
+				}
+				mc.ServiceCosts[svcName] += cost
+				mc.Total += cost
+				members[memName] = mc
+
+				sc := services[svcName]
+				if sc.ServiceName == "" {
+					sc.ServiceName = svcName
+					sc.MemberCosts = map[string]float64{}
+				}
+				sc.MemberCosts[memName] += cost
+				sc.Total += cost
+				services[svcName] = sc
+			}
+		}
+	}
+
+	for name, mc := range members {
+		if mc.Total <= 0 {
+			delete(members, name)
+		}
+	}
+
+	return Summary{Members: members, Services: services, Refresh: time.Now().UTC()}
+}
+
+// stateAt returns the pricing of each member's region and the resources of
+// each service in force at t.
+func (h *billingHistory) stateAt(t time.Time) (map[string]cfg.IaasPricing, map[string]string, map[string]cfg.Resources) {
+	pricing := make(map[string]cfg.IaasPricing)
+	regions := make(map[string]string)
+	resources := make(map[string]cfg.Resources)
+
+	for memName, mvs := range h.members {
+		mem, ok := valueAt(mvs, t)
+		if !ok {
+			continue
+		}
+		regions[memName] = mem.Region
+		if p, ok := valueAt(h.pricing[mem.Region], t); ok {
+			pricing[mem.Region] = p
+		}
+		for _, svcName := range mem.Services {
+			if svc, ok := valueAt(h.services[strings.ToLower(strings.TrimSpace(svcName))], t); ok {
+				resources[svcName] = svc.Resources
+			}
+		}
+	}
+	return pricing, regions, resources
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+//  Persistence
+// ─────────────────────────────────────────────────────────────────────────────
+
+const createHistoryTable = `
+	CREATE TABLE IF NOT EXISTS billing_config_history (
+		id             INT UNSIGNED NOT NULL AUTO_INCREMENT,
+		kind           VARCHAR(16)  NOT NULL,
+		name           VARCHAR(255) NOT NULL,
+		effective_from DATETIME     NOT NULL,
+		data           TEXT         NOT NULL,
+		PRIMARY KEY (id),
+		KEY idx_billing_config_history (kind, name, effective_from)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci`
+
+// historyEnabled is false when billing_config_history could not be prepared;
+// months are then priced from the current config only.
+var historyEnabled bool
+
+// initHistoryState prepares the billing_config_history table.
+func initHistoryState() {
+	if data2.DB == nil {
+		log.Log(log.Warn, "[billing] database unavailable — effective-dated pricing disabled")
+		return
+	}
+
+	if _, err := data2.DB.Exec(createHistoryTable); err != nil {
+		log.Log(log.Error, "[billing] failed to prepare billing_config_history table: %v", err)
+		return
+	}
+	historyEnabled = true
+}
+
+// configStates returns the JSON of every tracked entry of c by kind and name.
+func configStates(c cfg.Config) map[string]map[string]string {
+	states := map[string]map[string]string{
+		historyPricing: {},
+		historyService: {},
+		historyMember:  {},
+	}
+
+	for region, p := range c.Pricing {
+		data, _ := json.Marshal(p)
+		states[historyPricing][strings.ToLower(strings.TrimSpace(region))] = string(data)
+	}
+
+	for name, svc := range c.Services {
+		data, _ := json.Marshal(serviceState{Resources: svc.Resources, Active: svc.Configuration.Active == 1})
+		states[historyService][strings.ToLower(strings.TrimSpace(name))] = string(data)
+	}
+
+	for name, mem := range c.Members {
+		st := memberState{
+			Region:   strings.ToLower(strings.TrimSpace(mem.Location.Region)),
+			Services: []string{},
+			Joined:   mem.Membership.Joined,
+		}
+		for _, svcList := range mem.ServiceAssignments {
+			st.Services = append(st.Services, svcList...)
+		}
+		sort.Strings(st.Services)
+		data, _ := json.Marshal(st)
+		states[historyMember][name] = string(data)
+	}
+
+	return states
+}
+
+// recordConfigVersions stores every entry of c that differs from its latest
+// recorded version as in force from now.
+func recordConfigVersions(c cfg.Config, now time.Time) error {
+	if !historyEnabled {
+		return nil
+	}
+
+	latest, err := latestVersions()
+	if err != nil {
+		return err
+	}
+
+	from := now.UTC().Truncate(time.Second)
+	if len(latest) == 0 {
+		from = historyEpoch
+	}
+
+	current := configStates(c)
+	changed := 0
+	insert := func(kind, name, data string) error {
+		if _, err := data2.DB.Exec(`
+			INSERT INTO billing_config_history (kind, name, effective_from, data)
+			VALUES (?, ?, ?, ?)`, kind, name, from, data); err != nil {
+			return fmt.Errorf("record %s %s: %w", kind, name, err)
+		}
+		changed++
+		return nil
+	}
+
+	for kind, entries := range current {
+		for name, data := range entries {
+			if prev, ok := latest[kind+"\x00"+name]; !ok || prev != data {
+				if err := insert(kind, name, data); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// entries removed from config
+	for key, data := range latest {
+		parts := strings.SplitN(key, "\x00", 2)
+		if _, ok := current[parts[0]][parts[1]]; !ok && data != "null" {
+			if err := insert(parts[0], parts[1], "null"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if changed > 0 {
+		log.Log(log.Info, "[billing] recorded %d billing config change(s) effective %s", changed, from.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// latestVersions returns the data of the newest version of every entry, keyed
+// by kind + "\x00" + name.
+func latestVersions() (map[string]string, error) {
+	rows, err := data2.DB.Query(`
+		SELECT kind, name, data
+		FROM billing_config_history
+		ORDER BY effective_from, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	latest := make(map[string]string)
+	for rows.Next() {
+		var kind, name, data string
+		if err := rows.Scan(&kind, &name, &data); err != nil {
+			return nil, err
+		}
+		latest[kind+"\x00"+name] = data
+	}
+	return latest, rows.Err()
+}
+
+// loadHistory reads every recorded version.
+func loadHistory() (*billingHistory, error) {
+	h := newBillingHistory()
+	if !historyEnabled {
+		return h, nil
+	}
+
+	rows, err := data2.DB.Query(`
+		SELECT kind, name, effective_from, data
+		FROM billing_config_history
+		ORDER BY effective_from, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			kind, name, data string
+			from             time.Time
+		)
+		if err := rows.Scan(&kind, &name, &from, &data); err != nil {
+			return nil, err
+		}
+		from = from.UTC()
+
+		switch kind {
+		case historyPricing:
+			var v *cfg.IaasPricing
+			err = json.Unmarshal([]byte(data), &v)
+			h.pricing[name] = append(h.pricing[name], version[cfg.IaasPricing]{From: from, Value: v})
+		case historyService:
+			var v *serviceState
+			err = json.Unmarshal([]byte(data), &v)
+			h.services[name] = append(h.services[name], version[serviceState]{From: from, Value: v})
+		case historyMember:
+			var v *memberState
+			err = json.Unmarshal([]byte(data), &v)
+			h.members[name] = append(h.members[name], version[memberState]{From: from, Value: v})
+		}
+		if err != nil {
+			log.Log(log.Warn, "[billing] unreadable %s version of %s: %v", kind, name, err)
+		}
+	}
+	return h, rows.Err()
+}
+
+// historicalSummary prices w from the recorded versions; ok is false when no
+// history is available and the live summary must be used instead.
+func historicalSummary(w slaWindow) (Summary, *billingHistory, bool) {
+	if !historyEnabled {
+		return Summary{}, nil, false
+	}
+
+	h, err := loadHistory()
+	if err != nil {
+		log.Log(log.Error, "[billing] failed to load billing config history: %v", err)
+		return Summary{}, nil, false
+	}
+	if h.empty() {
+		return Summary{}, nil, false
+	}
+	return h.monthSummary(w), h, true
+}
+
+// trackConfigVersions records config changes on the leader at the config
+// reload interval until Shutdown.
+func trackConfigVersions() {
+	interval := cfg.GetConfig().Local.System.ConfigReloadTime * time.Second
+	if interval <= 0 {
+		interval = defaultHistoryInterval
+	}
+
+	for {
+		if cluster.IsLeader() {
+			if err := recordConfigVersions(cfg.GetConfig(), time.Now()); err != nil {
+				log.Log(log.Error, "[billing] failed to record billing config changes: %v", err)
+			}
+		}
+		if !sleepUntil(time.Now().Add(interval)) {
+			return
+		}
+	}
+}
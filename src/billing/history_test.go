@@ -0,0 +1,228 @@
+package billing
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	cfg "github.com/ibp-network/ibp-geodns-libs/config"
+)
+
+func TestValueAt(t *testing.T) {
+	one, two := 1, 2
+	versions := []version[int]{
+		{From: at("2025-03-01 00:00"), Value: &one},
+		{From: at("2025-03-10 00:00"), Value: &two},
+		{From: at("2025-03-20 00:00")}, // removed
+	}
+
+	tests := []struct {
+		name   string
+		at     time.Time
+		want   int
+		wantOK bool
+	}{
+		{"before the first version", at("2025-02-28 23:59"), 0, false},
+		{"first version", at("2025-03-05 00:00"), 1, true},
+		{"change takes effect at its timestamp", at("2025-03-10 00:00"), 2, true},
+		{"removed", at("2025-03-25 00:00"), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := valueAt(versions, tt.at)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("valueAt = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMonthSummaryProration(t *testing.T) {
+	// April has 30 days, so each day is 1/30 of the month
+	w := monthWindow(at("2025-04-01 00:00"))
+	day := func(d int) time.Time { return w.start.AddDate(0, 0, d-1) }
+
+	cheap := cfg.IaasPricing{Cores: 1}
+	dear := cfg.IaasPricing{Cores: 2}
+	node := serviceState{Resources: cfg.Resources{Nodes: 1, Cores: 30}, Active: true}
+	bigger := serviceState{Resources: cfg.Resources{Nodes: 2, Cores: 30}, Active: true}
+	inactive := serviceState{Resources: node.Resources}
+	withRPC := memberState{Region: "eu", Services: []string{"RPC"}}
+	withNone := memberState{Region: "eu", Services: []string{}}
+	joined21 := memberState{Region: "eu", Services: []string{"RPC"}, Joined: int(day(21).Unix())}
+	joinedEarlier := memberState{Region: "eu", Services: []string{"RPC"}, Joined: int(day(1).AddDate(0, -2, 0).Unix())}
+
+	tests := []struct {
+		name     string
+		pricing  []version[cfg.IaasPricing]
+		service  []version[serviceState]
+		member   []version[memberState]
+		wantCost float64 // for member "alice", service "RPC"
+	}{
+		{
+			name:     "unchanged all month",
+			pricing:  []version[cfg.IaasPricing]{{From: historyEpoch, Value: &cheap}},
+			service:  []version[serviceState]{{From: historyEpoch, Value: &node}},
+			member:   []version[memberState]{{From: historyEpoch, Value: &withRPC}},
+			wantCost: 30,
+		},
+		{
+			name:     "price change on the 16th",
+			pricing:  []version[cfg.IaasPricing]{{From: historyEpoch, Value: &cheap}, {From: day(16), Value: &dear}},
+			service:  []version[serviceState]{{From: historyEpoch, Value: &node}},
+			member:   []version[memberState]{{From: historyEpoch, Value: &withRPC}},
+			wantCost: 15 + 30,
+		},
+		{
+			name:     "resources doubled on the 21st",
+			pricing:  []version[cfg.IaasPricing]{{From: historyEpoch, Value: &cheap}},
+			service:  []version[serviceState]{{From: historyEpoch, Value: &node}, {From: day(21), Value: &bigger}},
+			member:   []version[memberState]{{From: historyEpoch, Value: &withRPC}},
+			wantCost: 20 + 20,
+		},
+		{
+			name:     "service assigned on the 11th",
+			pricing:  []version[cfg.IaasPricing]{{From: historyEpoch, Value: &cheap}},
+			service:  []version[serviceState]{{From: historyEpoch, Value: &node}},
+			member:   []version[memberState]{{From: historyEpoch, Value: &withNone}, {From: day(11), Value: &withRPC}},
+			wantCost: 20,
+		},
+		{
+			name:     "member removed on the 7th",
+			pricing:  []version[cfg.IaasPricing]{{From: historyEpoch, Value: &cheap}},
+			service:  []version[serviceState]{{From: historyEpoch, Value: &node}},
+			member:   []version[memberState]{{From: historyEpoch, Value: &withRPC}, {From: day(7)}},
+			wantCost: 6,
+		},
+		{
+			name:     "service deactivated on the 25th",
+			pricing:  []version[cfg.IaasPricing]{{From: historyEpoch, Value: &cheap}},
+			service:  []version[serviceState]{{From: historyEpoch, Value: &node}, {From: day(25), Value: &inactive}},
+			member:   []version[memberState]{{From: historyEpoch, Value: &withRPC}},
+			wantCost: 24,
+		},
+		{
+			name:     "changes outside the month are ignored",
+			pricing:  []version[cfg.IaasPricing]{{From: historyEpoch, Value: &cheap}, {From: w.end, Value: &dear}},
+			service:  []version[serviceState]{{From: historyEpoch, Value: &node}},
+			member:   []version[memberState]{{From: historyEpoch, Value: &withRPC}},
+			wantCost: 30,
+		},
+		{
+			name:     "joined on the 21st",
+			pricing:  []version[cfg.IaasPricing]{{From: historyEpoch, Value: &cheap}},
+			service:  []version[serviceState]{{From: historyEpoch, Value: &node}},
+			member:   []version[memberState]{{From: historyEpoch, Value: &joined21}},
+			wantCost: 10,
+		},
+		{
+			name:     "joined before the month",
+			pricing:  []version[cfg.IaasPricing]{{From: historyEpoch, Value: &cheap}},
+			service:  []version[serviceState]{{From: historyEpoch, Value: &node}},
+			member:   []version[memberState]{{From: historyEpoch, Value: &joinedEarlier}},
+			wantCost: 30,
+		},
+		{
+			name:     "region without pricing is not billed",
+			service:  []version[serviceState]{{From: historyEpoch, Value: &node}},
+			member:   []version[memberState]{{From: historyEpoch, Value: &withRPC}},
+			wantCost: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newBillingHistory()
+			if tt.pricing != nil {
+				h.pricing["eu"] = tt.pricing
+			}
+			h.services["rpc"] = tt.service
+			h.members["alice"] = tt.member
+
+			sum := h.monthSummary(w)
+			got := sum.Members["alice"].ServiceCosts["RPC"]
+			if math.Abs(got-tt.wantCost) > 1e-9 {
+				t.Errorf("cost = %v, want %v", got, tt.wantCost)
+			}
+			if svc := sum.Services["RPC"].MemberCosts["alice"]; math.Abs(svc-got) > 1e-9 {
+				t.Errorf("service view = %v, member view = %v", svc, got)
+			}
+		})
+	}
+}
+
+func TestHistoryPeriods(t *testing.T) {
+	w := monthWindow(at("2025-04-01 00:00"))
+	day := func(d int) time.Time { return w.start.AddDate(0, 0, d-1) }
+
+	member := memberState{Region: "eu", Services: []string{"RPC"}}
+	joined10 := member
+	joined10.Joined = int(day(10).Add(6 * time.Hour).Unix())
+
+	tests := []struct {
+		name        string
+		versions    []version[memberState]
+		wantOK      bool
+		wantStart   time.Time
+		wantEnd     time.Time
+		wantPartial bool
+	}{
+		{
+			name:      "whole month",
+			versions:  []version[memberState]{{From: historyEpoch, Value: &member}},
+			wantOK:    true,
+			wantStart: w.start,
+			wantEnd:   w.end,
+		},
+		{
+			name:        "joined mid-month",
+			versions:    []version[memberState]{{From: historyEpoch, Value: &joined10}},
+			wantOK:      true,
+			wantStart:   day(10).Add(6 * time.Hour),
+			wantEnd:     w.end,
+			wantPartial: true,
+		},
+		{
+			name:        "added to config mid-month",
+			versions:    []version[memberState]{{From: day(5), Value: &member}},
+			wantOK:      true,
+			wantStart:   day(5),
+			wantEnd:     w.end,
+			wantPartial: true,
+		},
+		{
+			name:        "left mid-month",
+			versions:    []version[memberState]{{From: historyEpoch, Value: &member}, {From: day(16)}},
+			wantOK:      true,
+			wantStart:   w.start,
+			wantEnd:     day(16),
+			wantPartial: true,
+		},
+		{
+			name:     "left before the month",
+			versions: []version[memberState]{{From: historyEpoch, Value: &member}, {From: day(1).AddDate(0, -1, 0)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newBillingHistory()
+			h.members["alice"] = tt.versions
+
+			p, ok := h.periods(w)["alice"]
+			if ok != tt.wantOK {
+				t.Fatalf("period found = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if !p.Start.Equal(tt.wantStart) || !p.End.Equal(tt.wantEnd) {
+				t.Errorf("period = %s - %s, want %s - %s", p.Start, p.End, tt.wantStart, tt.wantEnd)
+			}
+			if p.Partial() != tt.wantPartial {
+				t.Errorf("partial = %v, want %v", p.Partial(), tt.wantPartial)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package billing
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	data2 "github.com/ibp-network/ibp-geodns-libs/data2"
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+
+	common "github.com/ibp-network/ibp-geodns-collator/src/common"
+)
+
+// Invoice statuses. An issued invoice can be paid or voided; both are final.
+const (
+	InvoiceIssued = "issued"
+	InvoicePaid   = "paid"
+	InvoiceVoid   = "void"
+)
+
+var (
+	// ErrInvoiceNotFound is returned when an invoice id is unknown.
+	ErrInvoiceNotFound = errors.New("invoice not found")
+	// ErrInvoiceNotIssued is returned when paying or voiding an invoice that
+	// is already paid or void.
+	ErrInvoiceNotIssued = errors.New("invoice is not in issued state")
+	// ErrPaidInvoiceChanged is returned when a month is regenerated with
+	// totals that differ from the member's paid invoice.
+	ErrPaidInvoiceChanged = errors.New("paid invoice no longer matches the month's totals")
+)
+
+// Invoice is the bill issued to a member for one month alongside its PDF.
+// Numbers are sequential across all invoices and never reused; a voided
+// invoice is replaced by a new number the next time the month is generated,
+// and an issued one is voided and replaced when the month's totals change.
+type Invoice struct {
+	ID          int64
+	Number      string
+	Sequence    int64
+	Member      string // config member ID
+	Month       time.Time
+	IssueDate   time.Time
+	DueDate     time.Time
+	BaseTotal   float64
+	Credits     float64
+	Adjustments float64
+	AmountDue   float64
+	Status      string
+	PaidAt      *time.Time
+	Reference   string // payment reference or void reason
+	Created     time.Time
+}
+
+const createInvoicesTable = `
+	CREATE TABLE IF NOT EXISTS billing_invoices (
+		id                INT UNSIGNED  NOT NULL AUTO_INCREMENT,
+		sequence          INT UNSIGNED  NOT NULL,
+		invoice_number    VARCHAR(64)   NOT NULL,
+		member_name       VARCHAR(255)  NOT NULL,
+		billing_month     DATE          NOT NULL,
+		issue_date        DATE          NOT NULL,
+		due_date          DATE          NOT NULL,
+		base_total        DECIMAL(14,2) NOT NULL,
+		credits_total     DECIMAL(14,2) NOT NULL,
+		adjustments_total DECIMAL(14,2) NOT NULL,
+		amount_due        DECIMAL(14,2) NOT NULL,
+		status            VARCHAR(16)   NOT NULL,
+		paid_at           DATETIME      NULL,
+		reference         VARCHAR(255)  NOT NULL DEFAULT '',
+		created_at        DATETIME      NOT NULL,
+		PRIMARY KEY (id),
+		UNIQUE KEY uq_invoice_sequence (sequence),
+		UNIQUE KEY uq_invoice_number (invoice_number),
+		KEY idx_invoices_month_member (billing_month, member_name)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci`
+
+// invoicesEnabled is false when the billing_invoices table could not be
+// prepared; member PDFs are then written without an invoice number.
+var invoicesEnabled bool
+
+// initInvoiceState prepares the billing_invoices table.
+func initInvoiceState() {
+	if data2.DB == nil {
+		log.Log(log.Warn, "[billing] database unavailable — invoices disabled")
+		return
+	}
+
+	if _, err := data2.DB.Exec(createInvoicesTable); err != nil {
+		log.Log(log.Error, "[billing] failed to prepare billing_invoices table: %v", err)
+		return
+	}
+	invoicesEnabled = true
+}
+
+// issueInvoice returns the member's open (issued or paid) invoice for the
+// snapshot's month, issuing a new one from the snapshot's totals when there is
+// none. An issued invoice whose totals no longer match the snapshot, e.g.
+// after an adjustment, is voided and replaced; a paid one is left alone and
+// ErrPaidInvoiceChanged returned. It returns nil without error when invoices
+// are disabled.
+func issueInvoice(memberID string, snap *Snapshot) (*Invoice, error) {
+	if !invoicesEnabled {
+		return nil, nil
+	}
+
+	credits := snap.MemberCredits(memberID)
+	_, adjustments := snap.MemberAdjustments(memberID)
+	settings := common.GetSettings().Invoices
+
+	now := time.Now().UTC()
+	inv := Invoice{
+		Member:      memberID,
+		Month:       snap.Month,
+		IssueDate:   now.Truncate(24 * time.Hour),
+		BaseTotal:   roundCents(credits.TotalBase),
+		Credits:     roundCents(credits.TotalCredit),
+		Adjustments: roundCents(adjustments),
+		Status:      InvoiceIssued,
+		Created:     now,
+	}
+	inv.DueDate = inv.IssueDate.Add(settings.DueAfter())
+	inv.AmountDue = roundCents(inv.BaseTotal - inv.Credits + inv.Adjustments)
+
+	tx, err := data2.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the sequence so numbers stay gapless under concurrent issuers.
+	// Every issuer takes this lock first, so the open invoice check below
+	// cannot race with another run issuing the same month.
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(sequence), 0) FROM billing_invoices FOR UPDATE`).Scan(&inv.Sequence); err != nil {
+		return nil, fmt.Errorf("read invoice sequence: %w", err)
+	}
+	inv.Sequence++
+	inv.Number = fmt.Sprintf("%s-%06d", settings.NumberPrefix(), inv.Sequence)
+
+	rows, err := tx.Query(selectInvoices+`
+		WHERE billing_month = ? AND member_name = ? AND status <> ?
+		ORDER BY sequence FOR UPDATE`, inv.Month.Format("2006-01-02"), memberID, InvoiceVoid)
+	if err != nil {
+		return nil, err
+	}
+	open, err := scanInvoices(rows)
+	if err != nil {
+		return nil, err
+	}
+	for i := range open {
+		existing := &open[i]
+		if existing.sameTotals(inv) {
+			return existing, nil
+		}
+		if existing.Status == InvoicePaid {
+			return nil, fmt.Errorf("%w: %s", ErrPaidInvoiceChanged, existing.Number)
+		}
+		if _, err := tx.Exec(`UPDATE billing_invoices SET status = ?, reference = ? WHERE id = ?`,
+			InvoiceVoid, "superseded by "+inv.Number, existing.ID); err != nil {
+			return nil, fmt.Errorf("void invoice %s: %w", existing.Number, err)
+		}
+		log.Log(log.Info, "[billing] Invoice %s for %s voided: amount due changed from $%.2f to $%.2f",
+			existing.Number, memberID, existing.AmountDue, inv.AmountDue)
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO billing_invoices
+			(sequence, invoice_number, member_name, billing_month, issue_date, due_date,
+			 base_total, credits_total, adjustments_total, amount_due, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		inv.Sequence, inv.Number, inv.Member, inv.Month.Format("2006-01-02"),
+		inv.IssueDate.Format("2006-01-02"), inv.DueDate.Format("2006-01-02"),
+		inv.BaseTotal, inv.Credits, inv.Adjustments, inv.AmountDue, inv.Status, inv.Created)
+	if err != nil {
+		return nil, err
+	}
+	if inv.ID, err = res.LastInsertId(); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	log.Log(log.Info, "[billing] Invoice %s issued to %s for %s: $%.2f due %s",
+		inv.Number, memberID, snap.Month.Format("2006-01"), inv.AmountDue, inv.DueDate.Format("2006-01-02"))
+	return &inv, nil
+}
+
+// sameTotals reports whether inv bills the same amounts as other, to the cent.
+func (inv Invoice) sameTotals(other Invoice) bool {
+	same := func(a, b float64) bool { return math.Abs(a-b) < 0.005 }
+	return same(inv.BaseTotal, other.BaseTotal) && same(inv.Credits, other.Credits) &&
+		same(inv.Adjustments, other.Adjustments) && same(inv.AmountDue, other.AmountDue)
+}
+
+// GetInvoice returns a single invoice.
+func GetInvoice(id int64) (Invoice, error) {
+	if !invoicesEnabled {
+		return Invoice{}, fmt.Errorf("invoices are not available")
+	}
+
+	rows, err := data2.DB.Query(selectInvoices+" WHERE id = ?", id)
+	if err != nil {
+		return Invoice{}, err
+	}
+	invoices, err := scanInvoices(rows)
+	if err != nil {
+		return Invoice{}, err
+	}
+	if len(invoices) == 0 {
+		return Invoice{}, ErrInvoiceNotFound
+	}
+	return invoices[0], nil
+}
+
+// ListInvoices returns invoices by number, optionally restricted to one member
+// (config ID), month (zero = every month) and status.
+func ListInvoices(member string, month time.Time, status string) ([]Invoice, error) {
+	if !invoicesEnabled {
+		return nil, fmt.Errorf("invoices are not available")
+	}
+
+	query := selectInvoices + " WHERE 1=1"
+	args := []interface{}{}
+
+	if member != "" {
+		query += " AND member_name = ?"
+		args = append(args, member)
+	}
+	if !month.IsZero() {
+		query += " AND billing_month = ?"
+		args = append(args, monthStart(month).Format("2006-01-02"))
+	}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY sequence"
+
+	rows, err := data2.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanInvoices(rows)
+}
+
+// MarkInvoicePaid records payment of an issued invoice.
+func MarkInvoicePaid(id int64, paidAt time.Time, reference string) error {
+	return closeInvoice(id, InvoicePaid, &paidAt, reference)
+}
+
+// VoidInvoice cancels an issued invoice. Its number is not reused.
+func VoidInvoice(id int64, reason string) error {
+	return closeInvoice(id, InvoiceVoid, nil, reason)
+}
+
+func closeInvoice(id int64, status string, paidAt *time.Time, reference string) error {
+	if !invoicesEnabled {
+		return fmt.Errorf("invoices are not available")
+	}
+
+	var paid interface{}
+	if paidAt != nil {
+		paid = paidAt.UTC()
+	}
+
+	res, err := data2.DB.Exec(`
+		UPDATE billing_invoices SET status = ?, paid_at = ?, reference = ?
+		WHERE id = ? AND status = ?`, status, paid, reference, id, InvoiceIssued)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	if _, err := GetInvoice(id); err != nil {
+		return err
+	}
+	return ErrInvoiceNotIssued
+}
+
+const selectInvoices = `
+	SELECT id, sequence, invoice_number, member_name, billing_month, issue_date, due_date,
+	       base_total, credits_total, adjustments_total, amount_due, status, paid_at,
+	       reference, created_at
+	FROM billing_invoices`
+
+func scanInvoices(rows *sql.Rows) ([]Invoice, error) {
+	defer rows.Close()
+
+	invoices := []Invoice{}
+	for rows.Next() {
+		var (
+			inv  Invoice
+			paid sql.NullTime
+		)
+		if err := rows.Scan(&inv.ID, &inv.Sequence, &inv.Number, &inv.Member, &inv.Month,
+			&inv.IssueDate, &inv.DueDate, &inv.BaseTotal, &inv.Credits, &inv.Adjustments,
+			&inv.AmountDue, &inv.Status, &paid, &inv.Reference, &inv.Created); err != nil {
+			return nil, err
+		}
+		if paid.Valid {
+			t := paid.Time
+			inv.PaidAt = &t
+		}
+		invoices = append(invoices, inv)
+	}
+	return invoices, rows.Err()
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
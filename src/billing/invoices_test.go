@@ -0,0 +1,27 @@
+package billing
+
+import "testing"
+
+func TestInvoiceSameTotals(t *testing.T) {
+	issued := Invoice{BaseTotal: 1500, Credits: 150.25, Adjustments: -20, AmountDue: 1329.75}
+
+	tests := []struct {
+		name  string
+		other Invoice
+		want  bool
+	}{
+		{"identical", issued, true},
+		{"float noise below a cent", Invoice{BaseTotal: 1500.0000001, Credits: 150.25, Adjustments: -20, AmountDue: 1329.7499999}, true},
+		{"new adjustment", Invoice{BaseTotal: 1500, Credits: 150.25, Adjustments: -70, AmountDue: 1279.75}, false},
+		{"credits moved, same amount due", Invoice{BaseTotal: 1510, Credits: 160.25, Adjustments: -20, AmountDue: 1329.75}, false},
+		{"one cent", Invoice{BaseTotal: 1500, Credits: 150.24, Adjustments: -20, AmountDue: 1329.76}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := issued.sameTotals(tt.other); got != tt.want {
+				t.Errorf("sameTotals = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
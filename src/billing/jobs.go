@@ -0,0 +1,358 @@
+package billing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+// PDF types an on-demand generation job can produce.
+const (
+	PDFOverview    = "overview"
+	PDFMember      = "member"
+	PDFServiceCost = "service-cost"
+)
+
+// Job states.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// maxPDFJobs bounds how many finished jobs are kept for the status endpoint.
+const maxPDFJobs = 100
+
+// ErrInvalidJob is returned for generation requests that cannot be run.
+var ErrInvalidJob = errors.New("invalid PDF generation request")
+
+// PDFJobRequest selects the PDFs to regenerate. Members (config IDs) limits
+// the member PDFs; empty means every member billed in Month. Month is ignored
+// when only the service cost PDF is requested.
+type PDFJobRequest struct {
+	Month   time.Time
+	Members []string
+	Types   []string
+}
+
+// PDFJob is an on-demand PDF generation run. Done counts the PDFs attempted
+// out of Total; every failed PDF adds an entry to Errors.
+type PDFJob struct {
+	ID       string
+	Request  PDFJobRequest
+	Author   string
+	Status   string
+	Created  time.Time
+	Started  *time.Time
+	Finished *time.Time
+	Total    int
+	Done     int
+	Files    []string // artifact keys
+	Errors   []string
+}
+
+var pdfJobs struct {
+	sync.Mutex
+	jobs  map[string]*PDFJob
+	order []string // oldest first
+	run   sync.Mutex
+}
+
+// StartPDFJob validates req and queues it; jobs run one at a time in the
+// background. onDone, when set, is called with the finished job.
+func StartPDFJob(req PDFJobRequest, author string, onDone func(PDFJob)) (PDFJob, error) {
+	if err := validateJobRequest(&req, time.Now().UTC()); err != nil {
+		return PDFJob{}, err
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return PDFJob{}, err
+	}
+	job := &PDFJob{ID: id, Request: req, Author: author, Status: JobQueued, Created: time.Now().UTC()}
+
+	pdfJobs.Lock()
+	if pdfJobs.jobs == nil {
+		pdfJobs.jobs = make(map[string]*PDFJob)
+	}
+	pdfJobs.jobs[id] = job
+	pdfJobs.order = append(pdfJobs.order, id)
+	pruneJobs()
+	snapshot := copyJob(job)
+	pdfJobs.Unlock()
+
+	log.Log(log.Info, "[billing] PDF job %s queued by %s: %v for %s", id, author, req.Types, req.Month.Format("2006-01"))
+
+	goScheduled(func() {
+		runPDFJob(job)
+		if onDone != nil {
+			onDone(GetPDFJob(id))
+		}
+	})
+	return snapshot, nil
+}
+
+// GetPDFJob returns a copy of the job with id, or a zero job when unknown.
+func GetPDFJob(id string) PDFJob {
+	pdfJobs.Lock()
+	defer pdfJobs.Unlock()
+	if job, ok := pdfJobs.jobs[id]; ok {
+		return copyJob(job)
+	}
+	return PDFJob{}
+}
+
+// ListPDFJobs returns the known jobs, newest first.
+func ListPDFJobs() []PDFJob {
+	pdfJobs.Lock()
+	defer pdfJobs.Unlock()
+
+	jobs := make([]PDFJob, 0, len(pdfJobs.order))
+	for i := len(pdfJobs.order) - 1; i >= 0; i-- {
+		jobs = append(jobs, copyJob(pdfJobs.jobs[pdfJobs.order[i]]))
+	}
+	return jobs
+}
+
+// validateJobRequest normalises req and rejects unknown types and months that
+// have not ended yet.
+func validateJobRequest(req *PDFJobRequest, now time.Time) error {
+	if len(req.Types) == 0 {
+		return fmt.Errorf("%w: no PDF type given", ErrInvalidJob)
+	}
+
+	seen := make(map[string]bool)
+	types := []string{}
+	monthly := false
+	for _, t := range req.Types {
+		switch t {
+		case PDFOverview, PDFMember:
+			monthly = true
+		case PDFServiceCost:
+		default:
+			return fmt.Errorf("%w: unknown type %q", ErrInvalidJob, t)
+		}
+		if !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	req.Types = types
+
+	if len(req.Members) > 0 && !seen[PDFMember] {
+		return fmt.Errorf("%w: members given without the member type", ErrInvalidJob)
+	}
+	if monthly {
+		if req.Month.IsZero() {
+			return fmt.Errorf("%w: month is required", ErrInvalidJob)
+		}
+		req.Month = monthStart(req.Month)
+		if !req.Month.Before(monthStart(now)) {
+			return fmt.Errorf("%w: %s has not ended yet", ErrInvalidJob, req.Month.Format("2006-01"))
+		}
+	}
+	return nil
+}
+
+func runPDFJob(job *PDFJob) {
+	pdfJobs.run.Lock()
+	defer pdfJobs.run.Unlock()
+
+	update := func(fn func(j *PDFJob)) {
+		pdfJobs.Lock()
+		fn(job)
+		pdfJobs.Unlock()
+	}
+	fail := func(format string, args ...interface{}) {
+		msg := fmt.Sprintf(format, args...)
+		log.Log(log.Error, "[billing] PDF job %s: %s", job.ID, msg)
+		update(func(j *PDFJob) { j.Errors = append(j.Errors, msg) })
+	}
+
+	req := job.Request
+	monthly := containsType(req.Types, PDFOverview) || containsType(req.Types, PDFMember)
+
+	// Never write a month together with the scheduled run generating it; the
+	// job stays queued until that run has finished.
+	if monthly {
+		claimBillingMonth(req.Month, job.ID)
+		defer releaseBillingMonth(req.Month)
+	}
+
+	started := time.Now().UTC()
+	update(func(j *PDFJob) { j.Status, j.Started = JobRunning, &started })
+	defer func() {
+		finished := time.Now().UTC()
+		update(func(j *PDFJob) {
+			j.Finished = &finished
+			j.Status = JobSucceeded
+			if len(j.Errors) > 0 {
+				j.Status = JobFailed
+			}
+		})
+		log.Log(log.Info, "[billing] PDF job %s finished: %s", job.ID, GetPDFJob(job.ID).Status)
+	}()
+
+	wrote := func(key string) {
+		update(func(j *PDFJob) { j.Files = append(j.Files, key) })
+	}
+	progress := func() { update(func(j *PDFJob) { j.Done++ }) }
+
+	var (
+		snap    *Snapshot
+		members []string
+	)
+	if monthly {
+		var err error
+		if snap, err = freezeSnapshot(req.Month); err != nil {
+			fail("%v", err)
+			return
+		}
+
+		members = req.Members
+		if len(members) == 0 {
+			for m := range snap.Summary.Members {
+				members = append(members, m)
+			}
+			sort.Strings(members)
+		}
+	}
+
+	total := 0
+	for _, t := range req.Types {
+		if t == PDFMember {
+			total += len(members)
+		} else {
+			total++
+		}
+	}
+	update(func(j *PDFJob) { j.Total = total })
+
+	for _, t := range req.Types {
+		switch t {
+		case PDFOverview:
+			if key, err := writeMonthlyOverviewPDF(snap); err != nil {
+				fail("overview PDF: %v", err)
+			} else {
+				wrote(key)
+			}
+			progress()
+
+		case PDFMember:
+			for _, m := range members {
+				if stopping() {
+					fail("shutdown requested")
+					return
+				}
+				if _, ok := snap.Summary.Members[m]; !ok {
+					fail("member %s was not billed in %s", m, req.Month.Format("2006-01"))
+				} else if inv, err := issueInvoice(m, snap); err != nil {
+					fail("invoice %s: %v", m, err)
+				} else if key, err := writeMemberPDF(m, snap, inv); err != nil {
+					fail("member PDF %s: %v", m, err)
+				} else {
+					wrote(key)
+				}
+				progress()
+			}
+
+		case PDFServiceCost:
+			sum := GetSummary()
+			if key, err := writeServiceCostPDF(&sum); err != nil {
+				fail("service cost PDF: %v", err)
+			} else {
+				wrote(key)
+			}
+			progress()
+		}
+	}
+
+	// rewrite the treasury proposal, whose invoice numbers and totals may have
+	// changed, then re-sign the month so the manifest matches both
+	if monthly {
+		if key, err := writeProposal(snap); err != nil {
+			fail("treasury proposal: %v", err)
+		} else {
+			wrote(key)
+		}
+		if key, err := writeManifest(req.Month); err != nil {
+			fail("manifest: %v", err)
+		} else {
+			wrote(key)
+		}
+	}
+}
+
+// claimBillingMonth waits until no scheduled run or other job is writing
+// month and claims it until releaseBillingMonth.
+func claimBillingMonth(month time.Time, jobID string) {
+	billingGenMutex.Lock()
+	defer billingGenMutex.Unlock()
+	if billingGenerationInProgress[month] {
+		log.Log(log.Info, "[billing] PDF job %s waiting for the billing run of %s to finish", jobID, month.Format("2006-01"))
+	}
+	for billingGenerationInProgress[month] {
+		billingGenerationDone.Wait()
+	}
+	billingGenerationInProgress[month] = true
+}
+
+func releaseBillingMonth(month time.Time) {
+	billingGenMutex.Lock()
+	delete(billingGenerationInProgress, month)
+	billingGenerationDone.Broadcast()
+	billingGenMutex.Unlock()
+}
+
+func containsType(types []string, t string) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneJobs drops the oldest finished jobs beyond maxPDFJobs; the caller
+// holds pdfJobs.
+func pruneJobs() {
+	for len(pdfJobs.order) > maxPDFJobs {
+		dropped := false
+		for i, id := range pdfJobs.order {
+			if job := pdfJobs.jobs[id]; job.Finished != nil {
+				delete(pdfJobs.jobs, id)
+				pdfJobs.order = append(pdfJobs.order[:i], pdfJobs.order[i+1:]...)
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			return
+		}
+	}
+}
+
+// copyJob returns a copy of job that does not share slices with it.
+func copyJob(job *PDFJob) PDFJob {
+	c := *job
+	c.Request.Members = append([]string(nil), job.Request.Members...)
+	c.Request.Types = append([]string(nil), job.Request.Types...)
+	c.Files = append([]string(nil), job.Files...)
+	c.Errors = append([]string(nil), job.Errors...)
+	return c
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
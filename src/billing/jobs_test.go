@@ -0,0 +1,97 @@
+package billing
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidateJobRequest(t *testing.T) {
+	now := at("2025-05-10 12:00")
+
+	tests := []struct {
+		name      string
+		req       PDFJobRequest
+		wantErr   bool
+		wantTypes []string
+		wantMonth time.Time
+	}{
+		{
+			name:      "member PDFs of a past month",
+			req:       PDFJobRequest{Month: at("2025-04-15 08:00"), Members: []string{"alpha"}, Types: []string{"member"}},
+			wantTypes: []string{"member"},
+			wantMonth: at("2025-04-01 00:00"),
+		},
+		{
+			name:      "duplicate types are dropped",
+			req:       PDFJobRequest{Month: at("2025-04-01 00:00"), Types: []string{"overview", "member", "overview"}},
+			wantTypes: []string{"overview", "member"},
+			wantMonth: at("2025-04-01 00:00"),
+		},
+		{
+			name:      "service cost needs no month",
+			req:       PDFJobRequest{Types: []string{"service-cost"}},
+			wantTypes: []string{"service-cost"},
+		},
+		{name: "no types", req: PDFJobRequest{Month: at("2025-04-01 00:00")}, wantErr: true},
+		{name: "unknown type", req: PDFJobRequest{Month: at("2025-04-01 00:00"), Types: []string{"invoice"}}, wantErr: true},
+		{name: "overview without month", req: PDFJobRequest{Types: []string{"overview"}}, wantErr: true},
+		{name: "month in progress", req: PDFJobRequest{Month: at("2025-05-01 00:00"), Types: []string{"overview"}}, wantErr: true},
+		{
+			name:    "members without member type",
+			req:     PDFJobRequest{Month: at("2025-04-01 00:00"), Members: []string{"alpha"}, Types: []string{"overview"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			err := validateJobRequest(&req, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidJob) {
+					t.Fatalf("error = %v, want ErrInvalidJob", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateJobRequest: %v", err)
+			}
+			if !reflect.DeepEqual(req.Types, tt.wantTypes) {
+				t.Errorf("Types = %v, want %v", req.Types, tt.wantTypes)
+			}
+			if !req.Month.Equal(tt.wantMonth) {
+				t.Errorf("Month = %s, want %s", req.Month, tt.wantMonth)
+			}
+		})
+	}
+}
+
+func TestClaimBillingMonth(t *testing.T) {
+	april, may := at("2025-04-01 00:00"), at("2025-05-01 00:00")
+
+	claimBillingMonth(april, "scheduled")
+	claimBillingMonth(may, "other month") // does not wait for april
+	releaseBillingMonth(may)
+
+	claimed := make(chan struct{})
+	go func() {
+		claimBillingMonth(april, "job")
+		close(claimed)
+	}()
+
+	select {
+	case <-claimed:
+		t.Fatal("second claim of the same month did not wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	releaseBillingMonth(april)
+	select {
+	case <-claimed:
+	case <-time.After(time.Second):
+		t.Fatal("claim was not granted after release")
+	}
+	releaseBillingMonth(april)
+}
@@ -0,0 +1,251 @@
+package billing
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	data2 "github.com/ibp-network/ibp-geodns-libs/data2"
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+// ErrMaintenanceNotFound is returned when a maintenance window id is unknown.
+var ErrMaintenanceNotFound = errors.New("maintenance window not found")
+
+// MaintenanceWindow is announced maintenance of a member. Downtime inside a
+// window is reported as excluded instead of counting against the SLA.
+type MaintenanceWindow struct {
+	ID       int64
+	Member   string   // config member ID
+	Services []string // empty = every service of the member
+	Start    time.Time
+	End      time.Time
+	Reason   string
+	Created  time.Time
+}
+
+// AppliesTo reports whether the window covers service.
+func (mw MaintenanceWindow) AppliesTo(service string) bool {
+	if len(mw.Services) == 0 {
+		return true
+	}
+	for _, s := range mw.Services {
+		if strings.EqualFold(s, service) {
+			return true
+		}
+	}
+	return false
+}
+
+const createMaintenanceTable = `
+	CREATE TABLE IF NOT EXISTS maintenance_windows (
+		id          INT UNSIGNED NOT NULL AUTO_INCREMENT,
+		member_name VARCHAR(255) NOT NULL,
+		services    TEXT,
+		start_time  DATETIME     NOT NULL,
+		end_time    DATETIME     NOT NULL,
+		reason      TEXT,
+		created_at  DATETIME     NOT NULL,
+		PRIMARY KEY (id),
+		KEY idx_maintenance_member_time (member_name, start_time, end_time)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci`
+
+// maintenanceEnabled is false when the maintenance_windows table could not be
+// prepared; SLA figures are then computed without exclusions.
+var maintenanceEnabled bool
+
+// initMaintenanceState prepares the maintenance_windows table.
+func initMaintenanceState() {
+	if data2.DB == nil {
+		log.Log(log.Warn, "[billing] database unavailable — maintenance windows disabled")
+		return
+	}
+
+	if _, err := data2.DB.Exec(createMaintenanceTable); err != nil {
+		log.Log(log.Error, "[billing] failed to prepare maintenance_windows table: %v", err)
+		return
+	}
+	maintenanceEnabled = true
+}
+
+// CreateMaintenance stores a new maintenance window and returns its id.
+func CreateMaintenance(mw MaintenanceWindow) (int64, error) {
+	if !maintenanceEnabled {
+		return 0, fmt.Errorf("maintenance windows are not available")
+	}
+	if !mw.End.After(mw.Start) {
+		return 0, fmt.Errorf("maintenance window must end after it starts")
+	}
+
+	services := mw.Services
+	if services == nil {
+		services = []string{}
+	}
+	jServices, _ := json.Marshal(services)
+
+	res, err := data2.DB.Exec(`
+		INSERT INTO maintenance_windows (member_name, services, start_time, end_time, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, UTC_TIMESTAMP())`,
+		mw.Member, string(jServices), mw.Start.UTC(), mw.End.UTC(), mw.Reason)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetMaintenance returns a single maintenance window.
+func GetMaintenance(id int64) (MaintenanceWindow, error) {
+	if !maintenanceEnabled {
+		return MaintenanceWindow{}, fmt.Errorf("maintenance windows are not available")
+	}
+
+	rows, err := data2.DB.Query(selectMaintenance+" WHERE id = ?", id)
+	if err != nil {
+		return MaintenanceWindow{}, err
+	}
+	windows, err := scanMaintenance(rows)
+	if err != nil {
+		return MaintenanceWindow{}, err
+	}
+	if len(windows) == 0 {
+		return MaintenanceWindow{}, ErrMaintenanceNotFound
+	}
+	return windows[0], nil
+}
+
+// DeleteMaintenance removes a maintenance window.
+func DeleteMaintenance(id int64) error {
+	if !maintenanceEnabled {
+		return fmt.Errorf("maintenance windows are not available")
+	}
+
+	res, err := data2.DB.Exec(`DELETE FROM maintenance_windows WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrMaintenanceNotFound
+	}
+	return nil
+}
+
+// ListMaintenance returns the windows overlapping [from, to), oldest first,
+// optionally restricted to one member (config ID). Zero times leave that side
+// of the range open.
+func ListMaintenance(member string, from, to time.Time) ([]MaintenanceWindow, error) {
+	if !maintenanceEnabled {
+		return nil, fmt.Errorf("maintenance windows are not available")
+	}
+
+	query := selectMaintenance + " WHERE 1=1"
+	args := []interface{}{}
+
+	if member != "" {
+		query += " AND member_name = ?"
+		args = append(args, member)
+	}
+	if !to.IsZero() {
+		query += " AND start_time < ?"
+		args = append(args, to.UTC())
+	}
+	if !from.IsZero() {
+		query += " AND end_time > ?"
+		args = append(args, from.UTC())
+	}
+	query += " ORDER BY start_time, id"
+
+	rows, err := data2.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanMaintenance(rows)
+}
+
+const selectMaintenance = `
+	SELECT id, member_name, COALESCE(services, ''), start_time, end_time,
+	       COALESCE(reason, ''), created_at
+	FROM maintenance_windows`
+
+func scanMaintenance(rows *sql.Rows) ([]MaintenanceWindow, error) {
+	defer rows.Close()
+
+	windows := []MaintenanceWindow{}
+	for rows.Next() {
+		var (
+			mw       MaintenanceWindow
+			services string
+		)
+		if err := rows.Scan(&mw.ID, &mw.Member, &services, &mw.Start, &mw.End, &mw.Reason, &mw.Created); err != nil {
+			return nil, err
+		}
+		mw.Services = []string{}
+		if services != "" {
+			if err := json.Unmarshal([]byte(services), &mw.Services); err != nil {
+				log.Log(log.Warn, "[billing] maintenance window %d has unreadable service list: %v", mw.ID, err)
+			}
+		}
+		windows = append(windows, mw)
+	}
+	return windows, rows.Err()
+}
+
+// memberMaintenance returns a member's windows for the SLA window, or none
+// when they cannot be loaded.
+func memberMaintenance(memberID string, w slaWindow) []MaintenanceWindow {
+	if !maintenanceEnabled {
+		return nil
+	}
+	windows, err := ListMaintenance(memberID, w.start, w.end)
+	if err != nil {
+		log.Log(log.Error, "[SLA] Failed to load maintenance windows for %s: %v", memberID, err)
+		return nil
+	}
+	return windows
+}
+
+// maintenancePeriods returns the parts of windows covering service inside w.
+func maintenancePeriods(windows []MaintenanceWindow, service string, w slaWindow) []downtimePeriod {
+	periods := []downtimePeriod{}
+	for _, mw := range windows {
+		if !mw.AppliesTo(service) {
+			continue
+		}
+		end := mw.End
+		if p, ok := w.clip(OutageEvent{Start: mw.Start, End: &end}, w.end); ok {
+			periods = append(periods, p)
+		}
+	}
+	return periods
+}
+
+// MemberMaintenanceHours returns how many hours of the month the member's
+// windows cover together with extra, whatever services they name. Overlapping
+// windows are counted once.
+func MemberMaintenanceHours(memberID string, month time.Time, extra ...MaintenanceWindow) (float64, error) {
+	w := monthWindow(month)
+	windows, err := ListMaintenance(memberID, w.start, w.end)
+	if err != nil {
+		return 0, err
+	}
+	return maintenanceHours(append(windows, extra...), w), nil
+}
+
+// maintenanceHours returns the merged hours of windows inside w.
+func maintenanceHours(windows []MaintenanceWindow, w slaWindow) float64 {
+	var periods []downtimePeriod
+	for _, mw := range windows {
+		end := mw.End
+		if p, ok := w.clip(OutageEvent{Start: mw.Start, End: &end}, w.end); ok {
+			periods = append(periods, p)
+		}
+	}
+
+	hours := 0.0
+	for _, p := range mergeOverlappingPeriods(periods) {
+		hours += p.end.Sub(p.start).Hours()
+	}
+	return hours
+}
@@ -0,0 +1,57 @@
+package billing
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMaintenanceHours(t *testing.T) {
+	w := monthWindow(at("2025-03-01 00:00"))
+
+	tests := []struct {
+		name    string
+		windows []MaintenanceWindow
+		want    float64
+	}{
+		{"none", nil, 0},
+		{
+			name: "separate windows add up",
+			windows: []MaintenanceWindow{
+				{Start: at("2025-03-02 10:00"), End: at("2025-03-02 14:00")},
+				{Services: []string{"kusama"}, Start: at("2025-03-10 00:00"), End: at("2025-03-10 06:00")},
+			},
+			want: 10,
+		},
+		{
+			name: "overlaps are counted once",
+			windows: []MaintenanceWindow{
+				{Start: at("2025-03-02 10:00"), End: at("2025-03-02 14:00")},
+				{Start: at("2025-03-02 12:00"), End: at("2025-03-02 16:00")},
+			},
+			want: 6,
+		},
+		{
+			name: "only the part inside the month counts",
+			windows: []MaintenanceWindow{
+				{Start: at("2025-02-28 20:00"), End: at("2025-03-01 02:00")},
+				{Start: at("2025-03-31 23:00"), End: at("2025-04-01 05:00")},
+			},
+			want: 3,
+		},
+		{
+			name: "windows outside the month are ignored",
+			windows: []MaintenanceWindow{
+				{Start: at("2025-04-02 10:00"), End: at("2025-04-02 14:00")},
+			},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := maintenanceHours(tt.windows, w); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("maintenanceHours = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
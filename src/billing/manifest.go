@@ -0,0 +1,199 @@
+package billing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+
+	common "github.com/ibp-network/ibp-geodns-collator/src/common"
+)
+
+// ManifestFileName is the file stored with each month's reports that lists
+// them with their size and SHA-256.
+const ManifestFileName = "manifest.json"
+
+const manifestVersion = 1
+
+var (
+	// ErrManifestNotFound is returned for months without a manifest.
+	ErrManifestNotFound = errors.New("manifest not found")
+	// ErrBadSignature is returned when a manifest is unsigned or its signature
+	// does not verify with the configured key.
+	ErrBadSignature = errors.New("manifest signature does not verify")
+)
+
+// ManifestFile is one report listed in a manifest.
+type ManifestFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"` // hex
+}
+
+// Manifest makes the reports of a month tamper-evident: Signature is the
+// Ed25519 signature of SignedPayload by the collator's signing key, whose
+// public half is recorded in PublicKey. Both are base64 encoded and empty when
+// no key is configured.
+type Manifest struct {
+	Version   int            `json:"version"`
+	Month     string         `json:"month"` // YYYY-MM
+	Generated time.Time      `json:"generated_at"`
+	Files     []ManifestFile `json:"files"`
+	PublicKey string         `json:"public_key,omitempty"`
+	Signature string         `json:"signature,omitempty"`
+}
+
+// SignedPayload returns the bytes the signature covers: a header line followed
+// by one "<sha256>  <size>  <name>" line per file, in manifest order.
+func (m *Manifest) SignedPayload() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "ibp-collator-manifest v%d %s %s\n", m.Version, m.Month, m.Generated.UTC().Format(time.RFC3339))
+	for _, f := range m.Files {
+		fmt.Fprintf(&b, "%s  %d  %s\n", f.SHA256, f.Size, f.Name)
+	}
+	return b.Bytes()
+}
+
+// sign records the public key and signature of key.
+func (m *Manifest) sign(key ed25519.PrivateKey) {
+	m.PublicKey = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	m.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, m.SignedPayload()))
+}
+
+// VerifySignature checks the signature against pub, not against the key the
+// manifest carries, which anyone rewriting it could replace.
+func (m *Manifest) VerifySignature(pub ed25519.PublicKey) error {
+	sig, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil || len(pub) != ed25519.PublicKeySize || !ed25519.Verify(pub, m.SignedPayload(), sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// File returns the entry for name.
+func (m *Manifest) File(name string) (ManifestFile, bool) {
+	for _, f := range m.Files {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return ManifestFile{}, false
+}
+
+// Match returns the entry whose content hashes to sum (hex).
+func (m *Manifest) Match(sum string) (ManifestFile, bool) {
+	for _, f := range m.Files {
+		if strings.EqualFold(f.SHA256, sum) {
+			return f, true
+		}
+	}
+	return ManifestFile{}, false
+}
+
+// SigningPublicKey returns the public half of the configured signing key, or
+// nil when none is configured.
+func SigningPublicKey() (ed25519.PublicKey, error) {
+	key, err := common.GetSettings().Signing.PrivateKey()
+	if key == nil || err != nil {
+		return nil, err
+	}
+	return key.Public().(ed25519.PublicKey), nil
+}
+
+// HashReader returns the hex SHA-256 and size of everything read from r.
+func HashReader(r io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// ReadManifest loads the manifest of month (YYYY-MM).
+func ReadManifest(month string) (*Manifest, error) {
+	raw, err := ReadArtifact(path.Join(month, ManifestFileName))
+	if errors.Is(err, ErrArtifactNotFound) {
+		return nil, ErrManifestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ManifestFileName, err)
+	}
+	return &m, nil
+}
+
+// writeManifest lists every report of month, signs the list with the
+// configured key and stores it with the reports. It returns the key.
+func writeManifest(month time.Time) (string, error) {
+	key, err := common.GetSettings().Signing.PrivateKey()
+	if err != nil {
+		return "", fmt.Errorf("signing key: %w", err)
+	}
+	m, err := buildManifest(Artifacts(), month, time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+	if key != nil {
+		m.sign(key)
+	} else {
+		log.Log(log.Warn, "[billing] no signing key configured — manifest for %s is unsigned", month.Format("January 2006"))
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	artifact := monthKey(month, ManifestFileName)
+	err = putArtifact(artifact, func(w io.Writer) error {
+		_, err := w.Write(append(data, '\n'))
+		return err
+	})
+	return artifact, err
+}
+
+// buildManifest hashes the reports of month in store, skipping the manifest
+// itself.
+func buildManifest(store ArtifactStore, month, now time.Time) (*Manifest, error) {
+	list, err := store.List(month.Format("2006-01") + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		Version:   manifestVersion,
+		Month:     month.Format("2006-01"),
+		Generated: now.UTC().Truncate(time.Second),
+		Files:     []ManifestFile{},
+	}
+	for _, a := range list { // sorted by key
+		name := a.Name()
+		if name == ManifestFileName {
+			continue
+		}
+		rc, _, err := store.Get(a.Key)
+		if err != nil {
+			return nil, err
+		}
+		sum, size, err := HashReader(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("hash %s: %w", name, err)
+		}
+		m.Files = append(m.Files, ManifestFile{Name: name, Size: size, SHA256: sum})
+	}
+	return m, nil
+}
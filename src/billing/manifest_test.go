@@ -0,0 +1,74 @@
+package billing
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestManifestSignature(t *testing.T) {
+	store := newLocalStore(t.TempDir())
+	for key, content := range map[string]string{
+		"2025-04/2025_04-Monthly_Overview.pdf":  "overview",
+		"2025-04/2025_04-IBP-Service_alpha.pdf": "alpha",
+		"2025-04/2025_04-IBP-Service_beta.pdf":  "beta",
+		"2025-04/" + ManifestFileName:           "{}",
+		"2025-03/2025_03-Monthly_Overview.pdf":  "march",
+		"service_cost_20250501.pdf":             "cost",
+	} {
+		if err := store.Put(key, strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := buildManifest(store, at("2025-04-01 00:00"), at("2025-05-01 00:05"))
+	if err != nil {
+		t.Fatalf("buildManifest: %v", err)
+	}
+	var names []string
+	for _, f := range m.Files {
+		names = append(names, f.Name)
+	}
+	if got, want := strings.Join(names, ","), "2025_04-IBP-Service_alpha.pdf,2025_04-IBP-Service_beta.pdf,2025_04-Monthly_Overview.pdf"; got != want {
+		t.Fatalf("files = %s, want %s", got, want)
+	}
+	// sha256("alpha")
+	alpha := "8ed3f6ad685b959ead7022518e1af76cd816f8e8ec7ccdda1ed4018e8f2223f8"
+	if f, ok := m.Match(strings.ToUpper(alpha)); !ok || f.Name != "2025_04-IBP-Service_alpha.pdf" || f.Size != 5 {
+		t.Errorf("Match(alpha) = %+v, %v", f, ok)
+	}
+
+	pub, key, _ := ed25519.GenerateKey(nil)
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	if err := m.VerifySignature(pub); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("unsigned manifest: err = %v, want ErrBadSignature", err)
+	}
+	m.sign(key)
+
+	tests := []struct {
+		name    string
+		pub     ed25519.PublicKey
+		tamper  func(m *Manifest)
+		wantErr bool
+	}{
+		{name: "signed", pub: pub},
+		{name: "other key", pub: otherPub, wantErr: true},
+		{name: "file hash changed", pub: pub, tamper: func(m *Manifest) { m.Files[1].SHA256 = alpha }, wantErr: true},
+		{name: "file dropped", pub: pub, tamper: func(m *Manifest) { m.Files = m.Files[1:] }, wantErr: true},
+		{name: "month changed", pub: pub, tamper: func(m *Manifest) { m.Month = "2025-03" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := *m
+			c.Files = append([]ManifestFile(nil), m.Files...)
+			if tt.tamper != nil {
+				tt.tamper(&c)
+			}
+			err := c.VerifySignature(tt.pub)
+			if tt.wantErr != (err != nil) {
+				t.Errorf("VerifySignature() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
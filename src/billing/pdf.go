@@ -2,6 +2,7 @@ package billing
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
@@ -41,6 +42,12 @@ func findLogo(baseDir string) string {
 	return ""
 }
 
+// reportLogo returns the logo of the monthly reports. It is looked up on local
+// disk next to WorkDir/tmp whatever the artifact storage backend.
+func reportLogo() string {
+	return findLogo(resolveTempDir(cfg.GetConfig()))
+}
+
 // addPageWithWatermark creates a new page, draws the centred logo (62.5%
 // width, 25% transparency) and **moves Y to 32mm** so subsequent content
 // never collides with the header/title.
@@ -70,13 +77,38 @@ func addPageWithWatermark(pdf *gofpdf.Fpdf, logo string) {
 	pdf.SetY(32.0)
 }
 
+// draftWatermark marks PDFs rendered before their month has ended.
+const draftWatermark = "DRAFT – period to date"
+
+// drawDraftWatermark stamps draftWatermark diagonally across the page centre
+// at 25% transparency. Call it from a header func so it lands on every page
+// underneath the content.
+func drawDraftWatermark(pdf *gofpdf.Fpdf) {
+	pageW, pageH := pdf.GetPageSize()
+	text := pdf.UnicodeTranslatorFromDescriptor("")(draftWatermark)
+
+	pdf.SetFont("Helvetica", "B", 54)
+	textW := pdf.GetStringWidth(text)
+	_, fontH := pdf.GetFontSize()
+	cx, cy := pageW/2, pageH/2
+
+	pdf.SetAlpha(0.25, "Normal")
+	pdf.SetTextColor(200, 30, 30)
+	pdf.TransformBegin()
+	pdf.TransformRotate(math.Atan2(pageH, pageW)*180/math.Pi, cx, cy)
+	pdf.Text(cx-textW/2, cy+fontH/3, text)
+	pdf.TransformEnd()
+	pdf.SetAlpha(1, "Normal")
+	pdf.SetTextColor(0, 0, 0)
+}
+
 /*
 	---------------------------------------------------------------------
 	                     "cost by service" — PDF report
 
 ---------------------------------------------------------------------
 */
-func writeServiceCostPDF(sum *Summary, tmpDir string) error {
+func writeServiceCostPDF(sum *Summary) (string, error) {
 	c := cfg.GetConfig()
 	logoPath := findLogo(c.Local.System.WorkDir)
 
@@ -217,14 +249,13 @@ func writeServiceCostPDF(sum *Summary, tmpDir string) error {
 	pdf.CellFormat(colCostW, rowH+1, fmt.Sprintf("$%.2f", grand),
 		"1", 1, "R", false, 0, "")
 
-	filename := filepath.Join(tmpDir,
-		fmt.Sprintf("service_cost_%s.pdf", time.Now().UTC().Format("20060102")))
-	if err := pdf.OutputFileAndClose(filename); err != nil {
-		return err
+	key := fmt.Sprintf("service_cost_%s.pdf", time.Now().UTC().Format("20060102"))
+	if err := putArtifact(key, pdf.Output); err != nil {
+		return "", err
 	}
 
-	log.Log(log.Info, "[billing] service-cost PDF written → %s", filename)
-	return nil
+	log.Log(log.Info, "[billing] service-cost PDF written → %s", key)
+	return key, nil
 }
 
 /*
@@ -233,24 +264,6 @@ func writeServiceCostPDF(sum *Summary, tmpDir string) error {
 
 ---------------------------------------------------------------------
 */
-func getSLABreakdown(sla SLASummary, member, service string) SLABreakdown {
-	if upm, ok := sla[member]; ok {
-		if bd, ok2 := upm[service]; ok2 {
-			return bd
-		}
-	}
-
-	// Return default if not found
-	return SLABreakdown{
-		HoursTotal:   730, // Default month hours
-		HoursDown:    0,
-		HoursUp:      730,
-		Uptime:       100.0,
-		SLAThreshold: DefaultSLAPercentage,
-		SLAHours:     730 * (DefaultSLAPercentage / 100.0),
-		MeetsSLA:     true,
-	}
-}
 
 // MemberStats holds DNS request statistics for a member
 type MemberStats struct {
@@ -342,6 +355,16 @@ func calculateMemberStats(month time.Time) map[string]MemberStats {
 	return stats
 }
 
+// overviewPDFName is the file name of the monthly overview PDF.
+func overviewPDFName(month time.Time) string {
+	return fmt.Sprintf("%s-Monthly_Overview.pdf", month.Format("2006_01"))
+}
+
+// memberPDFName is the file name of a member's monthly service PDF.
+func memberPDFName(memberName string, month time.Time) string {
+	return fmt.Sprintf("%s-IBP-Service_%s.pdf", month.Format("2006_01"), sanitizeFilename(memberName))
+}
+
 /* --------------------------------------------------------------------- */
 
 func Version() string {
@@ -12,11 +12,8 @@ import (
 	"time"
 
 	cfg "github.com/ibp-network/ibp-geodns-libs/config"
-	data2 "github.com/ibp-network/ibp-geodns-libs/data2"
 	log "github.com/ibp-network/ibp-geodns-libs/logging"
 
-	common "github.com/ibp-network/ibp-geodns-collator/src/common"
-
 	"github.com/phpdave11/gofpdf"
 )
 
@@ -120,13 +117,26 @@ func groupServicesByLevel(memberCost MemberCost, services map[string]cfg.Service
 	return levelGroups
 }
 
-// writeMemberPDF generates an individual PDF for a member
-func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir string, month time.Time) error {
-	c := cfg.GetConfig()
-	logoPath := findLogo(filepath.Dir(outDir))
+// writeMemberPDF generates an individual PDF for a member and returns its
+// key. inv is the member's invoice for the month, or nil when invoices are
+// disabled.
+func writeMemberPDF(memberName string, snap *Snapshot, inv *Invoice) (string, error) {
+	key := monthKey(snap.Month, memberPDFName(memberName, snap.Month))
+	pdf := renderMemberPDF(memberName, snap, inv, reportLogo(), false)
+	if err := putArtifact(key, pdf.Output); err != nil {
+		return "", err
+	}
 
-	filename := filepath.Join(outDir, fmt.Sprintf("%s-IBP-Service_%s.pdf",
-		month.Format("2006_01"), sanitizeFilename(memberName)))
+	log.Log(log.Info, "[billing] Member PDF written → %s", key)
+	return key, nil
+}
+
+// renderMemberPDF lays out the member PDF; draft stamps every page with the
+// period-to-date watermark.
+func renderMemberPDF(memberName string, snap *Snapshot, inv *Invoice, logoPath string, draft bool) *gofpdf.Fpdf {
+	sum, sla, month := &snap.Summary, snap.SLA, snap.Month
+	period, partial := snap.MemberPeriod(memberName)
+	c := cfg.GetConfig()
 
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.SetTitle(fmt.Sprintf("IBP Service Report - %s", memberName), false)
@@ -134,6 +144,10 @@ func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir stri
 
 	// Custom header with modern design
 	pdf.SetHeaderFuncMode(func() {
+		if draft {
+			drawDraftWatermark(pdf)
+		}
+
 		pdf.SetFillColor(30, 30, 30)
 		pdf.Rect(0, 0, 210, 25, "F")
 
@@ -150,11 +164,21 @@ func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir stri
 		pdf.SetFont("Helvetica", "", 10)
 		pdf.SetXY(50, 16)
 		pdf.CellFormat(100, 5, month.Format("January 2006"), "", 0, "L", false, 0, "")
+		if partial {
+			pdf.SetFont("Helvetica", "I", 8)
+			pdf.SetXY(50, 20)
+			pdf.CellFormat(100, 4, "Partial period: "+period.Describe(), "", 0, "L", false, 0, "")
+		}
 
 		// Member name on right
 		pdf.SetFont("Helvetica", "B", 12)
 		pdf.SetXY(150, 10)
 		pdf.CellFormat(50, 8, memberName, "", 0, "R", false, 0, "")
+		if inv != nil {
+			pdf.SetFont("Helvetica", "", 9)
+			pdf.SetXY(130, 17)
+			pdf.CellFormat(70, 5, "Invoice "+inv.Number, "", 0, "R", false, 0, "")
+		}
 
 		pdf.SetTextColor(0, 0, 0)
 		pdf.SetY(30)
@@ -330,20 +354,22 @@ func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir stri
 	totalDisk := 0.0
 	totalBandwidth := 0.0
 
-	for svcName, baseCost := range memberCost.ServiceCosts {
+	credits := snap.MemberCredits(memberName)
+	adjustments, adjustmentTotal := snap.MemberAdjustments(memberName)
+
+	for svcName := range memberCost.ServiceCosts {
 		totalServices++
-		breakdown := getSLABreakdown(sla, memberName, svcName)
-		billed := baseCost * (breakdown.Uptime / 100.0)
-		totalBilled += billed
+		breakdown := sla.Breakdown(memberName, svcName)
+		totalBilled += credits.Services[svcName].Billed
 		totalDowntimeHours += breakdown.HoursDown
 		totalServiceHours += breakdown.HoursTotal
 
 		// Get resource totals
-		if svcConfig, exists := c.Services[svcName]; exists {
-			totalCores += svcConfig.Resources.Cores * float64(svcConfig.Resources.Nodes)
-			totalMemory += svcConfig.Resources.Memory * float64(svcConfig.Resources.Nodes)
-			totalDisk += svcConfig.Resources.Disk * float64(svcConfig.Resources.Nodes)
-			totalBandwidth += svcConfig.Resources.Bandwidth * float64(svcConfig.Resources.Nodes)
+		if res, exists := snap.ServiceResources(svcName); exists {
+			totalCores += res.Cores * float64(res.Nodes)
+			totalMemory += res.Memory * float64(res.Nodes)
+			totalDisk += res.Disk * float64(res.Nodes)
+			totalBandwidth += res.Bandwidth * float64(res.Nodes)
 		}
 	}
 
@@ -366,9 +392,18 @@ func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir stri
 	pdf.CellFormat(35, 5, "Total Payment:", "", 0, "L", false, 0, "")
 	pdf.SetX(50)
 	pdf.SetFont("Helvetica", "B", 10)
-	pdf.CellFormat(30, 5, fmt.Sprintf("$%.2f", totalBilled), "", 0, "L", false, 0, "")
+	pdf.CellFormat(30, 5, fmt.Sprintf("$%.2f", totalBilled+adjustmentTotal), "", 0, "L", false, 0, "")
 	pdf.SetFont("Helvetica", "", 10)
 
+	if len(adjustments) > 0 {
+		pdf.SetXY(140, y)
+		pdf.CellFormat(25, 5, "Adjustments:", "", 0, "L", false, 0, "")
+		pdf.SetX(165)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(30, 5, formatSignedAmount(adjustmentTotal), "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+	}
+
 	pdf.SetXY(80, y)
 	pdf.CellFormat(35, 5, "SLA Credits:", "", 0, "L", false, 0, "")
 	pdf.SetX(115)
@@ -395,7 +430,7 @@ func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir stri
 	pdf.SetXY(80, y)
 	pdf.CellFormat(35, 5, "Avg Uptime:", "", 0, "L", false, 0, "")
 	pdf.SetX(115)
-	if totalUptime < DefaultSLAPercentage {
+	if totalUptime < SLATarget(memberName, "") {
 		pdf.SetTextColor(255, 0, 0)
 	} else {
 		pdf.SetTextColor(0, 150, 0)
@@ -463,6 +498,9 @@ func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir stri
 
 	memberTotal := 0.0
 
+	// Announced maintenance for the month, listed per service
+	memberWindows := memberMaintenance(memberName, monthWindow(month))
+
 	// Process each level group
 	for _, level := range levels {
 		services := levelGroups[level]
@@ -487,11 +525,23 @@ func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir stri
 		for _, svcName := range services {
 			// Calculate service card height based on downtime events
 			baseHeight := 33.0
-			events := getServiceDowntimeEvents(dbMemberName, svcName, month)
-			filteredEvents := filterEvents(events, 5) // 5+ minute events
+			outages, err := snap.ServiceOutages(memberName, svcName)
+			if err != nil {
+				log.Log(log.Error, "[billing] Failed to load downtime events for %s/%s: %v", memberName, svcName, err)
+			}
+			filteredEvents := filterOutages(outages, 5) // 5+ minute events
 			if len(filteredEvents) > 0 {
 				baseHeight += 8 + float64(len(filteredEvents))*6 // Header + rows
 			}
+			windows := []MaintenanceWindow{}
+			for _, mw := range memberWindows {
+				if mw.AppliesTo(svcName) {
+					windows = append(windows, mw)
+				}
+			}
+			if len(windows) > 0 {
+				baseHeight += 8 + float64(len(windows))*6
+			}
 
 			if y+baseHeight > 270 {
 				pdf.AddPage()
@@ -509,8 +559,8 @@ func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir stri
 			pdf.CellFormat(180, 6, svcName, "", 1, "L", false, 0, "")
 
 			baseCost := memberCost.ServiceCosts[svcName]
-			breakdown := getSLABreakdown(sla, memberName, svcName)
-			billed := baseCost * (breakdown.Uptime / 100.0)
+			breakdown := sla.Breakdown(memberName, svcName)
+			billed := credits.Services[svcName].Billed
 			levelTotal += billed
 			memberTotal += billed
 
@@ -519,15 +569,15 @@ func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir stri
 			serviceY := y + 12
 
 			// Resources
-			if svcConfig, exists := c.Services[svcName]; exists {
+			if res, exists := snap.ServiceResources(svcName); exists {
 				pdf.SetXY(15, serviceY)
 				pdf.SetTextColor(100, 100, 100)
 				resourceText := fmt.Sprintf("Resources: %d nodes, %.1f cores, %.1f GB RAM, %.1f GB disk, %.1f GB bandwidth",
-					svcConfig.Resources.Nodes,
-					svcConfig.Resources.Cores,
-					svcConfig.Resources.Memory,
-					svcConfig.Resources.Disk,
-					svcConfig.Resources.Bandwidth)
+					res.Nodes,
+					res.Cores,
+					res.Memory,
+					res.Disk,
+					res.Bandwidth)
 				pdf.CellFormat(180, 4, resourceText, "", 1, "L", false, 0, "")
 				pdf.SetTextColor(0, 0, 0)
 				serviceY += 5
@@ -544,7 +594,7 @@ func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir stri
 			pdf.SetX(70)
 			pdf.CellFormat(20, 5, "Uptime:", "", 0, "L", false, 0, "")
 			pdf.SetX(90)
-			if breakdown.Uptime < DefaultSLAPercentage {
+			if breakdown.Uptime < breakdown.SLAThreshold {
 				pdf.SetTextColor(255, 0, 0)
 			} else {
 				pdf.SetTextColor(0, 128, 0)
@@ -567,11 +617,11 @@ func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir stri
 			pdf.SetXY(15, serviceY)
 			if breakdown.MeetsSLA {
 				pdf.SetTextColor(0, 128, 0)
-				pdf.CellFormat(180, 4, fmt.Sprintf("[OK] Meets SLA requirement of %.2f%%", DefaultSLAPercentage), "", 1, "L", false, 0, "")
+				pdf.CellFormat(180, 4, fmt.Sprintf("[OK] Meets SLA requirement of %.2f%%", breakdown.SLAThreshold), "", 1, "L", false, 0, "")
 			} else {
 				pdf.SetTextColor(255, 0, 0)
-				pdf.CellFormat(180, 4, fmt.Sprintf("[FAIL] Below SLA: %.2f hours downtime (%.2f%% uptime required)",
-					breakdown.HoursDown, DefaultSLAPercentage), "", 1, "L", false, 0, "")
+				pdf.CellFormat(180, 4, fmt.Sprintf("[FAIL] Below SLA: %.2f hours downtime (%.2f%% uptime required)%s",
+					breakdown.HoursDown, breakdown.SLAThreshold, creditNote(credits.Services[svcName])), "", 1, "L", false, 0, "")
 			}
 			pdf.SetTextColor(0, 0, 0)
 			serviceY += 6
@@ -600,13 +650,20 @@ func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir stri
 
 				// Downtime rows
 				for _, event := range filteredEvents {
-					duration := event.EndTime.Sub(event.StartTime)
+					duration := formatDuration(time.Duration(event.HoursDown * float64(time.Hour)))
+					if event.HoursExcluded > 0 {
+						duration += " +" + formatDuration(time.Duration(event.HoursExcluded*float64(time.Hour))) + " maint."
+					}
+					endText := event.To.Format("Jan 2 15:04 UTC")
+					if event.End == nil {
+						endText += " (ongoing)"
+					}
 					pdf.SetXY(15, serviceY)
 					pdf.SetFont("Helvetica", "", 6)
-					pdf.CellFormat(25, 4, formatDuration(duration), "1", 0, "L", false, 0, "")
-					pdf.CellFormat(50, 4, event.StartTime.Format("Jan 2 15:04 UTC"), "1", 0, "L", false, 0, "")
-					pdf.CellFormat(50, 4, event.EndTime.Format("Jan 2 15:04 UTC"), "1", 0, "L", false, 0, "")
-					errorText := event.ErrorText
+					pdf.CellFormat(25, 4, duration, "1", 0, "L", false, 0, "")
+					pdf.CellFormat(50, 4, event.From.Format("Jan 2 15:04 UTC"), "1", 0, "L", false, 0, "")
+					pdf.CellFormat(50, 4, endText, "1", 0, "L", false, 0, "")
+					errorText := event.Error
 					if len(errorText) > 40 {
 						errorText = errorText[:37] + "..."
 					}
@@ -615,6 +672,46 @@ func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir stri
 				}
 			}
 
+			// Scheduled maintenance for this service (downtime inside is excluded)
+			if len(windows) > 0 {
+				if len(filteredEvents) > 0 {
+					serviceY += 2
+				}
+				pdf.SetDrawColor(200, 200, 200)
+				pdf.Line(15, serviceY, 195, serviceY)
+				pdf.SetDrawColor(0, 0, 0)
+				serviceY += 3
+
+				pdf.SetFont("Helvetica", "B", 8)
+				pdf.SetXY(15, serviceY)
+				pdf.CellFormat(180, 4, fmt.Sprintf("Scheduled Maintenance (%.2f hours of downtime excluded from SLA):",
+					breakdown.HoursExcluded), "", 1, "L", false, 0, "")
+				serviceY += 5
+
+				pdf.SetFont("Helvetica", "", 7)
+				pdf.SetFillColor(245, 245, 245)
+				pdf.SetXY(15, serviceY)
+				pdf.CellFormat(25, 4, "Duration", "1", 0, "L", true, 0, "")
+				pdf.CellFormat(50, 4, "Start Time", "1", 0, "L", true, 0, "")
+				pdf.CellFormat(50, 4, "End Time", "1", 0, "L", true, 0, "")
+				pdf.CellFormat(55, 4, "Reason", "1", 1, "L", true, 0, "")
+				serviceY += 4
+
+				for _, mw := range windows {
+					pdf.SetXY(15, serviceY)
+					pdf.SetFont("Helvetica", "", 6)
+					pdf.CellFormat(25, 4, formatDuration(mw.End.Sub(mw.Start)), "1", 0, "L", false, 0, "")
+					pdf.CellFormat(50, 4, mw.Start.Format("Jan 2 15:04 UTC"), "1", 0, "L", false, 0, "")
+					pdf.CellFormat(50, 4, mw.End.Format("Jan 2 15:04 UTC"), "1", 0, "L", false, 0, "")
+					reason := mw.Reason
+					if len(reason) > 40 {
+						reason = reason[:37] + "..."
+					}
+					pdf.CellFormat(55, 4, reason, "1", 1, "L", false, 0, "")
+					serviceY += 4
+				}
+			}
+
 			y = serviceY + 12
 		}
 
@@ -632,8 +729,51 @@ func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir stri
 		}
 	}
 
-	// Total summary
-	if y > 240 {
+	// Manual adjustments granted by treasury
+	if len(adjustments) > 0 {
+		tableHeight := 14 + float64(len(adjustments))*5
+		if y+tableHeight > 270 {
+			pdf.AddPage()
+			y = 35
+		}
+
+		pdf.SetFont("Helvetica", "B", 14)
+		pdf.SetXY(10, y-2)
+		pdf.CellFormat(190, 7, "Adjustments", "", 1, "L", false, 0, "")
+		y += 6
+
+		pdf.SetFont("Helvetica", "B", 8)
+		pdf.SetFillColor(245, 245, 245)
+		pdf.SetXY(10, y)
+		pdf.CellFormat(25, 5, "Date", "1", 0, "L", true, 0, "")
+		pdf.CellFormat(40, 5, "Service", "1", 0, "L", true, 0, "")
+		pdf.CellFormat(95, 5, "Reason", "1", 0, "L", true, 0, "")
+		pdf.CellFormat(30, 5, "Amount", "1", 1, "R", true, 0, "")
+		y += 5
+
+		pdf.SetFont("Helvetica", "", 8)
+		for _, a := range adjustments {
+			service := a.Service
+			if service == "" {
+				service = "-"
+			}
+			reason := a.Reason
+			if len(reason) > 60 {
+				reason = reason[:57] + "..."
+			}
+			pdf.SetXY(10, y)
+			pdf.CellFormat(25, 5, a.Created.Format("Jan 2 2006"), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(40, 5, service, "1", 0, "L", false, 0, "")
+			pdf.CellFormat(95, 5, reason, "1", 0, "L", false, 0, "")
+			pdf.CellFormat(30, 5, formatSignedAmount(a.Amount), "1", 1, "R", false, 0, "")
+			y += 5
+		}
+		y += 10
+	}
+
+	// Total summary, followed by the invoice line and converted totals
+	rates := monthRates(month)
+	if y > 240-float64(len(rates))*5 {
 		pdf.AddPage()
 		y = 35
 	}
@@ -645,201 +785,33 @@ func writeMemberPDF(memberName string, sum *Summary, sla SLASummary, outDir stri
 	pdf.SetFont("Helvetica", "B", 16)
 	pdf.SetXY(15, y+7)
 	pdf.CellFormat(140, 6, "Total Amount Due (All Services)", "", 0, "L", false, 0, "")
-	pdf.CellFormat(35, 6, fmt.Sprintf("$%.2f", memberTotal), "", 0, "R", false, 0, "")
+	pdf.CellFormat(35, 6, fmt.Sprintf("$%.2f", memberTotal+adjustmentTotal), "", 0, "R", false, 0, "")
 	pdf.SetTextColor(0, 0, 0)
 
-	if err := pdf.OutputFileAndClose(filename); err != nil {
-		return err
-	}
-
-	log.Log(log.Info, "[billing] Member PDF written → %s", filename)
-	return nil
-}
-
-// getServiceDowntimeEvents retrieves downtime events for a specific service
-func getServiceDowntimeEvents(memberName, serviceName string, month time.Time) []DowntimeEvent {
-	events := []DowntimeEvent{}
-	if data2.DB == nil {
-		return events
+	if inv != nil {
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.SetXY(10, y+22)
+		pdf.CellFormat(190, 5, fmt.Sprintf("Invoice %s  |  Issued %s  |  Due %s", inv.Number,
+			inv.IssueDate.Format("January 2, 2006"), inv.DueDate.Format("January 2, 2006")), "", 0, "R", false, 0, "")
 	}
 
-	// Map service to domains
-	c := cfg.GetConfig()
-	domains := []string{}
-	if svc, exists := c.Services[serviceName]; exists {
-		for _, provider := range svc.Providers {
-			for _, rpcUrl := range provider.RpcUrls {
-				if domain := extractDomainFromURL(rpcUrl); domain != "" {
-					domains = append(domains, domain)
-				}
-			}
-		}
+	amountDue := memberTotal + adjustmentTotal
+	for i, rate := range rates {
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.SetXY(10, y+27+float64(i)*5)
+		pdf.CellFormat(190, 5, fmt.Sprintf("= %s  %s", FormatAmount(rate.Convert(amountDue), rate.Currency),
+			describeRate(rate)), "", 0, "R", false, 0, "")
 	}
 
-	startTime := month
-	endTime := month.AddDate(0, 1, 0).Add(-time.Nanosecond)
-
-	// First, get site-level events (affects all services)
-	siteQuery := `
-		SELECT  
-			check_type,
-			check_name,
-			COALESCE(domain_name, '') as domain_name,
-			COALESCE(endpoint, '') as endpoint,
-			start_time,
-			end_time,
-			COALESCE(error, '') as error,
-			COALESCE(vote_data, '') as vote_data,
-			is_ipv6
-		FROM member_events
-		WHERE member_name = ?
-		AND check_type IN ('site', '1')
-		AND status = 0
-		AND (
-			(start_time < ? AND (end_time IS NULL OR end_time > ?))
-			OR
-			(start_time >= ? AND start_time < ?)
-		)
-		ORDER BY start_time DESC
-	`
-
-	rows, err := data2.DB.Query(siteQuery, memberName, endTime, startTime, startTime, endTime)
-	if err != nil {
-		log.Log(log.Error, "[billing] Failed to query site downtime events: %v", err)
-	} else {
-		defer rows.Close()
-		for rows.Next() {
-			var event DowntimeEvent
-			var endTimePtr *time.Time
-			err := rows.Scan(
-				&event.CheckType,
-				&event.CheckName,
-				&event.DomainName,
-				&event.Endpoint,
-				&event.StartTime,
-				&endTimePtr,
-				&event.ErrorText,
-				&event.VoteData,
-				&event.IsIPv6,
-			)
-			if err != nil {
-				log.Log(log.Error, "[billing] Failed to scan site downtime event: %v", err)
-				continue
-			}
-
-			event.CheckType = common.NormalizeCheckType(event.CheckType)
-
-			// Adjust times to be within month
-			if event.StartTime.Before(startTime) {
-				event.StartTime = startTime
-			}
-
-			if endTimePtr != nil {
-				event.EndTime = *endTimePtr
-				if event.EndTime.After(endTime) {
-					event.EndTime = endTime
-				}
-			} else {
-				event.EndTime = endTime
-			}
-
-			events = append(events, event)
-		}
-	}
-
-	// Then get service-specific events
-	if len(domains) > 0 {
-		// Build parameterized query with proper placeholders
-		placeholders := make([]string, len(domains))
-		args := make([]interface{}, 0, len(domains)+5)
-		args = append(args, memberName)
-		for i, domain := range domains {
-			placeholders[i] = "?"
-			args = append(args, domain)
-		}
-		args = append(args, endTime, startTime, startTime, endTime)
-
-		// Safe query construction with parameterized inputs
-		query := fmt.Sprintf(`
-			SELECT  
-				check_type,
-				check_name,
-				COALESCE(domain_name, '') as domain_name,
-				COALESCE(endpoint, '') as endpoint,
-				start_time,
-				end_time,
-				COALESCE(error, '') as error,
-				COALESCE(vote_data, '') as vote_data,
-				is_ipv6
-			FROM member_events
-			WHERE member_name = ?
-			AND check_type IN ('domain', '2', 'endpoint', '3')
-			AND status = 0
-			AND domain_name IN (%s)
-			AND (
-				(start_time < ? AND (end_time IS NULL OR end_time > ?))
-				OR
-				(start_time >= ? AND start_time < ?)
-			)
-			ORDER BY start_time DESC
-		`, strings.Join(placeholders, ","))
-
-		rows, err := data2.DB.Query(query, args...)
-		if err != nil {
-			log.Log(log.Error, "[billing] Failed to query service downtime events: %v", err)
-			return events
-		}
-		defer rows.Close()
-
-		for rows.Next() {
-			var event DowntimeEvent
-			var endTimePtr *time.Time
-			err := rows.Scan(
-				&event.CheckType,
-				&event.CheckName,
-				&event.DomainName,
-				&event.Endpoint,
-				&event.StartTime,
-				&endTimePtr,
-				&event.ErrorText,
-				&event.VoteData,
-				&event.IsIPv6,
-			)
-			if err != nil {
-				log.Log(log.Error, "[billing] Failed to scan downtime event: %v", err)
-				continue
-			}
-
-			event.CheckType = common.NormalizeCheckType(event.CheckType)
-
-			// Adjust times to be within month
-			if event.StartTime.Before(startTime) {
-				event.StartTime = startTime
-			}
-
-			if endTimePtr != nil {
-				event.EndTime = *endTimePtr
-				if event.EndTime.After(endTime) {
-					event.EndTime = endTime
-				}
-			} else {
-				event.EndTime = endTime
-			}
-
-			events = append(events, event)
-		}
-	}
-
-	return events
+	return pdf
 }
 
-// filterEvents filters events to only show those longer than minMinutes
-func filterEvents(events []DowntimeEvent, minMinutes float64) []DowntimeEvent {
-	filtered := []DowntimeEvent{}
-	for _, event := range events {
-		duration := event.EndTime.Sub(event.StartTime)
-		if duration.Minutes() >= minMinutes {
-			filtered = append(filtered, event)
+// filterOutages keeps the outages that lasted at least minMinutes
+func filterOutages(outages []ServiceOutage, minMinutes float64) []ServiceOutage {
+	filtered := []ServiceOutage{}
+	for _, o := range outages {
+		if o.To.Sub(o.From).Minutes() >= minMinutes {
+			filtered = append(filtered, o)
 		}
 	}
 	return filtered
@@ -870,6 +842,20 @@ func sanitizeFilename(name string) string {
 	return replacer.Replace(name)
 }
 
+// describeRate renders the rate used for converted totals, e.g.
+// "(1 DOT = $4.3600, rate of 2024-09-30)".
+func describeRate(rate ExchangeRate) string {
+	return fmt.Sprintf("(1 %s = $%.4f, rate of %s)", rate.Currency, rate.UsdPerUnit, rate.Date.Format("2006-01-02"))
+}
+
+// formatSignedAmount renders an adjustment as "+$10.00" or "-$10.00".
+func formatSignedAmount(amount float64) string {
+	if amount < 0 {
+		return fmt.Sprintf("-$%.2f", -amount)
+	}
+	return fmt.Sprintf("+$%.2f", amount)
+}
+
 func formatDuration(d time.Duration) string {
 	days := int(d.Hours()) / 24
 	hours := int(d.Hours()) % 24
@@ -882,107 +868,3 @@ func formatDuration(d time.Duration) string {
 	}
 	return fmt.Sprintf("%dm", minutes)
 }
-
-// getMemberDowntimeEvents retrieves downtime events for a member in the given month
-func getMemberDowntimeEvents(memberName string, month time.Time) []DowntimeEvent {
-	events := []DowntimeEvent{}
-
-	if data2.DB == nil {
-		return events
-	}
-
-	startTime := month
-	endTime := month.AddDate(0, 1, 0).Add(-time.Second)
-
-	query := `
-		SELECT 
-			check_type,
-			check_name,
-			COALESCE(domain_name, '') as domain_name,
-			COALESCE(endpoint, '') as endpoint,
-			start_time,
-			COALESCE(end_time, ?) as end_time,
-			COALESCE(error, '') as error,
-			COALESCE(vote_data, '') as vote_data,
-			is_ipv6
-		FROM member_events
-		WHERE member_name = ?
-		AND status = 0
-		AND start_time < ?
-		AND (end_time IS NULL OR end_time > ?)
-		ORDER BY start_time DESC
-	`
-
-	rows, err := data2.DB.Query(query, endTime, memberName, endTime, startTime)
-	if err != nil {
-		log.Log(log.Error, "[billing] Failed to query downtime events: %v", err)
-		return events
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var event DowntimeEvent
-		err := rows.Scan(
-			&event.CheckType,
-			&event.CheckName,
-			&event.DomainName,
-			&event.Endpoint,
-			&event.StartTime,
-			&event.EndTime,
-			&event.ErrorText,
-			&event.VoteData,
-			&event.IsIPv6,
-		)
-		if err != nil {
-			log.Log(log.Error, "[billing] Failed to scan downtime event: %v", err)
-			continue
-		}
-
-		event.CheckType = common.NormalizeCheckType(event.CheckType)
-
-		// Adjust times to be within month
-		if event.StartTime.Before(startTime) {
-			event.StartTime = startTime
-		}
-		if event.EndTime.After(endTime) {
-			event.EndTime = endTime
-		}
-
-		events = append(events, event)
-	}
-
-	return events
-}
-
-func getServiceFromEvent(event DowntimeEvent) string {
-	if event.CheckType == "site" {
-		return "All Services"
-	}
-
-	// Map domain to service
-	c := cfg.GetConfig()
-	for svcName, svc := range c.Services {
-		for _, provider := range svc.Providers {
-			for _, rpcUrl := range provider.RpcUrls {
-				if strings.Contains(rpcUrl, event.DomainName) {
-					return svcName
-				}
-			}
-		}
-	}
-
-	return event.DomainName
-}
-
-// DowntimeEvent represents a downtime event
-type DowntimeEvent struct {
-	CheckType  string
-	CheckName  string
-	DomainName string
-	Endpoint   string
-	StartTime  time.Time
-	EndTime    time.Time
-	ErrorText  string
-	VoteData   string
-	IsIPv6     bool
-}
@@ -2,7 +2,6 @@ package billing
 
 import (
 	"fmt"
-	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -41,10 +40,23 @@ type ServiceStats struct {
 	Change6Months float64
 }
 
-// writeMonthlyOverviewPDF generates a summary PDF for all members with modern design
-func writeMonthlyOverviewPDF(sum *Summary, sla SLASummary, outDir string, month time.Time) error {
-	logoPath := findLogo(filepath.Dir(outDir))
-	filename := filepath.Join(outDir, fmt.Sprintf("%s-Monthly_Overview.pdf", month.Format("2006_01")))
+// writeMonthlyOverviewPDF generates a summary PDF for all members with modern
+// design and returns its key.
+func writeMonthlyOverviewPDF(snap *Snapshot) (string, error) {
+	key := monthKey(snap.Month, overviewPDFName(snap.Month))
+	pdf := renderOverviewPDF(snap, reportLogo(), false)
+	if err := putArtifact(key, pdf.Output); err != nil {
+		return "", err
+	}
+
+	log.Log(log.Info, "[billing] Monthly overview PDF written → %s", key)
+	return key, nil
+}
+
+// renderOverviewPDF lays out the monthly overview; draft stamps every page
+// with the period-to-date watermark.
+func renderOverviewPDF(snap *Snapshot, logoPath string, draft bool) *gofpdf.Fpdf {
+	sum, sla, month := &snap.Summary, snap.SLA, snap.Month
 
 	pdf := gofpdf.New("L", "mm", "A4", "") // Landscape
 	pdf.SetTitle("IBP Monthly Billing Sheet", false)
@@ -52,6 +64,10 @@ func writeMonthlyOverviewPDF(sum *Summary, sla SLASummary, outDir string, month
 
 	// Modern header with logo
 	pdf.SetHeaderFuncMode(func() {
+		if draft {
+			drawDraftWatermark(pdf)
+		}
+
 		// Dark header background
 		pdf.SetFillColor(30, 30, 30)
 		pdf.Rect(0, 0, 297, 30, "F")
@@ -101,7 +117,7 @@ func writeMonthlyOverviewPDF(sum *Summary, sla SLASummary, outDir string, month
 	sort.Strings(memberNames)
 
 	// Calculate totals
-	var grandTotalBase, grandTotalBilled float64
+	var grandTotalBase, grandTotalBilled, grandTotalAdjustments float64
 	totalDowntimeServices := 0
 	totalSLAViolations := 0
 	avgNetworkUptime := 0.0
@@ -117,17 +133,22 @@ func writeMonthlyOverviewPDF(sum *Summary, sla SLASummary, outDir string, month
 		baseCost         float64
 		billedCost       float64
 		avgUptime        float64
+		slaTarget        float64
 		meetsSLA         bool
+		partial          bool
+		adjustments      float64
 	}
 
 	memberData := make([]memberRow, 0, len(memberNames))
-	c := cfg.GetConfig()
+	anyPartial := false
 
 	for _, mem := range memberNames {
-		row := memberRow{name: mem}
+		row := memberRow{name: mem, slaTarget: SLATarget(mem, "")}
 
-		if memberConfig, exists := c.Members[mem]; exists {
-			row.level = memberConfig.Membership.Level
+		row.level = snap.MemberLevel(mem)
+		_, row.partial = snap.MemberPeriod(mem)
+		if row.partial {
+			anyPartial = true
 		}
 
 		if stats, exists := memberStats[mem]; exists {
@@ -142,9 +163,11 @@ func writeMonthlyOverviewPDF(sum *Summary, sla SLASummary, outDir string, month
 		uptimeCount := 0
 		row.meetsSLA = true
 
+		credits := snap.MemberCredits(mem)
+
 		for svcName, baseCost := range sum.Members[mem].ServiceCosts {
 			row.baseCost += baseCost
-			breakdown := getSLABreakdown(sla, mem, svcName)
+			breakdown := sla.Breakdown(mem, svcName)
 			if breakdown.HoursDown > 0 {
 				row.downtimeServices++
 			}
@@ -155,10 +178,13 @@ func writeMonthlyOverviewPDF(sum *Summary, sla SLASummary, outDir string, month
 			totalUptime += breakdown.Uptime
 			uptimeCount++
 
-			billed := baseCost * (breakdown.Uptime / 100.0)
-			row.billedCost += billed
+			row.billedCost += credits.Services[svcName].Billed
 		}
 
+		// Billed includes manual adjustments; credits shown stay SLA-only
+		_, row.adjustments = snap.MemberAdjustments(mem)
+		row.billedCost += row.adjustments
+
 		if uptimeCount > 0 {
 			row.avgUptime = totalUptime / float64(uptimeCount)
 			avgNetworkUptime += row.avgUptime
@@ -170,6 +196,7 @@ func writeMonthlyOverviewPDF(sum *Summary, sla SLASummary, outDir string, month
 		memberData = append(memberData, row)
 		grandTotalBase += row.baseCost
 		grandTotalBilled += row.billedCost
+		grandTotalAdjustments += row.adjustments
 		totalDowntimeServices += row.downtimeServices
 	}
 
@@ -220,7 +247,7 @@ func writeMonthlyOverviewPDF(sum *Summary, sla SLASummary, outDir string, month
 
 	// Card 3: Network Uptime
 	uptimeColor := []int{255, 152, 0}
-	if avgNetworkUptime >= DefaultSLAPercentage {
+	if avgNetworkUptime >= defaultSLATarget() {
 		uptimeColor = []int{46, 125, 50}
 	}
 	drawGradientCard(pdf, startX+2*(cardWidth+spacing), y, cardWidth, cardHeight, uptimeColor[0], uptimeColor[1], uptimeColor[2])
@@ -265,10 +292,14 @@ func writeMonthlyOverviewPDF(sum *Summary, sla SLASummary, outDir string, month
 	pdf.CellFormat(cardWidth-4, 10, fmt.Sprintf("$%s", formatNumber(int(grandTotalBilled))), "", 0, "C", false, 0, "")
 	pdf.SetFont("Helvetica", "", 9)
 	pdf.SetXY(startX+cardWidth+spacing+2, y+28)
-	pdf.CellFormat(cardWidth-4, 5, "After SLA credits", "", 0, "C", false, 0, "")
+	billedNote := "After SLA credits"
+	if grandTotalAdjustments != 0 {
+		billedNote = "After SLA credits and adjustments"
+	}
+	pdf.CellFormat(cardWidth-4, 5, billedNote, "", 0, "C", false, 0, "")
 
 	// SLA Credits Card
-	savings := grandTotalBase - grandTotalBilled
+	savings := grandTotalBase - (grandTotalBilled - grandTotalAdjustments)
 	drawGradientCard(pdf, startX+2*(cardWidth+spacing), y, cardWidth, cardHeight, 46, 125, 50)
 	pdf.SetFont("Helvetica", "", 11)
 	pdf.SetXY(startX+2*(cardWidth+spacing)+2, y+5)
@@ -281,6 +312,19 @@ func writeMonthlyOverviewPDF(sum *Summary, sla SLASummary, outDir string, month
 	pdf.CellFormat(cardWidth-4, 5, fmt.Sprintf("%.1f%% savings", (savings/grandTotalBase)*100), "", 0, "C", false, 0, "")
 	pdf.SetTextColor(0, 0, 0)
 
+	// Totals converted to the configured payout currencies
+	rateY := y + cardHeight + 8
+	for _, rate := range monthRates(month) {
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.SetXY(startX, rateY)
+		pdf.CellFormat(257, 5, fmt.Sprintf("In %s: base %s, billed %s, SLA credits %s  %s", rate.Currency,
+			FormatAmount(rate.Convert(grandTotalBase), rate.Currency),
+			FormatAmount(rate.Convert(grandTotalBilled), rate.Currency),
+			FormatAmount(rate.Convert(savings), rate.Currency),
+			describeRate(rate)), "", 0, "L", false, 0, "")
+		rateY += 6
+	}
+
 	// ===== PAGE 2: SERVICE HEALTH =====
 	pdf.AddPage()
 	y = 40
@@ -324,7 +368,7 @@ func writeMonthlyOverviewPDF(sum *Summary, sla SLASummary, outDir string, month
 	pdf.SetXY(25, y+17)
 	pdf.CellFormat(80, 6, "SLA requirement:", "", 0, "L", false, 0, "")
 	pdf.SetFont("Helvetica", "B", 11)
-	pdf.CellFormat(30, 6, fmt.Sprintf("%.2f%%", DefaultSLAPercentage), "", 0, "L", false, 0, "")
+	pdf.CellFormat(150, 6, describeSLATargets(), "", 0, "L", false, 0, "")
 
 	// Add downtime calendar
 	y += 35 // Reduced from 40
@@ -432,8 +476,12 @@ func writeMonthlyOverviewPDF(sum *Summary, sla SLASummary, outDir string, month
 
 		pdf.SetXY(tableX, y)
 
-		// Member name
-		pdf.CellFormat(colMemberW, rowH, row.name, "1", 0, "L", true, 0, "")
+		// Member name (* = billed for part of the month)
+		name := row.name
+		if row.partial {
+			name += " *"
+		}
+		pdf.CellFormat(colMemberW, rowH, name, "1", 0, "L", true, 0, "")
 
 		// Level
 		pdf.CellFormat(colLevelW, rowH, fmt.Sprintf("%d", row.level), "1", 0, "C", true, 0, "")
@@ -455,7 +503,7 @@ func writeMonthlyOverviewPDF(sum *Summary, sla SLASummary, outDir string, month
 		pdf.SetTextColor(0, 0, 0)
 
 		// Average uptime
-		if row.avgUptime < DefaultSLAPercentage {
+		if row.avgUptime < row.slaTarget {
 			pdf.SetTextColor(255, 0, 0)
 		}
 		pdf.CellFormat(colUptimeW, rowH, fmt.Sprintf("%.2f%%", row.avgUptime), "1", 0, "R", true, 0, "")
@@ -490,6 +538,20 @@ func writeMonthlyOverviewPDF(sum *Summary, sla SLASummary, outDir string, month
 	pdf.CellFormat(colBilledW, rowH, fmt.Sprintf("$%.2f", grandTotalBilled), "1", 0, "R", true, 0, "")
 	pdf.CellFormat(colStatusW, rowH, "", "1", 1, "C", true, 0, "")
 
+	noteY := y + rowH + 2
+	if anyPartial {
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetXY(tableX, noteY)
+		pdf.CellFormat(200, 4, "* Joined or left during the month: costs and SLA cover the active period only.", "", 1, "L", false, 0, "")
+		noteY += 5
+	}
+	if grandTotalAdjustments != 0 {
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetXY(tableX, noteY)
+		pdf.CellFormat(200, 4, fmt.Sprintf("Billed includes manual adjustments of %s (itemised in the member reports).",
+			formatSignedAmount(grandTotalAdjustments)), "", 1, "L", false, 0, "")
+	}
+
 	// ===== PAGE 4: GEOGRAPHIC DISTRIBUTION =====
 	pdf.AddPage()
 	pdf.SetFont("Helvetica", "B", 16)
@@ -522,12 +584,7 @@ func writeMonthlyOverviewPDF(sum *Summary, sla SLASummary, outDir string, month
 	// Draw unified service table
 	drawUnifiedServiceTable(pdf, serviceStats, 55, svcTableX, svcTableWidth)
 
-	if err := pdf.OutputFileAndClose(filename); err != nil {
-		return err
-	}
-
-	log.Log(log.Info, "[billing] Monthly overview PDF written → %s", filename)
-	return nil
+	return pdf
 }
 
 // drawUnifiedCountryTable draws a single table with all 15 countries
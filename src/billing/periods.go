@@ -0,0 +1,163 @@
+package billing
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	cfg "github.com/ibp-network/ibp-geodns-libs/config"
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+// ActivePeriod is the part of a billing month a member was active for: from
+// Membership.Joined (or the month start) until the member left (or the month
+// end). Costs are prorated to it and SLA hours only count inside it.
+type ActivePeriod struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Hours returns the length of the period.
+func (p ActivePeriod) Hours() float64 {
+	if !p.End.After(p.Start) {
+		return 0
+	}
+	return p.End.Sub(p.Start).Hours()
+}
+
+// Partial reports whether p covers less than the month containing it.
+func (p ActivePeriod) Partial() bool {
+	w := monthWindow(p.Start)
+	return p.Start.After(w.start) || p.End.Before(w.end)
+}
+
+// Describe renders p for the PDFs, e.g. "Mar 20 - Mar 31 (12 of 31 days)".
+func (p ActivePeriod) Describe() string {
+	w := monthWindow(p.Start)
+	days := int(math.Ceil(p.End.Sub(p.Start).Hours() / 24))
+	last := p.End.Add(-time.Second)
+	return fmt.Sprintf("%s - %s (%d of %d days)", p.Start.Format("Jan 2"), last.Format("Jan 2"),
+		days, int(w.hours()/24))
+}
+
+func (w slaWindow) period() ActivePeriod {
+	return ActivePeriod{Start: w.start, End: w.end}
+}
+
+// joinedTime converts Membership.Joined (unix seconds, 0 = unknown).
+func joinedTime(joined int) time.Time {
+	if joined <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(joined), 0).UTC()
+}
+
+// configPeriods derives each member's active period in w from the current
+// config's Membership.Joined. Members that joined after w are absent.
+func configPeriods(w slaWindow, members map[string]MemberCost) map[string]ActivePeriod {
+	c := cfg.GetConfig()
+	periods := make(map[string]ActivePeriod, len(members))
+
+	for memberID := range members {
+		p := w.period()
+		if m, ok := c.Members[memberID]; ok {
+			if joined := joinedTime(m.Membership.Joined); joined.After(p.Start) {
+				p.Start = joined
+			}
+		}
+		if p.End.After(p.Start) {
+			periods[memberID] = p
+		}
+	}
+	return periods
+}
+
+// periods derives each member's active period in w from the recorded
+// versions: from the first to the last moment the member was in the config
+// and past its join date.
+func (h *billingHistory) periods(w slaWindow) map[string]ActivePeriod {
+	periods := make(map[string]ActivePeriod)
+	bounds := h.boundaries(w)
+
+	for memberID, mvs := range h.members {
+		var p ActivePeriod
+		for i := 0; i+1 < len(bounds); i++ {
+			if !memberActiveAt(mvs, bounds[i]) {
+				continue
+			}
+			if p.Start.IsZero() {
+				p.Start = bounds[i]
+			}
+			p.End = bounds[i+1]
+		}
+		if !p.Start.IsZero() {
+			periods[memberID] = p
+		}
+	}
+	return periods
+}
+
+// memberActiveAt reports whether a member was in the config and had joined at t.
+func memberActiveAt(versions []version[memberState], t time.Time) bool {
+	mem, ok := valueAt(versions, t)
+	return ok && !t.Before(joinedTime(mem.Joined))
+}
+
+// configSummary prices w from the current billing summary: every member is
+// charged its share of the month for the part of w after its join date.
+func configSummary(live Summary, w slaWindow) (Summary, map[string]ActivePeriod) {
+	periods := configPeriods(w, live.Members)
+	return prorateSummary(live, periods, monthWindow(w.start)), periods
+}
+
+// prorateSummary scales full-month costs to each member's active period;
+// members without a period in w are dropped.
+func prorateSummary(sum Summary, periods map[string]ActivePeriod, w slaWindow) Summary {
+	out := Summary{
+		Members:  make(map[string]MemberCost, len(sum.Members)),
+		Services: make(map[string]ServiceCost, len(sum.Services)),
+		Refresh:  sum.Refresh,
+	}
+
+	for memberID, mc := range sum.Members {
+		p, ok := periods[memberID]
+		if !ok {
+			log.Log(log.Debug, "[billing] %s not active in %s — not billed", memberID, w.start.Format("2006-01"))
+			continue
+		}
+		share := p.Hours() / w.hours()
+
+		scaled := MemberCost{MemberName: mc.MemberName, ServiceCosts: make(map[string]float64, len(mc.ServiceCosts))}
+		for svcName, cost := range mc.ServiceCosts {
+			cost *= share
+			scaled.ServiceCosts[svcName] = cost
+			scaled.Total += cost
+
+			sc := out.Services[svcName]
+			if sc.ServiceName == "" {
+				sc.ServiceName = svcName
+				sc.MemberCosts = map[string]float64{}
+			}
+			sc.MemberCosts[memberID] += cost
+			sc.Total += cost
+			out.Services[svcName] = sc
+		}
+		out.Members[memberID] = scaled
+	}
+	return out
+}
+
+// memberPeriods returns the active periods for month, from the recorded
+// config history when available and from the current config otherwise.
+func memberPeriods(month time.Time, sum *Summary) map[string]ActivePeriod {
+	w := monthWindow(month)
+	if historyEnabled {
+		h, err := loadHistory()
+		if err != nil {
+			log.Log(log.Error, "[billing] failed to load billing config history: %v", err)
+		} else if !h.empty() {
+			return h.periods(w)
+		}
+	}
+	return configPeriods(w, sum.Members)
+}
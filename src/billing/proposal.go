@@ -0,0 +1,229 @@
+package billing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+// ProposalFileName is the file name of a month's treasury proposal.
+func ProposalFileName(month time.Time) string {
+	return fmt.Sprintf("%s-Treasury_Proposal.md", month.Format("2006_01"))
+}
+
+// proposal holds everything the treasury proposal is rendered from.
+type proposal struct {
+	snap      *Snapshot
+	invoices  map[string]string // member → invoice number
+	rates     []ExchangeRate
+	generated time.Time
+}
+
+// writeProposal stores the OpenGov treasury proposal of snap with the month's
+// reports and returns its key.
+func writeProposal(snap *Snapshot) (string, error) {
+	p := proposal{
+		snap:      snap,
+		invoices:  make(map[string]string),
+		rates:     monthRates(snap.Month),
+		generated: time.Now().UTC(),
+	}
+	if invoicesEnabled {
+		invoices, err := ListInvoices("", snap.Month, "")
+		if err != nil {
+			log.Log(log.Warn, "[billing] treasury proposal for %s without invoice numbers: %v", snap.Month.Format("January 2006"), err)
+		}
+		for _, inv := range invoices {
+			if inv.Status != InvoiceVoid {
+				p.invoices[inv.Member] = inv.Number
+			}
+		}
+	}
+
+	key := monthKey(snap.Month, ProposalFileName(snap.Month))
+	err := putArtifact(key, func(w io.Writer) error {
+		_, err := w.Write(p.render())
+		return err
+	})
+	return key, err
+}
+
+// render returns the proposal as Markdown: totals, a payout table per member,
+// the SLA violations with their credits and the month's adjustments.
+func (p proposal) render() []byte {
+	snap := p.snap
+	month := snap.Month.Format("January 2006")
+
+	members := make([]string, 0, len(snap.Summary.Members))
+	for m := range snap.Summary.Members {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+
+	type payout struct {
+		member                       string
+		services                     int
+		base, credits, adjust, total float64
+	}
+	var (
+		payouts []payout
+		total   payout
+	)
+	for _, m := range members {
+		credits := snap.MemberCredits(m)
+		_, adjustments := snap.MemberAdjustments(m)
+		row := payout{
+			member:   m,
+			services: len(snap.Summary.Members[m].ServiceCosts),
+			base:     credits.TotalBase,
+			credits:  credits.TotalCredit,
+			adjust:   adjustments,
+			total:    credits.TotalBilled + adjustments,
+		}
+		payouts = append(payouts, row)
+		total.services += row.services
+		total.base += row.base
+		total.credits += row.credits
+		total.adjust += row.adjust
+		total.total += row.total
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# IBP Infrastructure Services — %s\n\n", month)
+	fmt.Fprintf(&b, "Treasury proposal to pay the members of the Infrastructure Builders' Programme (IBP) "+
+		"for the services they provided in %s. Amounts are computed by IBPCollator from "+
+		"the billing snapshot of %s and match the monthly overview and member statements.\n\n",
+		month, snap.Created.Format("2006-01-02"))
+
+	b.WriteString("## Summary\n\n")
+	b.WriteString("| | Amount |\n|---|---:|\n")
+	fmt.Fprintf(&b, "| Members | %d |\n", len(payouts))
+	fmt.Fprintf(&b, "| Services provided | %d |\n", total.services)
+	fmt.Fprintf(&b, "| Base cost | %s |\n", FormatAmount(total.base, BaseCurrency))
+	fmt.Fprintf(&b, "| SLA credits | %s |\n", creditAmount(total.credits))
+	if total.adjust != 0 {
+		fmt.Fprintf(&b, "| Adjustments | %s |\n", signedAmount(total.adjust))
+	}
+	fmt.Fprintf(&b, "| **Total requested** | **%s** |\n", FormatAmount(total.total, BaseCurrency))
+	for _, r := range p.rates {
+		fmt.Fprintf(&b, "| Total requested in %s | %s |\n", r.Currency, FormatAmount(r.Convert(total.total), r.Currency))
+	}
+	b.WriteString("\n")
+	for _, r := range p.rates {
+		fmt.Fprintf(&b, "%s amounts use 1 %s = $%.4f, the rate of %s.\n", r.Currency, r.Currency, r.UsdPerUnit, r.Date.Format("2006-01-02"))
+	}
+	if len(p.rates) > 0 {
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Payouts\n\n")
+	header := "| Member | Level | Services | Base cost | SLA credits | Adjustments | Payout |"
+	divider := "|---|---:|---:|---:|---:|---:|---:|"
+	if len(p.invoices) > 0 {
+		header += " Invoice |"
+		divider += "---|"
+	}
+	b.WriteString(header + "\n" + divider + "\n")
+	for _, row := range payouts {
+		name := mdEscape(row.member)
+		if period, partial := snap.MemberPeriod(row.member); partial {
+			name += " *(" + period.Describe() + ")*"
+		}
+		fmt.Fprintf(&b, "| %s | %d | %d | %s | %s | %s | %s |", name, snap.MemberLevel(row.member), row.services,
+			FormatAmount(row.base, BaseCurrency), creditAmount(row.credits), signedAmount(row.adjust),
+			FormatAmount(row.total, BaseCurrency))
+		if len(p.invoices) > 0 {
+			fmt.Fprintf(&b, " %s |", p.invoices[row.member])
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "| **Total** | | %d | %s | %s | %s | **%s** |", total.services,
+		FormatAmount(total.base, BaseCurrency), creditAmount(total.credits), signedAmount(total.adjust),
+		FormatAmount(total.total, BaseCurrency))
+	if len(p.invoices) > 0 {
+		b.WriteString(" |")
+	}
+	b.WriteString("\n\n")
+
+	b.WriteString("## SLA Violations\n\n")
+	violations := 0
+	for _, m := range members {
+		credits := snap.MemberCredits(m)
+		for _, svc := range sortedKeys(snap.Summary.Members[m].ServiceCosts) {
+			bd, ok := snap.SLA[m][svc]
+			if !ok || bd.MeetsSLA {
+				continue
+			}
+			if violations == 0 {
+				b.WriteString("| Member | Service | Uptime | Target | Downtime (h) | Credit |\n")
+				b.WriteString("|---|---|---:|---:|---:|---:|\n")
+			}
+			violations++
+			sc := credits.Services[svc]
+			credit := creditAmount(sc.Credit)
+			if sc.Capped {
+				credit += " (capped)"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %.3f%% | %.2f%% | %.2f | %s |\n",
+				mdEscape(m), mdEscape(svc), bd.Uptime, bd.SLAThreshold, bd.HoursDown, credit)
+		}
+	}
+	if violations == 0 {
+		fmt.Fprintf(&b, "No SLA violations were recorded in %s.\n", month)
+	} else {
+		fmt.Fprintf(&b, "\n%d service(s) missed their SLA target; %s was credited in total.\n",
+			violations, FormatAmount(total.credits, BaseCurrency))
+	}
+	b.WriteString("\n")
+
+	var adjustments []Adjustment
+	for _, m := range members {
+		list, _ := snap.MemberAdjustments(m)
+		adjustments = append(adjustments, list...)
+	}
+	if len(adjustments) > 0 {
+		b.WriteString("## Adjustments\n\n")
+		b.WriteString("| Member | Service | Amount | Reason |\n|---|---|---:|---|\n")
+		for _, a := range adjustments {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", mdEscape(a.Member), mdEscape(a.Service),
+				signedAmount(a.Amount), mdEscape(a.Reason))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Method\n\n")
+	b.WriteString("Each member is paid for the services assigned to it at the IaaS price of its region " +
+		"(cores, memory, disk and bandwidth per node), prorated to the part of the month it was active. " +
+		"Uptime is measured by the IBP monitors; services below their SLA target are credited according " +
+		"to the member's credit policy, and manual adjustments are added on top.\n\n")
+	fmt.Fprintf(&b, "---\n*Generated by IBPCollator %s on %s UTC.*\n", Version(), p.generated.Format("2006-01-02 15:04"))
+
+	return b.Bytes()
+}
+
+// creditAmount renders a credit as a deduction, e.g. "-$12.50" or "$0.00".
+func creditAmount(credit float64) string {
+	if credit <= 0 {
+		return FormatAmount(0, BaseCurrency)
+	}
+	return "-" + FormatAmount(credit, BaseCurrency)
+}
+
+// signedAmount renders an adjustment with its sign, or a dash when there is none.
+func signedAmount(amount float64) string {
+	if amount == 0 {
+		return "—"
+	}
+	return formatSignedAmount(amount)
+}
+
+// mdEscape keeps text from breaking out of a Markdown table cell.
+func mdEscape(s string) string {
+	s = strings.NewReplacer("|", `\|`, "\r", " ", "\n", " ").Replace(s)
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,48 @@
+package billing
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProposalRender(t *testing.T) {
+	snap := testExport().snap
+	snap.Created = at("2025-05-01 00:05")
+	snap.Adjustments = map[string][]Adjustment{
+		"beta": {{Member: "beta", Amount: -20, Reason: "Relay | migration"}},
+	}
+
+	p := proposal{
+		snap:      snap,
+		invoices:  map[string]string{"alpha": "IBP-000007", "beta": "IBP-000008"},
+		rates:     []ExchangeRate{{Currency: "DOT", UsdPerUnit: 4, Date: at("2025-04-30 00:00")}},
+		generated: at("2025-05-01 00:06"),
+	}
+	doc := string(p.render())
+
+	for _, want := range []string{
+		"# IBP Infrastructure Services — April 2025",
+		"| **Total requested** | **$229.00** |",
+		"| Total requested in DOT | 57.25 DOT |",
+		"| alpha | 5 | 2 | $150.00 | -$1.00 | — | $149.00 | IBP-000007 |",
+		"| beta | 6 | 1 | $100.00 | $0.00 | -$20.00 | $80.00 | IBP-000008 |",
+		"| **Total** | | 3 | $250.00 | -$1.00 | -$20.00 | **$229.00** | |",
+		"| alpha | RPC | 99.000% | 99.90% | 7.20 | -$1.00 |",
+		"1 service(s) missed their SLA target",
+		`| beta |  | -$20.00 | Relay \| migration |`,
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("proposal lacks %q:\n%s", want, doc)
+		}
+	}
+
+	snap.SLA = SLASummary{}
+	p.invoices = map[string]string{}
+	doc = string(p.render())
+	if !strings.Contains(doc, "No SLA violations were recorded in April 2025.") {
+		t.Errorf("proposal without violations:\n%s", doc)
+	}
+	if strings.Contains(doc, "Invoice") {
+		t.Errorf("proposal without invoices has an invoice column:\n%s", doc)
+	}
+}
@@ -0,0 +1,230 @@
+package billing
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	data2 "github.com/ibp-network/ibp-geodns-libs/data2"
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+// Billing run states stored in billing_runs.status.
+const (
+	RunRunning     = "running"
+	RunSucceeded   = "succeeded"
+	RunFailed      = "failed"
+	RunInterrupted = "interrupted" // process stopped or crashed mid-run
+)
+
+// BillingRun is one monthly billing PDF generation attempt.
+type BillingRun struct {
+	ID       int64
+	Month    time.Time
+	Started  time.Time
+	Finished *time.Time
+	Status   string
+	Error    string
+	Files    []string // artifact keys, e.g. 2024-09/2024_09-Monthly_Overview.pdf
+}
+
+const createBillingRunsTable = `
+	CREATE TABLE IF NOT EXISTS billing_runs (
+		id            INT UNSIGNED NOT NULL AUTO_INCREMENT,
+		billing_month DATE         NOT NULL,
+		started_at    DATETIME     NOT NULL,
+		finished_at   DATETIME     DEFAULT NULL,
+		status        VARCHAR(16)  NOT NULL,
+		error         TEXT,
+		files         LONGTEXT,
+		PRIMARY KEY (id),
+		KEY idx_billing_runs_month (billing_month, status)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci`
+
+// runsEnabled is false when the billing_runs table could not be prepared; the
+// generator then falls back to in-memory state only.
+var runsEnabled bool
+
+// initRunState prepares billing_runs, marks runs left "running" by a previous
+// process as interrupted and restores the last successfully generated month.
+func initRunState() {
+	if data2.DB == nil {
+		log.Log(log.Warn, "[billing] database unavailable — billing run history disabled")
+		return
+	}
+
+	if _, err := data2.DB.Exec(createBillingRunsTable); err != nil {
+		log.Log(log.Error, "[billing] failed to prepare billing_runs table: %v", err)
+		return
+	}
+	runsEnabled = true
+
+	res, err := data2.DB.Exec(`
+		UPDATE billing_runs
+		SET status = ?, finished_at = UTC_TIMESTAMP(), error = COALESCE(error, 'collator stopped before the run finished')
+		WHERE status = ?`, RunInterrupted, RunRunning)
+	if err != nil {
+		log.Log(log.Error, "[billing] failed to close stale billing runs: %v", err)
+	} else if n, _ := res.RowsAffected(); n > 0 {
+		log.Log(log.Warn, "[billing] marked %d unfinished billing run(s) as interrupted", n)
+	}
+
+	if last := syncLastGeneratedMonth(); !last.IsZero() {
+		log.Log(log.Info, "[billing] last successful billing run: %s", last.Format("January 2006"))
+	}
+
+	if runs, err := ListRuns(time.Time{}, 1); err == nil && len(runs) > 0 && runs[0].Finished != nil {
+		lastGeneration.Lock()
+		lastGeneration.status = GenerationStatus{
+			Month:    runs[0].Month,
+			Finished: *runs[0].Finished,
+			Success:  runs[0].Status == RunSucceeded,
+		}
+		lastGeneration.ran = true
+		lastGeneration.Unlock()
+	}
+}
+
+// syncLastGeneratedMonth reloads the last successfully billed month from
+// billing_runs and returns it. Another collator may have billed months while
+// this one was a follower, so the in-memory value alone is not trusted before
+// deciding what to generate. Without run history it is returned unchanged.
+func syncLastGeneratedMonth() time.Time {
+	var month sql.NullTime
+	if runsEnabled {
+		if err := data2.DB.QueryRow(`SELECT MAX(billing_month) FROM billing_runs WHERE status = ?`, RunSucceeded).Scan(&month); err != nil {
+			log.Log(log.Error, "[billing] failed to load last billing run: %v", err)
+		}
+	}
+
+	billingGenMutex.Lock()
+	defer billingGenMutex.Unlock()
+	if month.Valid && lastGeneratedBillingMonth.Before(monthStart(month.Time)) {
+		lastGeneratedBillingMonth = monthStart(month.Time)
+	}
+	return lastGeneratedBillingMonth
+}
+
+// startRun records the beginning of a billing run and returns its id (0 when
+// run history is disabled).
+func startRun(month time.Time) int64 {
+	if !runsEnabled {
+		return 0
+	}
+
+	res, err := data2.DB.Exec(`
+		INSERT INTO billing_runs (billing_month, started_at, status)
+		VALUES (?, UTC_TIMESTAMP(), ?)`, month.Format("2006-01-02"), RunRunning)
+	if err != nil {
+		log.Log(log.Error, "[billing] failed to record billing run start: %v", err)
+		return 0
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		log.Log(log.Error, "[billing] failed to read billing run id: %v", err)
+		return 0
+	}
+	return id
+}
+
+// finishRun stores the outcome of a billing run.
+func finishRun(id int64, status, errText string, files []string) {
+	if !runsEnabled || id == 0 {
+		return
+	}
+
+	if files == nil {
+		files = []string{}
+	}
+	jFiles, _ := json.Marshal(files)
+
+	var errVal interface{}
+	if errText != "" {
+		errVal = errText
+	}
+
+	if _, err := data2.DB.Exec(`
+		UPDATE billing_runs
+		SET finished_at = UTC_TIMESTAMP(), status = ?, error = ?, files = ?
+		WHERE id = ?`, status, errVal, string(jFiles), id); err != nil {
+		log.Log(log.Error, "[billing] failed to record billing run result: %v", err)
+	}
+}
+
+// ListRuns returns billing runs newest first, optionally restricted to one
+// month (zero month = all).
+func ListRuns(month time.Time, limit int) ([]BillingRun, error) {
+	if !runsEnabled {
+		return nil, fmt.Errorf("billing run history is not available")
+	}
+
+	query := `
+		SELECT id, billing_month, started_at, finished_at, status,
+		       COALESCE(error, ''), COALESCE(files, '')
+		FROM billing_runs`
+	args := []interface{}{}
+
+	if !month.IsZero() {
+		query += " WHERE billing_month = ?"
+		args = append(args, monthStart(month).Format("2006-01-02"))
+	}
+
+	query += " ORDER BY started_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := data2.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []BillingRun{}
+	for rows.Next() {
+		var (
+			run      BillingRun
+			finished sql.NullTime
+			files    string
+		)
+		if err := rows.Scan(&run.ID, &run.Month, &run.Started, &finished, &run.Status, &run.Error, &files); err != nil {
+			return nil, err
+		}
+		if finished.Valid {
+			t := finished.Time
+			run.Finished = &t
+		}
+		run.Files = []string{}
+		if files != "" {
+			if err := json.Unmarshal([]byte(files), &run.Files); err != nil {
+				log.Log(log.Warn, "[billing] billing run %d has unreadable file list: %v", run.ID, err)
+			}
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// pendingBillingMonths lists the months that still need a billing run, oldest
+// first: every month after the last successful run up to the previous month.
+// Without any history only the previous month is due.
+func pendingBillingMonths(now time.Time) []time.Time {
+	previous := monthStart(now.AddDate(0, -1, 0))
+	last := syncLastGeneratedMonth()
+
+	if last.IsZero() {
+		return []time.Time{previous}
+	}
+
+	var months []time.Time
+	for m := last.AddDate(0, 1, 0); !m.After(previous); m = m.AddDate(0, 1, 0) {
+		months = append(months, m)
+	}
+	return months
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
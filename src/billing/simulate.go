@@ -0,0 +1,153 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	cfg "github.com/ibp-network/ibp-geodns-libs/config"
+)
+
+// ErrUnknownScenarioKey is returned for scenario regions or services that are
+// not part of the config.
+var ErrUnknownScenarioKey = errors.New("unknown region or service")
+
+// Scenario holds alternative pricing and resources to price the current
+// member assignments with. Keys match config names case-insensitively; regions
+// and services left out keep their configured values.
+type Scenario struct {
+	Pricing   map[string]cfg.IaasPricing // region → pricing
+	Resources map[string]cfg.Resources   // service → resources per instance
+}
+
+// CostDelta compares a live monthly cost with its simulated counterpart.
+type CostDelta struct {
+	Current   float64
+	Simulated float64
+}
+
+// Change is the simulated cost minus the live one.
+func (d CostDelta) Change() float64 {
+	return d.Simulated - d.Current
+}
+
+// MemberDelta is the impact of a scenario on one member's bill.
+type MemberDelta struct {
+	CostDelta
+	Services map[string]CostDelta // serviceName → costs
+}
+
+// Simulation is the impact of a scenario on the live billing summary.
+type Simulation struct {
+	Members  map[string]MemberDelta
+	Services map[string]CostDelta
+	Total    CostDelta
+	Baseline time.Time // refresh time of the live summary compared against
+}
+
+// Simulate prices the current config with s applied, the same way the hourly
+// refresh does, and compares the result with the live summary. The live
+// summary is left untouched.
+func Simulate(s Scenario) (Simulation, error) {
+	c, err := applyScenario(cfg.GetConfig(), s)
+	if err != nil {
+		return Simulation{}, err
+	}
+
+	members, services := computeCosts(c, time.Now().UTC())
+	simulated := Summary{Members: members, Services: services}
+	return compareSummaries(GetSummary(), simulated), nil
+}
+
+// applyScenario returns c with s's pricing and resources, without modifying
+// the maps shared with the config package.
+func applyScenario(c cfg.Config, s Scenario) (cfg.Config, error) {
+	regions := make(map[string]string, len(c.Pricing))
+	pricing := make(map[string]cfg.IaasPricing, len(c.Pricing))
+	for r, p := range c.Pricing {
+		regions[strings.ToLower(strings.TrimSpace(r))] = r
+		pricing[r] = p
+	}
+	for r, p := range s.Pricing {
+		name, ok := regions[strings.ToLower(strings.TrimSpace(r))]
+		if !ok {
+			return c, fmt.Errorf("%w: region %q", ErrUnknownScenarioKey, r)
+		}
+		pricing[name] = p
+	}
+
+	names := make(map[string]string, len(c.Services))
+	services := make(map[string]cfg.Service, len(c.Services))
+	for n, svc := range c.Services {
+		names[strings.ToLower(strings.TrimSpace(n))] = n
+		services[n] = svc
+	}
+	for n, res := range s.Resources {
+		name, ok := names[strings.ToLower(strings.TrimSpace(n))]
+		if !ok {
+			return c, fmt.Errorf("%w: service %q", ErrUnknownScenarioKey, n)
+		}
+		svc := services[name]
+		svc.Resources = res
+		services[name] = svc
+	}
+
+	c.Pricing = pricing
+	c.Services = services
+	return c, nil
+}
+
+// compareSummaries lines up every member and service of live and simulated;
+// entries missing on one side count as zero there.
+func compareSummaries(live, simulated Summary) Simulation {
+	sim := Simulation{
+		Members:  make(map[string]MemberDelta),
+		Services: make(map[string]CostDelta),
+		Baseline: live.Refresh,
+	}
+
+	member := func(name string) MemberDelta {
+		md, ok := sim.Members[name]
+		if !ok {
+			md.Services = make(map[string]CostDelta)
+		}
+		return md
+	}
+
+	for name, mc := range live.Members {
+		md := member(name)
+		md.Current = mc.Total
+		for svc, cost := range mc.ServiceCosts {
+			d := md.Services[svc]
+			d.Current = cost
+			md.Services[svc] = d
+		}
+		sim.Members[name] = md
+		sim.Total.Current += mc.Total
+	}
+	for name, mc := range simulated.Members {
+		md := member(name)
+		md.Simulated = mc.Total
+		for svc, cost := range mc.ServiceCosts {
+			d := md.Services[svc]
+			d.Simulated = cost
+			md.Services[svc] = d
+		}
+		sim.Members[name] = md
+		sim.Total.Simulated += mc.Total
+	}
+
+	for name, sc := range live.Services {
+		d := sim.Services[name]
+		d.Current = sc.Total
+		sim.Services[name] = d
+	}
+	for name, sc := range simulated.Services {
+		d := sim.Services[name]
+		d.Simulated = sc.Total
+		sim.Services[name] = d
+	}
+
+	return sim
+}
@@ -0,0 +1,95 @@
+package billing
+
+import (
+	"errors"
+	"testing"
+
+	cfg "github.com/ibp-network/ibp-geodns-libs/config"
+)
+
+func TestApplyScenario(t *testing.T) {
+	c := cfg.Config{
+		Pricing: map[string]cfg.IaasPricing{"EU": {Cores: 1}},
+		Services: map[string]cfg.Service{
+			"Polkadot-RPC": {Resources: cfg.Resources{Nodes: 1, Cores: 8}},
+		},
+	}
+
+	got, err := applyScenario(c, Scenario{
+		Pricing:   map[string]cfg.IaasPricing{"eu": {Cores: 2}},
+		Resources: map[string]cfg.Resources{"polkadot-rpc": {Nodes: 2, Cores: 8}},
+	})
+	if err != nil {
+		t.Fatalf("applyScenario: %v", err)
+	}
+	if got.Pricing["EU"].Cores != 2 {
+		t.Errorf("EU cores price = %v, want 2", got.Pricing["EU"].Cores)
+	}
+	if got.Services["Polkadot-RPC"].Resources.Nodes != 2 {
+		t.Errorf("Polkadot-RPC nodes = %v, want 2", got.Services["Polkadot-RPC"].Resources.Nodes)
+	}
+	// the config's own maps must stay as they were
+	if c.Pricing["EU"].Cores != 1 || c.Services["Polkadot-RPC"].Resources.Nodes != 1 {
+		t.Errorf("applyScenario modified the config: %+v", c)
+	}
+
+	for _, s := range []Scenario{
+		{Pricing: map[string]cfg.IaasPricing{"us": {}}},
+		{Resources: map[string]cfg.Resources{"Kusama-RPC": {}}},
+	} {
+		if _, err := applyScenario(c, s); !errors.Is(err, ErrUnknownScenarioKey) {
+			t.Errorf("applyScenario(%+v) error = %v, want ErrUnknownScenarioKey", s, err)
+		}
+	}
+}
+
+func TestCompareSummaries(t *testing.T) {
+	live := Summary{
+		Members: map[string]MemberCost{
+			"alpha": {ServiceCosts: map[string]float64{"RPC": 100, "ETH": 50}, Total: 150},
+			"beta":  {ServiceCosts: map[string]float64{"RPC": 100}, Total: 100},
+		},
+		Services: map[string]ServiceCost{
+			"RPC": {Total: 200},
+			"ETH": {Total: 50},
+		},
+	}
+	// ETH dropped to zero resources, so alpha's ETH cost disappears
+	simulated := Summary{
+		Members: map[string]MemberCost{
+			"alpha": {ServiceCosts: map[string]float64{"RPC": 120}, Total: 120},
+			"beta":  {ServiceCosts: map[string]float64{"RPC": 120}, Total: 120},
+		},
+		Services: map[string]ServiceCost{
+			"RPC": {Total: 240},
+		},
+	}
+
+	sim := compareSummaries(live, simulated)
+
+	tests := []struct {
+		name string
+		got  CostDelta
+		want CostDelta
+	}{
+		{"total", sim.Total, CostDelta{250, 240}},
+		{"alpha", sim.Members["alpha"].CostDelta, CostDelta{150, 120}},
+		{"alpha RPC", sim.Members["alpha"].Services["RPC"], CostDelta{100, 120}},
+		{"alpha ETH only in live", sim.Members["alpha"].Services["ETH"], CostDelta{50, 0}},
+		{"beta", sim.Members["beta"].CostDelta, CostDelta{100, 120}},
+		{"RPC service", sim.Services["RPC"], CostDelta{200, 240}},
+		{"ETH service", sim.Services["ETH"], CostDelta{50, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %+v, want %+v", tt.got, tt.want)
+			}
+		})
+	}
+
+	if got := sim.Total.Change(); got != -10 {
+		t.Errorf("total change = %v, want -10", got)
+	}
+}
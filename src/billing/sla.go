@@ -1,10 +1,14 @@
 package billing
 
 import (
+	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
+	common "github.com/ibp-network/ibp-geodns-collator/src/common"
+
 	cfg "github.com/ibp-network/ibp-geodns-libs/config"
 	data2 "github.com/ibp-network/ibp-geodns-libs/data2"
 	log "github.com/ibp-network/ibp-geodns-libs/logging"
@@ -12,29 +16,204 @@ import (
 
 // SLABreakdown captures the availability of a single <member,service> pair.
 type SLABreakdown struct {
-	HoursTotal   float64
-	HoursDown    float64
-	HoursUp      float64
-	Uptime       float64 // 0-100 percentage
-	SLAThreshold float64 // SLA threshold in percentage (e.g., 99.99)
-	SLAHours     float64 // SLA threshold in hours
-	MeetsSLA     bool
+	HoursTotal    float64
+	HoursDown     float64
+	HoursUp       float64
+	Uptime        float64 // 0-100 percentage
+	SLAThreshold  float64 // SLA threshold in percentage (e.g., 99.99)
+	SLAHours      float64 // SLA threshold in hours
+	MeetsSLA      bool
+	HoursExcluded float64 // downtime inside maintenance windows, not part of HoursDown
 }
 
 // SLASummary maps member → service → breakdown.
 type SLASummary map[string]map[string]SLABreakdown
 
-// Default SLA threshold
+// averageMonthHours is the month length assumed for services without SLA data.
+const averageMonthHours = 730
+
+// Breakdown returns the SLA of a member service. Services the engine did not
+// measure (inactive, or the SLA could not be calculated) report full uptime
+// over an average month.
+func (s SLASummary) Breakdown(member, service string) SLABreakdown {
+	if bd, ok := s[member][service]; ok {
+		return bd
+	}
+	return newSLABreakdown(averageMonthHours, 0, 0, SLATarget(member, service))
+}
+
+// DefaultSLAPercentage is the SLA target used when Sla.DefaultTarget is unset.
 const DefaultSLAPercentage = 99.99
 
+// ─────────────────────────────────────────────────────────────────────────────
+//  SLA engine
+//
+//  This is the only SLA calculator: the API, the PDFs, the billing summary
+//  and the metrics endpoint all call CalculateSLAAdjustments. Its interval
+//  semantics are:
+//
+//   - A month is the half-open window [1st 00:00 UTC, 1st of next month
+//     00:00 UTC), narrowed to the member's active period when it joined or
+//     left during the month. HoursTotal is the length of that window, also
+//     for the month in progress.
+//   - Only outage rows (status = 0) of member_events count. Each event is
+//     clipped to the window; events entirely outside it are ignored.
+//   - An event without end_time is still open and ends at min(now, window
+//     end). Time after now is never counted as downtime.
+//   - Site checks (check_type "site" or "1") take down every service of the
+//     member. Domain and endpoint checks ("domain"/"2", "endpoint"/"3") take
+//     down the services whose RPC URLs resolve to the event's domain_name.
+//   - Overlapping and touching intervals are merged before summing, so
+//     simultaneous outages on several checks are counted once.
+//   - Maintenance windows covering the service are merged the same way and
+//     subtracted from the merged downtime afterwards. The overlap is reported
+//     as HoursExcluded and does not count against the SLA.
+// ─────────────────────────────────────────────────────────────────────────────
+
 // downtimePeriod represents a period of downtime
 type downtimePeriod struct {
 	start time.Time
 	end   time.Time
 }
 
+// OutageEvent is one member_events outage row as seen by the SLA engine.
+type OutageEvent struct {
+	ID        int64
+	CheckType string // raw check_type value, textual or numeric
+	CheckName string
+	Domain    string
+	Endpoint  string
+	Error     string
+	IPv6      bool
+	Start     time.Time
+	End       *time.Time // nil while the outage is still open
+}
+
+// affectsService reports whether e takes down a service whose RPC URLs
+// resolve to domains, and returns e's normalized check type.
+func (e OutageEvent) affectsService(domains map[string]bool) (string, bool) {
+	checkType := common.NormalizeCheckType(e.CheckType)
+	switch checkType {
+	case "site":
+		return checkType, true
+	case "domain", "endpoint":
+		return checkType, domains[strings.ToLower(strings.TrimSpace(e.Domain))]
+	}
+	return checkType, false
+}
+
+// slaWindow is the half-open accounting interval [start, end).
+type slaWindow struct {
+	start time.Time
+	end   time.Time
+}
+
+// monthWindow returns the accounting window of the month containing month.
+func monthWindow(month time.Time) slaWindow {
+	start := monthStart(month)
+	return slaWindow{start: start, end: start.AddDate(0, 1, 0)}
+}
+
+func (w slaWindow) hours() float64 {
+	return w.end.Sub(w.start).Hours()
+}
+
+// clip returns the part of e that falls inside w, with open events ending at
+// now; ok is false when nothing remains.
+func (w slaWindow) clip(e OutageEvent, now time.Time) (downtimePeriod, bool) {
+	end := w.end
+	if e.End != nil && e.End.Before(end) {
+		end = *e.End
+	}
+	if e.End == nil && now.Before(end) {
+		end = now
+	}
+
+	start := e.Start
+	if start.Before(w.start) {
+		start = w.start
+	}
+
+	if !end.After(start) {
+		return downtimePeriod{}, false
+	}
+	return downtimePeriod{start: start, end: end}, true
+}
+
+// serviceDowntime returns the merged downtime of one service given all of the
+// member's outage events and the service's domains (lower-case), split into
+// the hours counted against the SLA and the hours excluded by maintenance.
+func serviceDowntime(events []OutageEvent, domains map[string]bool, maintenance []downtimePeriod, w slaWindow, now time.Time) (down, excluded float64) {
+	periods := []downtimePeriod{}
+
+	for _, e := range events {
+		if _, ok := e.affectsService(domains); !ok {
+			continue
+		}
+		if p, ok := w.clip(e, now); ok {
+			periods = append(periods, p)
+		}
+	}
+
+	merged := mergeOverlappingPeriods(periods)
+	maint := mergeOverlappingPeriods(maintenance)
+
+	for _, p := range merged {
+		down += p.end.Sub(p.start).Hours()
+		for _, m := range maint {
+			excluded += overlapHours(p, m)
+		}
+	}
+	return down - excluded, excluded
+}
+
+// overlapHours returns how long a and b overlap.
+func overlapHours(a, b downtimePeriod) float64 {
+	start, end := a.start, a.end
+	if b.start.After(start) {
+		start = b.start
+	}
+	if b.end.Before(end) {
+		end = b.end
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start).Hours()
+}
+
+// newSLABreakdown derives uptime figures from downtime and a target.
+func newSLABreakdown(totalHours, downHours, excludedHours, threshold float64) SLABreakdown {
+	if downHours > totalHours {
+		downHours = totalHours
+	}
+	upHours := totalHours - downHours
+
+	uptimePercent := 100.0
+	if totalHours > 0 {
+		uptimePercent = (upHours / totalHours) * 100.0
+	}
+
+	return SLABreakdown{
+		HoursTotal:    totalHours,
+		HoursDown:     downHours,
+		HoursUp:       upHours,
+		Uptime:        uptimePercent,
+		SLAThreshold:  threshold,
+		SLAHours:      totalHours * (threshold / 100.0),
+		MeetsSLA:      uptimePercent >= threshold,
+		HoursExcluded: excludedHours,
+	}
+}
+
 // CalculateSLAAdjustments calculates actual uptime from the member_events table
 func CalculateSLAAdjustments(month time.Time, sum *Summary) (SLASummary, error) {
+	return calculateSLA(month, sum, memberPeriods(month, sum))
+}
+
+// calculateSLA computes every member's SLA over its active period; members
+// without a period are measured over the whole month.
+func calculateSLA(month time.Time, sum *Summary, periods map[string]ActivePeriod) (SLASummary, error) {
 	out := make(SLASummary)
 
 	// Check if database is initialized
@@ -42,51 +221,34 @@ func CalculateSLAAdjustments(month time.Time, sum *Summary) (SLASummary, error)
 		return nil, fmt.Errorf("database not initialized")
 	}
 
-	// Calculate the time range for the month
-	startTime := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
-	endTime := startTime.AddDate(0, 1, 0).Add(-time.Nanosecond) // Last nanosecond of the month
-
-	// Total hours in the month
-	totalHours := endTime.Sub(startTime).Hours()
-	slaHours := totalHours * (DefaultSLAPercentage / 100.0)
+	month = monthStart(month)
+	now := time.Now().UTC()
 
 	// Get configuration for member name mapping
 	c := cfg.GetConfig()
 
-	// Build member ID to DB name mapping
-	memberIDToDBName := make(map[string]string)
-	for id, member := range c.Members {
-		if member.Details.Name != "" {
-			memberIDToDBName[id] = member.Details.Name
-		} else {
-			memberIDToDBName[id] = id
+	for memberID, m := range sum.Members {
+		if _, ok := out[memberID]; !ok {
+			out[memberID] = make(map[string]SLABreakdown)
 		}
-	}
 
-	// Build service to domains mapping (active services only)
-	serviceToDomains := make(map[string][]string)
-	for svcName, svc := range c.Services {
-		if svc.Configuration.Active != 1 {
-			continue
-		}
-		domains := []string{}
-		for _, provider := range svc.Providers {
-			for _, rpcUrl := range provider.RpcUrls {
-				if domain := extractDomainFromURL(rpcUrl); domain != "" {
-					domains = append(domains, domain)
-				}
-			}
+		// member_events stores the member's Details.Name
+		dbMemberName := memberID
+		if member, ok := c.Members[memberID]; ok && member.Details.Name != "" {
+			dbMemberName = member.Details.Name
 		}
-		serviceToDomains[svcName] = domains
-	}
 
-	// Calculate downtime for each member/service combination
-	for memberID, m := range sum.Members {
-		if _, ok := out[memberID]; !ok {
-			out[memberID] = make(map[string]SLABreakdown)
+		w := monthWindow(month)
+		if p, ok := periods[memberID]; ok {
+			w = slaWindow{start: p.Start, end: p.End}
 		}
+		totalHours := w.hours()
 
-		dbMemberName := memberIDToDBName[memberID]
+		events, err := loadOutageEvents(dbMemberName, w)
+		if err != nil {
+			return nil, fmt.Errorf("load downtime for %s: %w", memberID, err)
+		}
+		windows := memberMaintenance(memberID, w)
 
 		for svcKey := range m.ServiceCosts {
 			svcConfig, svcExists := c.Services[svcKey]
@@ -94,35 +256,14 @@ func CalculateSLAAdjustments(month time.Time, sum *Summary) (SLASummary, error)
 				continue
 			}
 
-			// Calculate downtime for this specific service
-			downtime := calculateServiceDowntime(dbMemberName, svcKey, serviceToDomains[svcKey], startTime, endTime)
-
-			// Calculate uptime
-			uptime := totalHours - downtime
-			if uptime < 0 {
-				uptime = 0
-			}
+			downtime, excluded := serviceDowntime(events, serviceDomains(svcConfig),
+				maintenancePeriods(windows, svcKey, w), w, now)
+			bd := newSLABreakdown(totalHours, downtime, excluded, SLATarget(memberID, svcKey))
+			out[memberID][svcKey] = bd
 
-			uptimePercent := 100.0
-			if totalHours > 0 {
-				uptimePercent = (uptime / totalHours) * 100.0
-			}
-
-			meetsSLA := uptimePercent >= DefaultSLAPercentage
-
-			out[memberID][svcKey] = SLABreakdown{
-				HoursTotal:   totalHours,
-				HoursDown:    downtime,
-				HoursUp:      uptime,
-				Uptime:       uptimePercent,
-				SLAThreshold: DefaultSLAPercentage,
-				SLAHours:     slaHours,
-				MeetsSLA:     meetsSLA,
-			}
-
-			if downtime > 0 {
-				log.Log(log.Info, "[SLA] %s/%s - Total downtime: %.2f hours (%.2f%% uptime)",
-					memberID, svcKey, downtime, uptimePercent)
+			if downtime > 0 || excluded > 0 {
+				log.Log(log.Info, "[SLA] %s/%s - Total downtime: %.2f hours (%.2f%% uptime), %.2f hours excluded as maintenance",
+					memberID, svcKey, downtime, bd.Uptime, excluded)
 			}
 		}
 	}
@@ -130,146 +271,117 @@ func CalculateSLAAdjustments(month time.Time, sum *Summary) (SLASummary, error)
 	return out, nil
 }
 
-// calculateServiceDowntime calculates total downtime hours for a specific service
-func calculateServiceDowntime(memberName, serviceName string, domains []string, startTime, endTime time.Time) float64 {
+// ServiceOutage is one outage of a member's service as the SLA engine counts
+// it: clipped to the member's active period, open outages ending at now.
+type ServiceOutage struct {
+	OutageEvent
+	From          time.Time
+	To            time.Time
+	HoursDown     float64 // counted against the SLA
+	HoursExcluded float64 // inside maintenance windows
+}
+
+// ServiceOutages returns the outages behind the SLA breakdown of one member
+// service, newest first. Overlapping outages are listed separately, so their
+// HoursDown can add up to more than the breakdown's merged HoursDown.
+func (s *Snapshot) ServiceOutages(memberID, service string) ([]ServiceOutage, error) {
 	if data2.DB == nil {
-		return 0
+		return nil, fmt.Errorf("database not initialized")
 	}
 
-	// Collect all downtime periods
-	allPeriods := []downtimePeriod{}
-
-	// Query for site-level checks (affects all services)
-	// This handles scenarios A, B, C, D, and E for site-level events
-	siteQuery := `
-		SELECT  
-			start_time,
-			end_time
-		FROM member_events
-		WHERE member_name = ?
-		AND check_type IN ('site', '1')
-		AND status = 0
-		AND (
-			-- Event starts before period and ends during or after period
-			(start_time < ? AND (end_time IS NULL OR end_time > ?))
-			OR
-			-- Event starts during period
-			(start_time >= ? AND start_time < ?)
-		)
-	`
+	c := cfg.GetConfig()
+	dbMemberName := memberID
+	if member, ok := c.Members[memberID]; ok && member.Details.Name != "" {
+		dbMemberName = member.Details.Name
+	}
+	svcConfig, ok := c.Services[service]
+	if !ok {
+		return []ServiceOutage{}, nil
+	}
 
-	rows, err := data2.DB.Query(siteQuery, memberName, endTime, startTime, startTime, endTime)
+	period, _ := s.MemberPeriod(memberID)
+	w := slaWindow{start: period.Start, end: period.End}
+	events, err := loadOutageEvents(dbMemberName, w)
 	if err != nil {
-		log.Log(log.Error, "[SLA] Failed to query site downtime: %v", err)
-	} else {
-		defer rows.Close()
-		siteCount := 0
-		for rows.Next() {
-			var eventStart time.Time
-			var eventEnd *time.Time
-
-			if err := rows.Scan(&eventStart, &eventEnd); err != nil {
-				log.Log(log.Error, "[SLA] Failed to scan site event: %v", err)
-				continue
-			}
-
-			// Adjust times to month boundaries
-			if eventStart.Before(startTime) {
-				eventStart = startTime
-			}
-
-			actualEnd := endTime
-			if eventEnd != nil && eventEnd.Before(endTime) {
-				actualEnd = *eventEnd
-			}
-
-			allPeriods = append(allPeriods, downtimePeriod{start: eventStart, end: actualEnd})
-			siteCount++
-		}
+		return nil, fmt.Errorf("load downtime for %s: %w", memberID, err)
 	}
+	maint := mergeOverlappingPeriods(maintenancePeriods(memberMaintenance(memberID, w), service, w))
 
-	// Query for domain/endpoint checks specific to this service
-	if len(domains) > 0 {
-		// Build parameterized query
-		placeholders := make([]string, len(domains))
-		args := make([]interface{}, 0, len(domains)+5)
-		args = append(args, memberName)
+	outages := serviceOutages(events, serviceDomains(svcConfig), maint, w, time.Now().UTC())
+	sort.SliceStable(outages, func(i, j int) bool { return outages[i].From.After(outages[j].From) })
+	return outages, nil
+}
 
-		for i, domain := range domains {
-			placeholders[i] = "?"
-			args = append(args, domain)
+// serviceOutages clips the events affecting a service to w and splits each
+// into downtime and time covered by the merged maintenance periods.
+func serviceOutages(events []OutageEvent, domains map[string]bool, maintenance []downtimePeriod, w slaWindow, now time.Time) []ServiceOutage {
+	outages := []ServiceOutage{}
+	for _, e := range events {
+		if _, ok := e.affectsService(domains); !ok {
+			continue
+		}
+		p, ok := w.clip(e, now)
+		if !ok {
+			continue
 		}
 
-		args = append(args, endTime, startTime, startTime, endTime)
-
-		serviceQuery := fmt.Sprintf(`
-			SELECT  
-				check_type,
-				domain_name,
-				endpoint,
-				start_time,
-				end_time
-			FROM member_events
-			WHERE member_name = ?
-		AND check_type IN ('domain', '2', 'endpoint', '3')
-			AND status = 0
-			AND domain_name IN (%s)
-			AND (
-				-- Event starts before period and ends during or after period
-				(start_time < ? AND (end_time IS NULL OR end_time > ?))
-				OR
-				-- Event starts during period
-				(start_time >= ? AND start_time < ?)
-			)
-		`, strings.Join(placeholders, ","))
-
-		rows, err := data2.DB.Query(serviceQuery, args...)
-		if err != nil {
-			log.Log(log.Error, "[SLA] Failed to query service downtime: %v", err)
-		} else {
-			defer rows.Close()
-			serviceCount := 0
-
-			for rows.Next() {
-				var checkType string
-				var domainName, endpoint string
-				var eventStart time.Time
-				var eventEnd *time.Time
-
-				if err := rows.Scan(&checkType, &domainName, &endpoint, &eventStart, &eventEnd); err != nil {
-					log.Log(log.Error, "[SLA] Failed to scan service event: %v", err)
-					continue
-				}
-
-				// Adjust times to month boundaries
-				if eventStart.Before(startTime) {
-					eventStart = startTime
-				}
-
-				actualEnd := endTime
-				if eventEnd != nil && eventEnd.Before(endTime) {
-					actualEnd = *eventEnd
-				}
-
-				allPeriods = append(allPeriods, downtimePeriod{start: eventStart, end: actualEnd})
-				serviceCount++
-			}
+		o := ServiceOutage{OutageEvent: e, From: p.start, To: p.end}
+		for _, m := range maintenance {
+			o.HoursExcluded += overlapHours(p, m)
 		}
+		o.HoursDown = p.end.Sub(p.start).Hours() - o.HoursExcluded
+		outages = append(outages, o)
 	}
+	return outages
+}
 
-	// Merge overlapping periods to avoid double-counting
-	if len(allPeriods) == 0 {
-		return 0
+// loadOutageEvents returns every outage of a member that overlaps w.
+func loadOutageEvents(memberName string, w slaWindow) ([]OutageEvent, error) {
+	rows, err := data2.DB.Query(`
+		SELECT id, check_type, COALESCE(check_name, ''), COALESCE(domain_name, ''),
+		       COALESCE(endpoint, ''), COALESCE(error, ''), COALESCE(is_ipv6, 0),
+		       start_time, end_time
+		FROM member_events
+		WHERE member_name = ?
+		AND status = 0
+		AND start_time < ?
+		AND (end_time IS NULL OR end_time > ?)
+	`, memberName, w.end, w.start)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	merged := mergeOverlappingPeriods(allPeriods)
-	totalDowntime := 0.0
+	events := []OutageEvent{}
+	for rows.Next() {
+		var (
+			e   OutageEvent
+			end sql.NullTime
+		)
+		if err := rows.Scan(&e.ID, &e.CheckType, &e.CheckName, &e.Domain, &e.Endpoint, &e.Error, &e.IPv6, &e.Start, &end); err != nil {
+			log.Log(log.Error, "[SLA] Failed to scan downtime event: %v", err)
+			continue
+		}
+		if end.Valid {
+			t := end.Time
+			e.End = &t
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
 
-	for _, period := range merged {
-		hours := period.end.Sub(period.start).Hours()
-		totalDowntime += hours
+// serviceDomains returns the lower-case domains of a service's RPC URLs.
+func serviceDomains(svc cfg.Service) map[string]bool {
+	domains := make(map[string]bool)
+	for _, provider := range svc.Providers {
+		for _, rpcUrl := range provider.RpcUrls {
+			if domain := extractDomainFromURL(rpcUrl); domain != "" {
+				domains[domain] = true
+			}
+		}
 	}
-	return totalDowntime
+	return domains
 }
 
 // mergeOverlappingPeriods merges overlapping downtime periods to avoid double-counting
@@ -279,13 +391,7 @@ func mergeOverlappingPeriods(periods []downtimePeriod) []downtimePeriod {
 	}
 
 	// Sort by start time
-	for i := 0; i < len(periods)-1; i++ {
-		for j := i + 1; j < len(periods); j++ {
-			if periods[j].start.Before(periods[i].start) {
-				periods[i], periods[j] = periods[j], periods[i]
-			}
-		}
-	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].start.Before(periods[j].start) })
 
 	merged := []downtimePeriod{periods[0]}
 
@@ -294,7 +400,7 @@ func mergeOverlappingPeriods(periods []downtimePeriod) []downtimePeriod {
 		current := periods[i]
 
 		// If current period overlaps with last, merge them
-		if current.start.Before(last.end) || current.start.Equal(last.end) {
+		if !current.start.After(last.end) {
 			if current.end.After(last.end) {
 				last.end = current.end
 			}
@@ -325,40 +431,3 @@ func extractDomainFromURL(rpcUrl string) string {
 
 	return strings.ToLower(url)
 }
-
-// mapDomainToService maps a domain name to a service name
-func mapDomainToService(domain, checkType string) string {
-	if checkType == "site" {
-		// Site-level checks don't map to a specific service
-		return ""
-	}
-
-	if domain == "" {
-		return ""
-	}
-
-	c := cfg.GetConfig()
-	for svcName, svc := range c.Services {
-		for _, provider := range svc.Providers {
-			for _, rpcUrl := range provider.RpcUrls {
-				// Clean up the URL for comparison
-				cleanUrl := strings.ToLower(strings.TrimSpace(rpcUrl))
-				cleanDomain := strings.ToLower(strings.TrimSpace(domain))
-
-				// Check if the domain is contained in the RPC URL
-				if strings.Contains(cleanUrl, cleanDomain) {
-					return svcName
-				}
-
-				// Also check if the RPC URL contains the domain without protocol
-				if strings.Contains(cleanUrl, "://"+cleanDomain) ||
-					strings.Contains(cleanUrl, "://"+cleanDomain+":") ||
-					strings.Contains(cleanUrl, "://"+cleanDomain+"/") {
-					return svcName
-				}
-			}
-		}
-	}
-
-	return ""
-}
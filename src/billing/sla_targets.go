@@ -0,0 +1,74 @@
+package billing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	common "github.com/ibp-network/ibp-geodns-collator/src/common"
+
+	cfg "github.com/ibp-network/ibp-geodns-libs/config"
+)
+
+// SLATarget returns the uptime percentage a member must meet for a service.
+// Overrides are resolved most specific first: member, service, membership
+// level, then the configured default. An empty service skips the service
+// override and yields the member's general target.
+func SLATarget(memberID, service string) float64 {
+	s := common.GetSettings().Sla
+	c := cfg.GetConfig()
+
+	member, hasMember := c.Members[memberID]
+	for name, target := range s.MemberTargets {
+		if strings.EqualFold(name, memberID) ||
+			(hasMember && member.Details.Name != "" && strings.EqualFold(name, member.Details.Name)) {
+			return target
+		}
+	}
+
+	if service != "" {
+		for name, target := range s.ServiceTargets {
+			if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(service)) {
+				return target
+			}
+		}
+	}
+
+	if hasMember {
+		if target, ok := s.LevelTargets[member.Membership.Level]; ok {
+			return target
+		}
+	}
+
+	return defaultSLATarget()
+}
+
+// defaultSLATarget is the network-wide target used when nothing more specific applies.
+func defaultSLATarget() float64 {
+	if t := common.GetSettings().Sla.DefaultTarget; t > 0 {
+		return t
+	}
+	return DefaultSLAPercentage
+}
+
+// describeSLATargets renders the default target followed by any per-level
+// targets, e.g. "99.99% (L1 99.00%, L5 99.95%)".
+func describeSLATargets() string {
+	s := common.GetSettings().Sla
+	out := fmt.Sprintf("%.2f%%", defaultSLATarget())
+	if len(s.LevelTargets) == 0 {
+		return out
+	}
+
+	levels := make([]int, 0, len(s.LevelTargets))
+	for l := range s.LevelTargets {
+		levels = append(levels, l)
+	}
+	sort.Ints(levels)
+
+	parts := make([]string, 0, len(levels))
+	for _, l := range levels {
+		parts = append(parts, fmt.Sprintf("L%d %.2f%%", l, s.LevelTargets[l]))
+	}
+	return out + " (" + strings.Join(parts, ", ") + ")"
+}
@@ -0,0 +1,363 @@
+package billing
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func at(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func ptr(t time.Time) *time.Time { return &t }
+
+func TestMonthWindow(t *testing.T) {
+	tests := []struct {
+		name  string
+		month time.Time
+		start time.Time
+		hours float64
+	}{
+		{"january", at("2025-01-17 13:00"), at("2025-01-01 00:00"), 31 * 24},
+		{"april", at("2025-04-01 00:00"), at("2025-04-01 00:00"), 30 * 24},
+		{"february", at("2025-02-28 23:59"), at("2025-02-01 00:00"), 28 * 24},
+		{"leap february", at("2024-02-10 00:00"), at("2024-02-01 00:00"), 29 * 24},
+		{"december rolls into next year", at("2024-12-31 23:00"), at("2024-12-01 00:00"), 31 * 24},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := monthWindow(tt.month)
+			if !w.start.Equal(tt.start) {
+				t.Errorf("start = %s, want %s", w.start, tt.start)
+			}
+			if w.hours() != tt.hours {
+				t.Errorf("hours = %v, want %v", w.hours(), tt.hours)
+			}
+		})
+	}
+}
+
+func TestServiceDowntime(t *testing.T) {
+	w := monthWindow(at("2025-03-01 00:00"))
+	afterMonth := at("2025-04-15 00:00")
+	domains := map[string]bool{"rpc.example.com": true}
+
+	tests := []struct {
+		name   string
+		events []OutageEvent
+		now    time.Time
+		want   float64
+	}{
+		{
+			name: "no events",
+			now:  afterMonth,
+			want: 0,
+		},
+		{
+			name: "single site outage",
+			events: []OutageEvent{
+				{CheckType: "site", Start: at("2025-03-02 10:00"), End: ptr(at("2025-03-02 12:00"))},
+			},
+			now:  afterMonth,
+			want: 2,
+		},
+		{
+			name: "overlapping outages are counted once",
+			events: []OutageEvent{
+				{CheckType: "site", Start: at("2025-03-02 10:00"), End: ptr(at("2025-03-02 12:00"))},
+				{CheckType: "domain", Domain: "rpc.example.com", Start: at("2025-03-02 11:00"), End: ptr(at("2025-03-02 13:00"))},
+				{CheckType: "endpoint", Domain: "rpc.example.com", Start: at("2025-03-02 10:30"), End: ptr(at("2025-03-02 11:30"))},
+			},
+			now:  afterMonth,
+			want: 3,
+		},
+		{
+			name: "touching outages merge",
+			events: []OutageEvent{
+				{CheckType: "site", Start: at("2025-03-05 01:00"), End: ptr(at("2025-03-05 02:00"))},
+				{CheckType: "site", Start: at("2025-03-05 02:00"), End: ptr(at("2025-03-05 03:00"))},
+			},
+			now:  afterMonth,
+			want: 2,
+		},
+		{
+			name: "disjoint outages add up regardless of order",
+			events: []OutageEvent{
+				{CheckType: "site", Start: at("2025-03-20 00:00"), End: ptr(at("2025-03-20 01:00"))},
+				{CheckType: "site", Start: at("2025-03-10 00:00"), End: ptr(at("2025-03-10 02:00"))},
+			},
+			now:  afterMonth,
+			want: 3,
+		},
+		{
+			name: "numeric check types",
+			events: []OutageEvent{
+				{CheckType: "1", Start: at("2025-03-02 00:00"), End: ptr(at("2025-03-02 01:00"))},
+				{CheckType: "2", Domain: "rpc.example.com", Start: at("2025-03-03 00:00"), End: ptr(at("2025-03-03 01:00"))},
+				{CheckType: "3", Domain: "rpc.example.com", Start: at("2025-03-04 00:00"), End: ptr(at("2025-03-04 01:00"))},
+			},
+			now:  afterMonth,
+			want: 3,
+		},
+		{
+			name: "domain match is case-insensitive",
+			events: []OutageEvent{
+				{CheckType: "domain", Domain: " RPC.Example.com ", Start: at("2025-03-02 00:00"), End: ptr(at("2025-03-02 01:30"))},
+			},
+			now:  afterMonth,
+			want: 1.5,
+		},
+		{
+			name: "other domains and unknown check types are ignored",
+			events: []OutageEvent{
+				{CheckType: "domain", Domain: "other.example.com", Start: at("2025-03-02 00:00"), End: ptr(at("2025-03-02 05:00"))},
+				{CheckType: "endpoint", Start: at("2025-03-02 00:00"), End: ptr(at("2025-03-02 05:00"))},
+				{CheckType: "9", Start: at("2025-03-02 00:00"), End: ptr(at("2025-03-02 05:00"))},
+			},
+			now:  afterMonth,
+			want: 0,
+		},
+		{
+			name: "open outage runs until now",
+			events: []OutageEvent{
+				{CheckType: "site", Start: at("2025-03-10 00:00")},
+			},
+			now:  at("2025-03-10 06:00"),
+			want: 6,
+		},
+		{
+			name: "open outage stops at month end",
+			events: []OutageEvent{
+				{CheckType: "site", Start: at("2025-03-31 20:00")},
+			},
+			now:  afterMonth,
+			want: 4,
+		},
+		{
+			name: "open outage started after now counts nothing",
+			events: []OutageEvent{
+				{CheckType: "site", Start: at("2025-03-12 00:00")},
+			},
+			now:  at("2025-03-10 00:00"),
+			want: 0,
+		},
+		{
+			name: "outage starting in the previous month is clipped",
+			events: []OutageEvent{
+				{CheckType: "site", Start: at("2025-02-28 20:00"), End: ptr(at("2025-03-01 03:00"))},
+			},
+			now:  afterMonth,
+			want: 3,
+		},
+		{
+			name: "outage running into the next month is clipped",
+			events: []OutageEvent{
+				{CheckType: "site", Start: at("2025-03-31 22:00"), End: ptr(at("2025-04-01 05:00"))},
+			},
+			now:  afterMonth,
+			want: 2,
+		},
+		{
+			name: "outage spanning the whole month",
+			events: []OutageEvent{
+				{CheckType: "site", Start: at("2025-02-20 00:00"), End: ptr(at("2025-04-02 00:00"))},
+			},
+			now:  afterMonth,
+			want: 31 * 24,
+		},
+		{
+			name: "outages outside the month are ignored",
+			events: []OutageEvent{
+				{CheckType: "site", Start: at("2025-02-27 00:00"), End: ptr(at("2025-03-01 00:00"))},
+				{CheckType: "site", Start: at("2025-04-01 00:00"), End: ptr(at("2025-04-01 02:00"))},
+			},
+			now:  afterMonth,
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := serviceDowntime(tt.events, domains, nil, w, tt.now)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("downtime = %v hours, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceDowntimeMaintenance(t *testing.T) {
+	w := monthWindow(at("2025-03-01 00:00"))
+	now := at("2025-04-15 00:00")
+	outage := []OutageEvent{
+		{CheckType: "site", Start: at("2025-03-02 10:00"), End: ptr(at("2025-03-02 14:00"))},
+		{CheckType: "site", Start: at("2025-03-02 12:00"), End: ptr(at("2025-03-02 13:00"))},
+	}
+
+	tests := []struct {
+		name         string
+		windows      []MaintenanceWindow
+		service      string
+		wantDown     float64
+		wantExcluded float64
+	}{
+		{
+			name:     "no maintenance",
+			service:  "polkadot",
+			wantDown: 4,
+		},
+		{
+			name: "window covers part of the outage",
+			windows: []MaintenanceWindow{
+				{Start: at("2025-03-02 09:00"), End: at("2025-03-02 11:00")},
+			},
+			service:      "polkadot",
+			wantDown:     3,
+			wantExcluded: 1,
+		},
+		{
+			name: "overlapping windows are merged before subtracting",
+			windows: []MaintenanceWindow{
+				{Start: at("2025-03-02 10:00"), End: at("2025-03-02 12:00")},
+				{Start: at("2025-03-02 11:00"), End: at("2025-03-02 13:00")},
+			},
+			service:      "polkadot",
+			wantDown:     1,
+			wantExcluded: 3,
+		},
+		{
+			name: "window covers the whole outage",
+			windows: []MaintenanceWindow{
+				{Start: at("2025-03-01 00:00"), End: at("2025-03-03 00:00")},
+			},
+			service:      "polkadot",
+			wantExcluded: 4,
+		},
+		{
+			name: "window for another service is ignored",
+			windows: []MaintenanceWindow{
+				{Services: []string{"kusama"}, Start: at("2025-03-02 10:00"), End: at("2025-03-02 14:00")},
+			},
+			service:  "polkadot",
+			wantDown: 4,
+		},
+		{
+			name: "window listing the service applies",
+			windows: []MaintenanceWindow{
+				{Services: []string{"kusama", "Polkadot"}, Start: at("2025-03-02 13:00"), End: at("2025-03-02 15:00")},
+			},
+			service:      "polkadot",
+			wantDown:     3,
+			wantExcluded: 1,
+		},
+		{
+			name: "window without downtime excludes nothing",
+			windows: []MaintenanceWindow{
+				{Start: at("2025-03-20 00:00"), End: at("2025-03-21 00:00")},
+			},
+			service:  "polkadot",
+			wantDown: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			down, excluded := serviceDowntime(outage, nil, maintenancePeriods(tt.windows, tt.service, w), w, now)
+			if math.Abs(down-tt.wantDown) > 1e-9 {
+				t.Errorf("downtime = %v hours, want %v", down, tt.wantDown)
+			}
+			if math.Abs(excluded-tt.wantExcluded) > 1e-9 {
+				t.Errorf("excluded = %v hours, want %v", excluded, tt.wantExcluded)
+			}
+		})
+	}
+}
+
+func TestServiceOutages(t *testing.T) {
+	// member joined on the 10th
+	w := slaWindow{start: at("2025-03-10 00:00"), end: at("2025-04-01 00:00")}
+	now := at("2025-03-20 12:00")
+	domains := map[string]bool{"rpc.example.com": true}
+	events := []OutageEvent{
+		{ID: 1, CheckType: "1", Start: at("2025-03-09 22:00"), End: ptr(at("2025-03-10 02:00"))},
+		{ID: 2, CheckType: "domain", Domain: "RPC.example.com ", Start: at("2025-03-12 10:00"), End: ptr(at("2025-03-12 14:00"))},
+		{ID: 3, CheckType: "domain", Domain: "other.example.com", Start: at("2025-03-12 10:00"), End: ptr(at("2025-03-12 14:00"))},
+		{ID: 4, CheckType: "endpoint", Domain: "rpc.example.com", Start: at("2025-03-20 10:00")},
+		{ID: 5, CheckType: "site", Start: at("2025-03-05 10:00"), End: ptr(at("2025-03-05 12:00"))},
+	}
+	maintenance := []downtimePeriod{{start: at("2025-03-12 13:00"), end: at("2025-03-12 16:00")}}
+
+	tests := []struct {
+		id           int64
+		from, to     time.Time
+		wantDown     float64
+		wantExcluded float64
+	}{
+		{1, at("2025-03-10 00:00"), at("2025-03-10 02:00"), 2, 0},
+		{2, at("2025-03-12 10:00"), at("2025-03-12 14:00"), 3, 1},
+		{4, at("2025-03-20 10:00"), now, 2, 0},
+	}
+
+	got := serviceOutages(events, domains, maintenance, w, now)
+	if len(got) != len(tests) {
+		t.Fatalf("got %d outages, want %d: %+v", len(got), len(tests), got)
+	}
+	for i, tt := range tests {
+		o := got[i]
+		if o.ID != tt.id || !o.From.Equal(tt.from) || !o.To.Equal(tt.to) {
+			t.Errorf("outage %d = #%d %s – %s, want #%d %s – %s", i, o.ID, o.From, o.To, tt.id, tt.from, tt.to)
+		}
+		if math.Abs(o.HoursDown-tt.wantDown) > 1e-9 || math.Abs(o.HoursExcluded-tt.wantExcluded) > 1e-9 {
+			t.Errorf("outage #%d hours = %v down / %v excluded, want %v / %v", o.ID, o.HoursDown, o.HoursExcluded, tt.wantDown, tt.wantExcluded)
+		}
+	}
+
+	// the listed outages don't overlap here, so they add up to the engine's downtime
+	down, excluded := serviceDowntime(events, domains, maintenance, w, now)
+	sumDown, sumExcluded := 0.0, 0.0
+	for _, o := range got {
+		sumDown += o.HoursDown
+		sumExcluded += o.HoursExcluded
+	}
+	if math.Abs(down-sumDown) > 1e-9 || math.Abs(excluded-sumExcluded) > 1e-9 {
+		t.Errorf("outages sum to %v / %v, engine reports %v / %v", sumDown, sumExcluded, down, excluded)
+	}
+}
+
+func TestNewSLABreakdown(t *testing.T) {
+	tests := []struct {
+		name       string
+		total      float64
+		down       float64
+		threshold  float64
+		wantUptime float64
+		wantMeets  bool
+	}{
+		{"no downtime", 720, 0, 99.99, 100, true},
+		{"within target", 720, 0.036, 99.99, 99.995, true},
+		{"below target", 720, 7.2, 99.9, 99, false},
+		{"downtime capped at total", 720, 1000, 99, 0, false},
+		{"empty window", 0, 0, 99.99, 100, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bd := newSLABreakdown(tt.total, tt.down, 0, tt.threshold)
+			if math.Abs(bd.Uptime-tt.wantUptime) > 1e-9 {
+				t.Errorf("uptime = %v, want %v", bd.Uptime, tt.wantUptime)
+			}
+			if bd.MeetsSLA != tt.wantMeets {
+				t.Errorf("meets SLA = %v, want %v", bd.MeetsSLA, tt.wantMeets)
+			}
+			if bd.HoursUp+bd.HoursDown != bd.HoursTotal {
+				t.Errorf("up %v + down %v != total %v", bd.HoursUp, bd.HoursDown, bd.HoursTotal)
+			}
+		})
+	}
+}
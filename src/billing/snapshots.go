@@ -0,0 +1,264 @@
+package billing
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	cfg "github.com/ibp-network/ibp-geodns-libs/config"
+	data2 "github.com/ibp-network/ibp-geodns-libs/data2"
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+)
+
+// ErrSnapshotNotFound is returned when a month has no persisted snapshot.
+var ErrSnapshotNotFound = errors.New("billing snapshot not found")
+
+// Snapshot is the billing state of one month: member and service costs, the
+// pricing and resources they were computed from, the SLA results and the
+// resulting credits. Generated months are persisted so that later changes to
+// members, services or pricing do not alter past bills.
+type Snapshot struct {
+	Month     time.Time
+	Created   time.Time
+	Summary   Summary
+	SLA       SLASummary
+	Credits   map[string]MemberCredit    // member → credits after policy and caps
+	Pricing   map[string]cfg.IaasPricing // region → pricing in force at month end
+	Regions   map[string]string          // member → pricing region
+	Levels    map[string]int             // member → membership level
+	Resources map[string]cfg.Resources   // service → resources per instance at month end
+	Periods   map[string]ActivePeriod    // member → active period within the month
+
+	// Manual adjustments are read from the ledger whenever a snapshot is
+	// loaded, so that credits granted after a month was frozen still apply.
+	Adjustments map[string][]Adjustment `json:"-"`
+
+	Persisted bool `json:"-"` // read from billing_snapshots rather than live config
+}
+
+// MemberCredits returns the credits recorded for a member, computing them
+// from the snapshot's SLA results when none were recorded.
+func (s *Snapshot) MemberCredits(memberID string) MemberCredit {
+	if mc, ok := s.Credits[memberID]; ok {
+		return mc
+	}
+	return CalculateMemberCredits(memberID, s.Summary.Members[memberID].ServiceCosts, s.SLA)
+}
+
+// MemberAdjustments returns a member's active adjustments and their total.
+func (s *Snapshot) MemberAdjustments(memberID string) ([]Adjustment, float64) {
+	adjustments := s.Adjustments[memberID]
+	return adjustments, adjustmentsTotal(adjustments)
+}
+
+// MemberPeriod returns the member's active period and whether it covers only
+// part of the month.
+func (s *Snapshot) MemberPeriod(memberID string) (ActivePeriod, bool) {
+	p, ok := s.Periods[memberID]
+	if !ok {
+		return monthWindow(s.Month).period(), false
+	}
+	return p, p.Partial()
+}
+
+// MemberLevel returns the membership level a member was billed at.
+func (s *Snapshot) MemberLevel(memberID string) int {
+	if level, ok := s.Levels[memberID]; ok {
+		return level
+	}
+	return cfg.GetConfig().Members[memberID].Membership.Level
+}
+
+// ServiceResources returns the resources a service was billed with.
+func (s *Snapshot) ServiceResources(service string) (cfg.Resources, bool) {
+	if res, ok := s.Resources[service]; ok {
+		return res, true
+	}
+	if svc, ok := cfg.GetConfig().Services[service]; ok {
+		return svc.Resources, true
+	}
+	return cfg.Resources{}, false
+}
+
+const createSnapshotsTable = `
+	CREATE TABLE IF NOT EXISTS billing_snapshots (
+		billing_month DATE     NOT NULL,
+		created_at    DATETIME NOT NULL,
+		data          LONGTEXT NOT NULL,
+		PRIMARY KEY (billing_month)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci`
+
+// snapshotsEnabled is false when the billing_snapshots table could not be
+// prepared; every month is then computed from live config.
+var snapshotsEnabled bool
+
+// initSnapshotState prepares the billing_snapshots table.
+func initSnapshotState() {
+	if data2.DB == nil {
+		log.Log(log.Warn, "[billing] database unavailable — billing snapshots disabled")
+		return
+	}
+
+	if _, err := data2.DB.Exec(createSnapshotsTable); err != nil {
+		log.Log(log.Error, "[billing] failed to prepare billing_snapshots table: %v", err)
+		return
+	}
+	snapshotsEnabled = true
+}
+
+// newSnapshot captures sum and sla for month together with the pricing and
+// resources of the current config.
+func newSnapshot(month time.Time, sum Summary, sla SLASummary) *Snapshot {
+	c := cfg.GetConfig()
+
+	snap := &Snapshot{
+		Month:     monthStart(month),
+		Created:   time.Now().UTC(),
+		Summary:   sum,
+		SLA:       sla,
+		Credits:   make(map[string]MemberCredit, len(sum.Members)),
+		Pricing:   make(map[string]cfg.IaasPricing),
+		Regions:   make(map[string]string, len(sum.Members)),
+		Levels:    make(map[string]int, len(sum.Members)),
+		Resources: make(map[string]cfg.Resources, len(sum.Services)),
+		Periods:   make(map[string]ActivePeriod, len(sum.Members)),
+
+		Adjustments: monthAdjustments(month),
+	}
+
+	priceByRegion := make(map[string]cfg.IaasPricing)
+	for r, p := range c.Pricing {
+		priceByRegion[strings.ToLower(strings.TrimSpace(r))] = p
+	}
+
+	for memberID, mc := range sum.Members {
+		snap.Credits[memberID] = CalculateMemberCredits(memberID, mc.ServiceCosts, sla)
+
+		if member, ok := c.Members[memberID]; ok {
+			snap.Levels[memberID] = member.Membership.Level
+			region := strings.ToLower(strings.TrimSpace(member.Location.Region))
+			snap.Regions[memberID] = region
+			if p, ok := priceByRegion[region]; ok {
+				snap.Pricing[region] = p
+			}
+		}
+	}
+
+	for svcName := range sum.Services {
+		if svc, ok := c.Services[svcName]; ok {
+			snap.Resources[svcName] = svc.Resources
+		}
+	}
+
+	return snap
+}
+
+// LiveSnapshot computes month from the recorded config history, prorated by
+// the time each version was in force, or from the current billing summary
+// prorated to each member's join date when no history is available. The SLA
+// error is returned alongside a snapshot without SLA results so that callers
+// can decide whether to carry on.
+func LiveSnapshot(month time.Time) (*Snapshot, error) {
+	return liveSnapshot(month, monthWindow(month).end)
+}
+
+// liveSnapshot computes month up to until: active periods end there at the
+// latest, so costs cover only that part of the month and the SLA does not
+// count the hours after it as uptime.
+func liveSnapshot(month, until time.Time) (*Snapshot, error) {
+	w := monthWindow(month)
+	if until.Before(w.end) {
+		w.end = until
+	}
+
+	sum, history, ok := historicalSummary(w)
+	var periods map[string]ActivePeriod
+	if ok {
+		periods = history.periods(w)
+	} else {
+		sum, periods = configSummary(GetSummary(), w)
+	}
+
+	sla, err := calculateSLA(month, &sum, periods)
+	if err != nil {
+		sla = make(SLASummary)
+	}
+
+	snap := newSnapshot(month, sum, sla)
+	for memberID := range sum.Members {
+		if p, found := periods[memberID]; found {
+			snap.Periods[memberID] = p
+		}
+	}
+	if ok {
+		// record what was in force at the end of the month (or now)
+		at := w.end.Add(-time.Second)
+		if now := time.Now().UTC(); now.Before(at) {
+			at = now
+		}
+		snap.Pricing, snap.Regions, snap.Resources = history.stateAt(at)
+	}
+	return snap, err
+}
+
+// MonthSnapshot returns the persisted snapshot of month, or a live one when the
+// month has not been generated yet.
+func MonthSnapshot(month time.Time) (*Snapshot, error) {
+	snap, err := LoadSnapshot(month)
+	if err == nil {
+		return snap, nil
+	}
+	if !errors.Is(err, ErrSnapshotNotFound) {
+		log.Log(log.Error, "[billing] failed to load snapshot for %s, using live config: %v", month.Format("2006-01"), err)
+	}
+	return LiveSnapshot(month)
+}
+
+// LoadSnapshot reads the persisted snapshot of month.
+func LoadSnapshot(month time.Time) (*Snapshot, error) {
+	if !snapshotsEnabled {
+		return nil, ErrSnapshotNotFound
+	}
+
+	var data string
+	err := data2.DB.QueryRow(`SELECT data FROM billing_snapshots WHERE billing_month = ?`,
+		monthStart(month).Format("2006-01-02")).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{}
+	if err := json.Unmarshal([]byte(data), snap); err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+	snap.Persisted = true
+	snap.Adjustments = monthAdjustments(month)
+	return snap, nil
+}
+
+// saveSnapshot persists snap. An existing snapshot of the same month is kept,
+// so a month is frozen the first time it is generated.
+func saveSnapshot(snap *Snapshot) error {
+	if !snapshotsEnabled {
+		return nil
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	if _, err := data2.DB.Exec(`
+		INSERT IGNORE INTO billing_snapshots (billing_month, created_at, data)
+		VALUES (?, ?, ?)`, snap.Month.Format("2006-01-02"), snap.Created, string(data)); err != nil {
+		return err
+	}
+	snap.Persisted = true
+	return nil
+}
@@ -0,0 +1,97 @@
+package billing
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	cfg "github.com/ibp-network/ibp-geodns-libs/config"
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+
+	common "github.com/ibp-network/ibp-geodns-collator/src/common"
+)
+
+// ErrArtifactNotFound is returned by ArtifactStore.Get for unknown keys.
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// Artifact describes a stored report.
+type Artifact struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+}
+
+// Name returns the file name part of the key.
+func (a Artifact) Name() string {
+	return path.Base(a.Key)
+}
+
+// ArtifactStore keeps the generated reports. Keys are slash-separated and
+// relative to the store root: "2006-01/<file>" for the files of a billing
+// month, "<file>" for the service cost PDF.
+type ArtifactStore interface {
+	// Put stores r under key, replacing any previous object. Readers never
+	// see a partial write.
+	Put(key string, r io.Reader) error
+	// Get opens key, or returns ErrArtifactNotFound.
+	Get(key string) (io.ReadCloser, Artifact, error)
+	// List returns the artifacts whose key starts with prefix, sorted by key.
+	List(prefix string) ([]Artifact, error)
+	// Delete removes key; deleting a missing key is not an error.
+	Delete(key string) error
+}
+
+var artifacts struct {
+	once  sync.Once
+	store ArtifactStore
+}
+
+// Artifacts returns the store selected by the Storage settings, created on
+// first use.
+func Artifacts() ArtifactStore {
+	artifacts.once.Do(func() {
+		settings := common.GetSettings().Storage
+		switch settings.BackendName() {
+		case common.StorageS3:
+			artifacts.store = newS3Store(settings.S3)
+			log.Log(log.Info, "[billing] Storing reports in S3 bucket %s at %s", settings.S3.Bucket, settings.S3.Endpoint)
+		default:
+			root := resolveTempDir(cfg.GetConfig())
+			artifacts.store = newLocalStore(root)
+			log.Log(log.Info, "[billing] Storing reports in %s", root)
+		}
+	})
+	return artifacts.store
+}
+
+// monthKey is the key of file name in the reports of month.
+func monthKey(month time.Time, name string) string {
+	return path.Join(month.Format("2006-01"), name)
+}
+
+// putArtifact renders write into memory and stores the result under key, so
+// a failed render never replaces the previous version.
+func putArtifact(key string, write func(io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	return Artifacts().Put(key, &buf)
+}
+
+// ReadArtifact returns the content of key.
+func ReadArtifact(key string) ([]byte, error) {
+	return readArtifact(Artifacts(), key)
+}
+
+func readArtifact(store ArtifactStore, key string) ([]byte, error) {
+	rc, _, err := store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,135 @@
+package billing
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// localStore keeps artifacts as files below root, WorkDir/tmp by default.
+type localStore struct {
+	root string
+}
+
+func newLocalStore(root string) *localStore {
+	return &localStore{root: root}
+}
+
+// filename maps key to a path below root, rejecting keys that escape it.
+func (s *localStore) filename(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if key == "" || clean != "/"+key {
+		return "", fmt.Errorf("invalid artifact key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the file next to its destination and renames it into place.
+func (s *localStore) Put(key string, r io.Reader) error {
+	filename, err := s.filename(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+
+	partial := filename + ".partial"
+	f, err := os.Create(partial)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(partial)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	if err := os.Rename(partial, filename); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return nil
+}
+
+func (s *localStore) Get(key string) (io.ReadCloser, Artifact, error) {
+	filename, err := s.filename(key)
+	if err != nil {
+		return nil, Artifact{}, err
+	}
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, Artifact{}, ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, Artifact{}, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, Artifact{}, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, Artifact{}, ErrArtifactNotFound
+	}
+	return f, Artifact{Key: key, Size: info.Size(), Modified: info.ModTime()}, nil
+}
+
+// List walks root; files still being written are skipped.
+func (s *localStore) List(prefix string) ([]Artifact, error) {
+	var list []Artifact
+	err := filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == s.root && os.IsNotExist(err) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if d.IsDir() {
+			// skip directories that cannot hold a matching key
+			if p != s.root && !strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !strings.HasPrefix(key, prefix) || strings.HasSuffix(key, ".partial") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		list = append(list, Artifact{Key: key, Size: info.Size(), Modified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list, nil
+}
+
+func (s *localStore) Delete(key string) error {
+	filename, err := s.filename(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package cluster
+
+// ─────────────────────────────────────────────────────────────────────────────
+//  Leader election between collators sharing a NATS cluster.
+//
+//  Every collator publishes a heartbeat on leaderSubject. A node that has not
+//  been heard from within the lease is considered gone. While a live node
+//  claims leadership the others follow it; when nobody does, the live node with
+//  the lowest NodeID takes over. Two claimants resolve to the lower NodeID.
+// ─────────────────────────────────────────────────────────────────────────────
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	common "github.com/ibp-network/ibp-geodns-collator/src/common"
+
+	log "github.com/ibp-network/ibp-geodns-libs/logging"
+	nats "github.com/ibp-network/ibp-geodns-libs/nats"
+
+	natsio "github.com/nats-io/nats.go"
+)
+
+const leaderSubject = "collator.leader.heartbeat"
+
+// Role names reported by Status.
+const (
+	RoleLeader   = "leader"
+	RoleFollower = "follower"
+)
+
+type heartbeat struct {
+	NodeID string `json:"NodeID"`
+	Leader bool   `json:"Leader"`
+	Resign bool   `json:"Resign,omitempty"` // sent on shutdown so peers take over at once
+}
+
+type peer struct {
+	lastHeard time.Time
+	leader    bool
+}
+
+// Status describes this node's view of the election.
+type Status struct {
+	NodeID string
+	Role   string
+	Leader string   // NodeID of the current leader, "" if unknown
+	Peers  []string // live peers, excluding this node
+	Since  time.Time
+}
+
+var state struct {
+	sync.RWMutex
+	nodeID  string
+	started time.Time
+	leader  bool
+	since   time.Time
+	peers   map[string]peer
+	sub     *natsio.Subscription
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// Init joins the election as nodeID. It must be called after the NATS
+// connection is established.
+func Init(nodeID string) error {
+	sub, err := nats.Subscribe(leaderSubject, handleHeartbeat)
+	if err != nil {
+		return err
+	}
+
+	state.Lock()
+	state.nodeID = nodeID
+	state.started = time.Now()
+	state.since = state.started
+	state.peers = make(map[string]peer)
+	state.sub = sub
+	state.stop = make(chan struct{})
+	state.done = make(chan struct{})
+	state.Unlock()
+
+	settings := common.GetSettings().Cluster
+	log.Log(log.Info, "[cluster] leader election started for node=%s (heartbeat %s, lease %s)",
+		nodeID, settings.HeartbeatIntervalDuration(), settings.LeaseTimeoutDuration())
+
+	go run(settings.HeartbeatIntervalDuration(), settings.LeaseTimeoutDuration())
+	return nil
+}
+
+// IsLeader reports whether this node currently runs the scheduled jobs.
+func IsLeader() bool {
+	state.RLock()
+	defer state.RUnlock()
+	return state.leader
+}
+
+// GetStatus returns this node's current view of the election.
+func GetStatus() Status {
+	state.RLock()
+	defer state.RUnlock()
+
+	lease := common.GetSettings().Cluster.LeaseTimeoutDuration()
+	st := Status{NodeID: state.nodeID, Role: RoleFollower, Since: state.since, Peers: []string{}}
+	if state.leader {
+		st.Role = RoleLeader
+		st.Leader = state.nodeID
+	}
+
+	for id, p := range state.peers {
+		if time.Since(p.lastHeard) > lease {
+			continue
+		}
+		st.Peers = append(st.Peers, id)
+		if p.leader && st.Leader == "" {
+			st.Leader = id
+		}
+	}
+	sort.Strings(st.Peers)
+	return st
+}
+
+// Shutdown resigns leadership so a peer can take over without waiting for the
+// lease to expire.
+func Shutdown() {
+	state.Lock()
+	stop, done, sub := state.stop, state.done, state.sub
+	state.stop = nil
+	state.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+
+	if sub != nil {
+		if err := sub.Unsubscribe(); err != nil {
+			log.Log(log.Debug, "[cluster] unsubscribe: %v", err)
+		}
+	}
+
+	state.Lock()
+	wasLeader := state.leader
+	state.leader = false
+	state.Unlock()
+
+	publish(true)
+	if wasLeader {
+		log.Log(log.Info, "[cluster] resigned leadership")
+	}
+}
+
+func run(interval, lease time.Duration) {
+	state.RLock()
+	stop, done := state.stop, state.done
+	state.RUnlock()
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	publish(false)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			elect(lease)
+			publish(false)
+		}
+	}
+}
+
+// elect re-evaluates leadership from the heartbeats heard so far.
+func elect(lease time.Duration) {
+	state.Lock()
+	defer state.Unlock()
+
+	now := time.Now()
+
+	// Listen for a full lease before claiming anything so an existing leader
+	// is discovered first.
+	if now.Sub(state.started) < lease {
+		return
+	}
+
+	lowest := state.nodeID
+	claimant := ""
+	for id, p := range state.peers {
+		if now.Sub(p.lastHeard) > lease {
+			delete(state.peers, id)
+			continue
+		}
+		if id < lowest {
+			lowest = id
+		}
+		if p.leader && (claimant == "" || id < claimant) {
+			claimant = id
+		}
+	}
+
+	shouldLead := false
+	switch {
+	case claimant != "":
+		// Follow an existing leader; two leaders resolve to the lower NodeID.
+		shouldLead = state.leader && state.nodeID < claimant
+	case state.leader:
+		shouldLead = true
+	default:
+		shouldLead = lowest == state.nodeID
+	}
+
+	// Never lead while our heartbeats cannot reach the peers.
+	if conn := nats.GetConnection(); conn == nil || !conn.IsConnected() {
+		shouldLead = false
+	}
+
+	if shouldLead != state.leader {
+		state.leader = shouldLead
+		state.since = now
+		if shouldLead {
+			log.Log(log.Info, "[cluster] node %s is now the leader", state.nodeID)
+		} else {
+			log.Log(log.Info, "[cluster] node %s is now a follower (leader %s)", state.nodeID, claimant)
+		}
+	}
+}
+
+func publish(resign bool) {
+	state.Lock()
+	hb := heartbeat{NodeID: state.nodeID, Leader: state.leader, Resign: resign}
+	state.Unlock()
+
+	data, _ := json.Marshal(hb)
+	if err := nats.Publish(leaderSubject, data); err != nil {
+		log.Log(log.Warn, "[cluster] failed to publish heartbeat: %v", err)
+
+		// A leader that cannot be heard would be replaced by its peers anyway;
+		// step down so two nodes never run the jobs at once.
+		state.Lock()
+		if state.leader {
+			state.leader = false
+			state.since = time.Now()
+			log.Log(log.Warn, "[cluster] node %s stepped down: NATS unavailable", state.nodeID)
+		}
+		state.Unlock()
+	}
+}
+
+func handleHeartbeat(m *natsio.Msg) {
+	var hb heartbeat
+	if err := json.Unmarshal(m.Data, &hb); err != nil {
+		log.Log(log.Debug, "[cluster] ignoring malformed heartbeat: %v", err)
+		return
+	}
+
+	state.Lock()
+	defer state.Unlock()
+
+	if hb.NodeID == "" || hb.NodeID == state.nodeID || state.peers == nil {
+		return
+	}
+	if hb.Resign {
+		delete(state.peers, hb.NodeID)
+		return
+	}
+	state.peers[hb.NodeID] = peer{lastHeard: time.Now(), leader: hb.Leader}
+}
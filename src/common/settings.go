@@ -0,0 +1,434 @@
+package common
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults applied when the corresponding setting is unset.
+const (
+	DefaultShutdownTimeout   = 30 * time.Second
+	DefaultHeartbeatInterval = 5 * time.Second
+	DefaultLeaseTimeout      = 15 * time.Second
+	DefaultInvoicePrefix     = "IBP"
+	DefaultInvoiceDueDays    = 30
+
+	DefaultMemberMaintenanceHours        = 24
+	DefaultMemberMonthlyMaintenanceHours = 72
+)
+
+// Settings holds collator-only options. They live in the same JSON file as the
+// shared ibp-geodns-libs configuration, which ignores keys it does not know.
+type Settings struct {
+	CollatorApi ApiSettings         `json:"CollatorApi"`
+	System      SystemSettings      `json:"System"`
+	Cluster     ClusterSettings     `json:"Cluster"`
+	Sla         SlaSettings         `json:"Sla"`
+	Maintenance MaintenanceSettings `json:"Maintenance"`
+	Credits     CreditSettings      `json:"Credits"`
+	Invoices    InvoiceSettings     `json:"Invoices"`
+	Currencies  CurrencySettings    `json:"Currencies"`
+	Signing     SigningSettings     `json:"Signing"`
+	Storage     StorageSettings     `json:"Storage"`
+}
+
+// SlaSettings configures uptime targets. The most specific match wins:
+// MemberTargets, then ServiceTargets, then LevelTargets, then DefaultTarget.
+type SlaSettings struct {
+	DefaultTarget  float64            `json:"DefaultTarget"`  // percent, 0 = built-in default
+	LevelTargets   map[int]float64    `json:"LevelTargets"`   // membership level → percent
+	ServiceTargets map[string]float64 `json:"ServiceTargets"` // service name → percent
+	MemberTargets  map[string]float64 `json:"MemberTargets"`  // member ID or name → percent
+}
+
+// SystemSettings holds process-level options.
+type SystemSettings struct {
+	ShutdownTimeout int `json:"ShutdownTimeout"` // seconds
+}
+
+// ShutdownTimeoutDuration returns the configured graceful shutdown bound.
+func (s SystemSettings) ShutdownTimeoutDuration() time.Duration {
+	return secondsOr(s.ShutdownTimeout, DefaultShutdownTimeout)
+}
+
+// ClusterSettings tunes leader election between collators.
+type ClusterSettings struct {
+	HeartbeatInterval int `json:"HeartbeatInterval"` // seconds
+	LeaseTimeout      int `json:"LeaseTimeout"`      // seconds without a heartbeat before a peer is dropped
+}
+
+// HeartbeatIntervalDuration returns how often leader heartbeats are sent.
+func (c ClusterSettings) HeartbeatIntervalDuration() time.Duration {
+	return secondsOr(c.HeartbeatInterval, DefaultHeartbeatInterval)
+}
+
+// LeaseTimeoutDuration returns how long a silent peer is still considered alive.
+func (c ClusterSettings) LeaseTimeoutDuration() time.Duration {
+	return secondsOr(c.LeaseTimeout, DefaultLeaseTimeout)
+}
+
+func (s SlaSettings) validate() error {
+	check := func(what string, v float64) error {
+		if v <= 0 || v > 100 {
+			return fmt.Errorf("%s target %.4f is outside (0, 100]", what, v)
+		}
+		return nil
+	}
+
+	if s.DefaultTarget != 0 {
+		if err := check("default", s.DefaultTarget); err != nil {
+			return err
+		}
+	}
+	for level, v := range s.LevelTargets {
+		if err := check(fmt.Sprintf("level %d", level), v); err != nil {
+			return err
+		}
+	}
+	for name, v := range s.ServiceTargets {
+		if err := check("service "+name, v); err != nil {
+			return err
+		}
+	}
+	for name, v := range s.MemberTargets {
+		if err := check("member "+name, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MaintenanceSettings limits the maintenance windows member-bound tokens may
+// announce, since downtime inside them does not count against the SLA. Longer
+// windows, or windows beyond the monthly budget, need a token without a member.
+type MaintenanceSettings struct {
+	MemberMaxHours     int `json:"MemberMaxHours"`     // longest single window
+	MemberMonthlyHours int `json:"MemberMonthlyHours"` // per member and calendar month, all windows together
+}
+
+// MemberMaxDuration returns the longest window a member token may announce.
+func (s MaintenanceSettings) MemberMaxDuration() time.Duration {
+	return hoursOr(s.MemberMaxHours, DefaultMemberMaintenanceHours)
+}
+
+// MemberMonthlyBudget returns how much of a month a member's windows may
+// cover when a member token adds one.
+func (s MaintenanceSettings) MemberMonthlyBudget() time.Duration {
+	return hoursOr(s.MemberMonthlyHours, DefaultMemberMonthlyMaintenanceHours)
+}
+
+func (s MaintenanceSettings) validate() error {
+	if s.MemberMaxHours < 0 || s.MemberMonthlyHours < 0 {
+		return fmt.Errorf("hours must not be negative")
+	}
+	return nil
+}
+
+// CreditSettings configures how SLA misses turn into service credits. Policy
+// applies to every member unless LevelPolicies has an entry for the member's
+// membership level. A policy without steps prorates linearly by downtime.
+type CreditSettings struct {
+	Policy        CreditPolicy         `json:"Policy"`
+	LevelPolicies map[int]CreditPolicy `json:"LevelPolicies"`
+}
+
+// CreditPolicy is a step table plus optional caps (percent of base cost, 0 = no cap).
+type CreditPolicy struct {
+	Steps      []CreditStep `json:"Steps"`
+	ServiceCap float64      `json:"ServiceCap"` // per service per month
+	MemberCap  float64      `json:"MemberCap"`  // per member per month, across services
+}
+
+// CreditStep grants Credit percent of the base cost when uptime is below Below.
+// When several steps match, the one with the lowest Below applies.
+type CreditStep struct {
+	Below  float64 `json:"Below"`
+	Credit float64 `json:"Credit"`
+}
+
+// PolicyForLevel returns the credit policy for a membership level.
+func (c CreditSettings) PolicyForLevel(level int) CreditPolicy {
+	if p, ok := c.LevelPolicies[level]; ok {
+		return p
+	}
+	return c.Policy
+}
+
+func (c CreditSettings) validate() error {
+	if err := c.Policy.validate("default"); err != nil {
+		return err
+	}
+	for level, p := range c.LevelPolicies {
+		if err := p.validate(fmt.Sprintf("level %d", level)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p CreditPolicy) validate(name string) error {
+	for _, st := range p.Steps {
+		if st.Below <= 0 || st.Below > 100 {
+			return fmt.Errorf("%s policy: step threshold %.4f is outside (0, 100]", name, st.Below)
+		}
+		if st.Credit < 0 || st.Credit > 100 {
+			return fmt.Errorf("%s policy: step credit %.4f is outside [0, 100]", name, st.Credit)
+		}
+	}
+	if p.ServiceCap < 0 || p.ServiceCap > 100 || p.MemberCap < 0 || p.MemberCap > 100 {
+		return fmt.Errorf("%s policy: caps must be within [0, 100]", name)
+	}
+	return nil
+}
+
+// InvoiceSettings configures the invoices issued with the monthly member PDFs.
+type InvoiceSettings struct {
+	Prefix  string `json:"Prefix"`  // invoice number prefix, e.g. "IBP" → IBP-000042
+	DueDays int    `json:"DueDays"` // days from issue to due date
+}
+
+// NumberPrefix returns the configured invoice number prefix.
+func (s InvoiceSettings) NumberPrefix() string {
+	if s.Prefix == "" {
+		return DefaultInvoicePrefix
+	}
+	return s.Prefix
+}
+
+// DueAfter returns the payment term.
+func (s InvoiceSettings) DueAfter() time.Duration {
+	days := s.DueDays
+	if days <= 0 {
+		days = DefaultInvoiceDueDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func (s InvoiceSettings) validate() error {
+	for _, r := range s.Prefix {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("prefix %q may only contain letters, digits, '-' and '_'", s.Prefix)
+		}
+	}
+	if s.DueDays < 0 {
+		return fmt.Errorf("due days must not be negative")
+	}
+	return nil
+}
+
+// CurrencySettings lists the currencies bills can be converted to besides
+// USD, and the file holding their monthly exchange rates (see
+// billing.ExchangeRate). Rates can also be provided through the API.
+type CurrencySettings struct {
+	Enabled   []CurrencyConfig `json:"Enabled"`
+	RatesFile string           `json:"RatesFile"`
+}
+
+// CurrencyConfig is a currency code and the decimals its amounts are shown with.
+type CurrencyConfig struct {
+	Code     string `json:"Code"`
+	Decimals int    `json:"Decimals"`
+}
+
+// Lookup returns the configured currency with code (case-insensitive).
+func (s CurrencySettings) Lookup(code string) (CurrencyConfig, bool) {
+	for _, c := range s.Enabled {
+		if strings.EqualFold(c.Code, code) {
+			return c, true
+		}
+	}
+	return CurrencyConfig{}, false
+}
+
+func (s CurrencySettings) validate() error {
+	seen := make(map[string]bool, len(s.Enabled))
+	for _, c := range s.Enabled {
+		code := strings.ToUpper(c.Code)
+		if len(code) < 2 || len(code) > 10 || strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789") != "" {
+			return fmt.Errorf("invalid currency code %q", c.Code)
+		}
+		if code == "USD" {
+			return fmt.Errorf("USD is the billing currency and must not be listed")
+		}
+		if seen[code] {
+			return fmt.Errorf("currency %s listed twice", code)
+		}
+		seen[code] = true
+		if c.Decimals < 0 || c.Decimals > 10 {
+			return fmt.Errorf("currency %s: decimals must be within [0, 10]", code)
+		}
+	}
+	return nil
+}
+
+// SigningSettings names the Ed25519 key the monthly report manifests are
+// signed with: a PKCS#8 PEM file as written by
+// `openssl genpkey -algorithm ed25519`. Manifests are unsigned without it.
+type SigningSettings struct {
+	KeyFile string `json:"KeyFile"`
+}
+
+// PrivateKey reads the signing key, or returns nil when none is configured.
+func (s SigningSettings) PrivateKey() (ed25519.PrivateKey, error) {
+	if s.KeyFile == "" {
+		return nil, nil
+	}
+	raw, err := os.ReadFile(s.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", s.KeyFile)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.KeyFile, err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key", s.KeyFile)
+	}
+	return edKey, nil
+}
+
+func (s SigningSettings) validate() error {
+	_, err := s.PrivateKey()
+	return err
+}
+
+// Artifact storage backends.
+const (
+	StorageLocal = "local"
+	StorageS3    = "s3"
+)
+
+// StorageSettings selects where generated reports are kept: Backend "local"
+// (the default) uses WorkDir/tmp, "s3" an S3-compatible bucket.
+type StorageSettings struct {
+	Backend string     `json:"Backend"`
+	S3      S3Settings `json:"S3"`
+}
+
+// S3Settings addresses an S3-compatible bucket (AWS, MinIO, ...) with
+// path-style requests, e.g. http://localhost:9000/<bucket>/<prefix><key>.
+type S3Settings struct {
+	Endpoint  string `json:"Endpoint"`
+	Region    string `json:"Region"` // default us-east-1
+	Bucket    string `json:"Bucket"`
+	Prefix    string `json:"Prefix"` // prepended to every key, e.g. "collator/"
+	AccessKey string `json:"AccessKey"`
+	SecretKey string `json:"SecretKey"`
+}
+
+// BackendName returns the configured backend.
+func (s StorageSettings) BackendName() string {
+	if s.Backend == "" {
+		return StorageLocal
+	}
+	return strings.ToLower(s.Backend)
+}
+
+func (s StorageSettings) validate() error {
+	switch s.BackendName() {
+	case StorageLocal:
+		return nil
+	case StorageS3:
+	default:
+		return fmt.Errorf("unknown backend %q", s.Backend)
+	}
+	if !strings.HasPrefix(s.S3.Endpoint, "http://") && !strings.HasPrefix(s.S3.Endpoint, "https://") {
+		return fmt.Errorf("S3 endpoint %q must be an http(s) URL", s.S3.Endpoint)
+	}
+	if s.S3.Bucket == "" || s.S3.AccessKey == "" || s.S3.SecretKey == "" {
+		return fmt.Errorf("S3 bucket, access key and secret key are required")
+	}
+	return nil
+}
+
+func secondsOr(seconds int, def time.Duration) time.Duration {
+	if seconds <= 0 {
+		return def
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func hoursOr(hours, def int) time.Duration {
+	if hours <= 0 {
+		hours = def
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// ApiSettings configures access to the collator API.
+type ApiSettings struct {
+	Tokens []ApiToken `json:"Tokens"`
+}
+
+// ApiToken is a static bearer credential together with the scopes it grants.
+// Setting Member binds the token to a single member (config member ID) so it
+// can only ever see that member's data.
+type ApiToken struct {
+	Name   string   `json:"Name"`
+	Token  string   `json:"Token"`
+	Scopes []string `json:"Scopes"`
+	Member string   `json:"Member,omitempty"`
+}
+
+var (
+	settingsMu sync.RWMutex
+	settings   Settings
+)
+
+// LoadSettings reads the collator-only options from the configuration file.
+func LoadSettings(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read settings: %w", err)
+	}
+
+	var s Settings
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return fmt.Errorf("parse settings: %w", err)
+	}
+	if err := s.Sla.validate(); err != nil {
+		return fmt.Errorf("invalid Sla settings: %w", err)
+	}
+	if err := s.Maintenance.validate(); err != nil {
+		return fmt.Errorf("invalid Maintenance settings: %w", err)
+	}
+	if err := s.Credits.validate(); err != nil {
+		return fmt.Errorf("invalid Credits settings: %w", err)
+	}
+	if err := s.Invoices.validate(); err != nil {
+		return fmt.Errorf("invalid Invoices settings: %w", err)
+	}
+	if err := s.Currencies.validate(); err != nil {
+		return fmt.Errorf("invalid Currencies settings: %w", err)
+	}
+	if err := s.Signing.validate(); err != nil {
+		return fmt.Errorf("invalid Signing settings: %w", err)
+	}
+	if err := s.Storage.validate(); err != nil {
+		return fmt.Errorf("invalid Storage settings: %w", err)
+	}
+
+	settingsMu.Lock()
+	settings = s
+	settingsMu.Unlock()
+	return nil
+}
+
+// GetSettings returns the currently loaded collator-only options.
+func GetSettings() Settings {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return settings
+}